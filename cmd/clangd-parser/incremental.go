@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"clangd-parser/internal/fileuri"
+	"clangd-parser/internal/lsp"
+	"clangd-parser/internal/manifest"
+	"clangd-parser/internal/model"
+	"clangd-parser/internal/output"
+	"clangd-parser/internal/parser"
+)
+
+// incremental carries the state of an -incremental run from planning to
+// saving the new manifest.
+//
+// Chunks of unchanged files are carried over as they were written, including
+// their cross-file links. Those can go stale when only the other end of a
+// link changes; a run without -incremental rebuilds everything. The
+// exception is a declaration merged into its definition with -decls: the
+// declaration only survives inside the definition's chunk, so both files are
+// parsed again when either of them changes.
+type incremental struct {
+	path   string // manifest path
+	config string
+
+	prev   *manifest.Manifest
+	states map[string]manifest.State
+	plan   manifest.Plan
+
+	// reused holds the carried over chunks by file
+	reused map[string][]model.SemanticChunk
+
+	// failed files are left out of the manifest so they are retried
+	failed map[string]bool
+}
+
+func newIncremental(cfg config, spec lsp.ServerSpec) *incremental {
+	return &incremental{
+		path:   output.ManifestPath(cfg.outputFile),
+		config: cfg.fingerprint(spec),
+		failed: make(map[string]bool),
+	}
+}
+
+// fingerprint identifies the settings chunks depend on
+func (cfg config) fingerprint(spec lsp.ServerSpec) string {
+	parts := []string{
+		spec.Name, strings.Join(spec.Args, " "), strings.Join(cfg.enrichmentNames(), ","), strconv.Itoa(cfg.maxUsages),
+		cfg.kinds.String(), strconv.Itoa(cfg.minTokens), strconv.Itoa(cfg.maxTokens),
+	}
+	return manifest.Fingerprint(parts...)
+}
+
+// previousServer returns the server recorded in the index info of the last
+// run, for runs that start no server because nothing changed. It returns
+// fallback if the last run left no index info.
+func (inc *incremental) previousServer(cfg config, fallback lsp.ServerInfo) lsp.ServerInfo {
+	info, err := output.ReadIndexInfo(output.MetaPath(cfg.outputFile))
+	if err != nil || info.Server == "" {
+		return fallback
+	}
+	return lsp.ServerInfo{Name: info.Server, Version: info.ServerVersion}
+}
+
+// planFiles hashes the discovered files and returns the ones that need to be
+// parsed. files must be normalized.
+func (inc *incremental) planFiles(cfg config, files []string) ([]string, error) {
+	prev, err := manifest.Load(inc.path)
+	if err != nil {
+		return nil, err
+	}
+	inc.prev = prev
+
+	commands := make(map[string]string)
+	if cmds, err := parser.LoadCompileCommands(cfg.compileDB); err == nil {
+		for _, cmd := range cmds {
+			commands[cmd.Path()] = manifest.HashCommand(cmd)
+		}
+	}
+
+	inc.states = make(map[string]manifest.State, len(files))
+	for _, file := range files {
+		hash, err := manifest.HashFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("hash %s: %w", file, err)
+		}
+		inc.states[file] = manifest.State{ContentHash: hash, CommandHash: commands[file]}
+	}
+
+	inc.plan = prev.Plan(inc.config, files, inc.states)
+	if len(inc.plan.Reuse) > 0 {
+		if err := inc.loadReused(cfg.outputFile, cfg.decls); err != nil {
+			log.Printf("  ⚠️  Can't carry over chunks (%v), reindexing everything", err)
+			inc.plan.Parse, inc.plan.Reuse = files, nil
+		}
+	}
+
+	switch {
+	case prev == nil:
+		log.Printf("  No manifest at %s, indexing everything", inc.path)
+	case prev.Version != manifest.Version || prev.Config != inc.config:
+		log.Printf("  Manifest was written with other settings, indexing everything")
+	default:
+		log.Printf("  %d changed, %d unchanged, %d deleted", len(inc.plan.Parse), len(inc.plan.Reuse), len(inc.plan.Deleted))
+	}
+
+	return inc.plan.Parse, nil
+}
+
+// loadReused reads the chunks of unchanged files from the previous output.
+// With merged declarations, unchanged files linked to a changed one are
+// moved to the files to parse first.
+func (inc *incremental) loadReused(outputFile string, merged bool) error {
+	chunks, err := output.ReadJSON(outputFile)
+	if err != nil {
+		return err
+	}
+	if merged {
+		inc.parseLinked(chunks)
+	}
+
+	byID := make(map[string]model.SemanticChunk, len(chunks))
+	for _, c := range chunks {
+		byID[c.ID] = c
+	}
+
+	inc.reused = make(map[string][]model.SemanticChunk, len(inc.plan.Reuse))
+	for _, file := range inc.plan.Reuse {
+		for _, id := range inc.prev.Files[file].ChunkIDs {
+			c, ok := byID[id]
+			if !ok {
+				return fmt.Errorf("chunk %s missing from %s", id, outputFile)
+			}
+			inc.reused[file] = append(inc.reused[file], c)
+		}
+	}
+	return nil
+}
+
+// parseLinked moves unchanged files that are linked by a declaration and its
+// definition to a changed or deleted file, directly or through other linked
+// files, from the files to reuse to the files to parse
+func (inc *incremental) parseLinked(chunks []model.SemanticChunk) {
+	links := make(map[string][]string)
+	for _, c := range chunks {
+		for _, ref := range []*model.ChunkRef{c.Declaration, c.Definition} {
+			if ref == nil {
+				continue
+			}
+			a, b := fileuri.Normalize(c.Context.FilePath), fileuri.Normalize(ref.FilePath)
+			if a != b {
+				links[a] = append(links[a], b)
+				links[b] = append(links[b], a)
+			}
+		}
+	}
+
+	reuse := make(map[string]bool, len(inc.plan.Reuse))
+	for _, file := range inc.plan.Reuse {
+		reuse[file] = true
+	}
+
+	queue := append(append([]string(nil), inc.plan.Parse...), inc.plan.Deleted...)
+	moved := make(map[string]bool)
+	for len(queue) > 0 {
+		file := queue[0]
+		queue = queue[1:]
+		for _, other := range links[file] {
+			if reuse[other] && !moved[other] {
+				moved[other] = true
+				queue = append(queue, other)
+			}
+		}
+	}
+	if len(moved) == 0 {
+		return
+	}
+
+	var kept []string
+	for _, file := range inc.plan.Reuse {
+		if moved[file] {
+			inc.plan.Parse = append(inc.plan.Parse, file)
+		} else {
+			kept = append(kept, file)
+		}
+	}
+	inc.plan.Reuse = kept
+}
+
+// combine merges freshly parsed chunks with the carried over ones in the
+// order of files
+func (inc *incremental) combine(files []string, parsed []model.SemanticChunk) []model.SemanticChunk {
+	byFile := make(map[string][]model.SemanticChunk)
+	for _, c := range parsed {
+		byFile[c.Context.FilePath] = append(byFile[c.Context.FilePath], c)
+	}
+
+	var chunks []model.SemanticChunk
+	for _, file := range files {
+		if reused, ok := inc.reused[file]; ok {
+			chunks = append(chunks, reused...)
+		} else {
+			chunks = append(chunks, byFile[file]...)
+		}
+	}
+	return chunks
+}
+
+// save writes the manifest for the final chunks of the run
+func (inc *incremental) save(chunks []model.SemanticChunk) error {
+	m := manifest.New(inc.config)
+	for file, state := range inc.states {
+		if !inc.failed[file] {
+			m.Files[file] = manifest.Entry{State: state, ChunkIDs: []string{}}
+		}
+	}
+
+	for _, c := range chunks {
+		file := fileuri.Normalize(c.Context.FilePath)
+		if entry, ok := m.Files[file]; ok {
+			entry.ChunkIDs = append(entry.ChunkIDs, c.ID)
+			m.Files[file] = entry
+		}
+	}
+
+	return m.Save(inc.path)
+}
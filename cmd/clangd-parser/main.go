@@ -1,49 +1,364 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
+	"clangd-parser/internal/enrich"
+	"clangd-parser/internal/fileuri"
+	"clangd-parser/internal/indexer"
 	"clangd-parser/internal/lsp"
 	"clangd-parser/internal/model"
 	"clangd-parser/internal/output"
 	"clangd-parser/internal/parser"
 )
 
+// config holds the parsed command line
+type config struct {
+	compileDB  string
+	rootPath   string
+	outputFile string
+	testFile   string
+	compact    bool
+	jobs       int
+
+	// discover selects how files are found: "walk" the root or read the
+	// units from the "compile-db"
+	discover string
+
+	// include and exclude are doublestar globs relative to the root
+	include stringList
+	exclude stringList
+
+	// Optional enrichment passes
+	hover bool
+	calls bool
+	types bool
+	refs  bool
+	decls bool
+
+	// maxUsages caps the usage sites stored per chunk with -refs
+	maxUsages int
+
+	// kinds selects what becomes of small symbols such as fields and
+	// enumerators
+	kinds parser.Kinds
+
+	// minTokens is the size tiny sibling chunks are grouped up to and
+	// maxTokens the budget above which chunks are split into parts; zero
+	// disables either
+	minTokens int
+	maxTokens int
+
+	// indexTimeout is how long to wait for the background index before
+	// querying; zero skips waiting
+	indexTimeout time.Duration
+
+	// Timeout policy. requestTimeout bounds each LSP request, fileTimeout
+	// all work on one file; zero disables either.
+	requestTimeout     time.Duration
+	diagnosticsTimeout time.Duration
+	fileTimeout        time.Duration
+
+	// maxCrashes is how often a file may crash a worker before it is
+	// quarantined; restartBackoff is the delay before the first restart
+	maxCrashes     int
+	restartBackoff time.Duration
+
+	server     string
+	serverPath string
+	serverArgs stringList
+	serverEnv  stringList
+
+	// incremental only re-parses files that changed since the manifest
+	// written next to the output
+	incremental bool
+
+	// connect is the address of a running server to use instead of
+	// starting one. Each run opens one session on it, so the socket must
+	// start a server per connection or lead to a multiplexer sharing a warm
+	// server between sessions.
+	connect string
+
+	// record is the transcript path for recording sessions, replay one to
+	// replay instead of starting a language server
+	record   string
+	replay   string
+	recorder *recorder
+
+	// newClient starts a language server client. Tests replace it with a
+	// fake server.
+	newClient func(ctx context.Context, spec lsp.ServerSpec, rootPath string) (*lsp.Client, error)
+}
+
+// stringList is a flag that can be given multiple times
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, " ")
+}
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// serverSpec builds the language server spec from the command line
+func (cfg config) serverSpec() (lsp.ServerSpec, error) {
+	spec, err := lsp.SpecByName(cfg.server, cfg.compileDB)
+	if err != nil {
+		return spec, err
+	}
+	if cfg.serverPath != "" {
+		spec.Command = cfg.serverPath
+	}
+	spec.Args = append(spec.Args, cfg.serverArgs...)
+	spec.Env = append(spec.Env, cfg.serverEnv...)
+	return spec, nil
+}
+
+// startClient starts a language server client with the configured timeouts
+func (cfg config) startClient(ctx context.Context, spec lsp.ServerSpec) (*lsp.Client, error) {
+	if cfg.recorder != nil {
+		f, err := cfg.recorder.create()
+		if err != nil {
+			return nil, err
+		}
+		spec.Transcript = f
+	}
+
+	client, err := cfg.newClient(ctx, spec, cfg.rootPath)
+	if err != nil {
+		return nil, err
+	}
+	client.Timeouts.Request = cfg.requestTimeout
+	client.Timeouts.Diagnostics = cfg.diagnosticsTimeout
+	return client, nil
+}
+
 func main() {
-	compileDB := flag.String("compile-db", "/tmp", "Path to compile_commands.json directory")
-	rootPath := flag.String("root", ".", "Root directory of the project")
-	outputFile := flag.String("output", "chunks.json", "Output JSON file path")
-	testFile := flag.String("test-file", "", "Single C++ file to test parsing")
-	compact := flag.Bool("compact", false, "Write compact JSON (no indentation)")
+	cfg := config{newClient: lsp.NewClientWithSpec}
+	flag.StringVar(&cfg.compileDB, "compile-db", "/tmp", "Path to compile_commands.json directory")
+	flag.StringVar(&cfg.rootPath, "root", ".", "Root directory of the project")
+	flag.StringVar(&cfg.outputFile, "output", "chunks.json", "Output JSON file path")
+	flag.StringVar(&cfg.testFile, "test-file", "", "Single C++ file to test parsing")
+	flag.StringVar(&cfg.discover, "discover", "walk", "How to find files: walk (all C++ files under -root) or compile-db (units in -compile-db plus the headers they include)")
+	flag.Var(&cfg.include, "include", "Only index files matching this glob relative to -root, e.g. 'src/**' (repeatable)")
+	flag.Var(&cfg.exclude, "exclude", "Skip files matching this glob relative to -root, e.g. '**/*.pb.h' (repeatable)")
+	flag.BoolVar(&cfg.compact, "compact", false, "Write compact JSON (no indentation)")
+	flag.IntVar(&cfg.jobs, "jobs", runtime.NumCPU(), "Number of parallel clangd workers")
+	flag.BoolVar(&cfg.hover, "hover", false, "Fill missing signatures and docstrings from hover information")
+	flag.BoolVar(&cfg.calls, "calls", false, "Record callers and callees of functions (use with -index-timeout)")
+	flag.BoolVar(&cfg.types, "types", false, "Record base and derived classes and method overrides (use with -index-timeout)")
+	flag.BoolVar(&cfg.refs, "refs", false, "Count references and sample usage sites (use with -index-timeout)")
+	flag.BoolVar(&cfg.decls, "decls", false, "Merge header declarations of functions into their definitions (use with -index-timeout)")
+	flag.IntVar(&cfg.maxUsages, "max-usages", 5, "Maximum usage sites stored per chunk with -refs")
+	flag.Var(&cfg.kinds, "kinds", "Extract small symbols as kind=mode pairs, e.g. 'EnumMember=member,Constant=chunk'; kinds: "+strings.Join(parser.SmallKinds, ", ")+"; modes: chunk (own chunk), member (listed in the parent chunk), skip (repeatable)")
+	flag.IntVar(&cfg.minTokens, "min-tokens", 0, "Group adjacent sibling chunks smaller than this many estimated tokens, e.g. 64 (0 = don't group)")
+	flag.IntVar(&cfg.maxTokens, "max-tokens", 0, "Split chunks larger than this many estimated tokens into parts, e.g. 512 (0 = don't split)")
+	flag.DurationVar(&cfg.indexTimeout, "index-timeout", 0, "Wait up to this long for the background index before parsing (0 = don't wait)")
+	flag.DurationVar(&cfg.requestTimeout, "request-timeout", lsp.DefaultTimeouts().Request, "Timeout for each language server request (0 = none)")
+	flag.DurationVar(&cfg.diagnosticsTimeout, "diagnostics-timeout", lsp.DefaultTimeouts().Diagnostics, "How long to wait for a file's diagnostics (0 = don't wait)")
+	flag.DurationVar(&cfg.fileTimeout, "file-timeout", 0, "Timeout for all work on a single file, including enrichment (0 = none)")
+	flag.IntVar(&cfg.maxCrashes, "max-crashes", indexer.DefaultMaxCrashes, "Quarantine a file after it crashed or hung the language server this many times")
+	flag.DurationVar(&cfg.restartBackoff, "restart-backoff", indexer.DefaultRestartBackoff, "Delay before restarting a crashed language server, doubling on each consecutive restart")
+	flag.StringVar(&cfg.server, "server", "clangd", "Language server backend (clangd, ccls)")
+	flag.StringVar(&cfg.serverPath, "server-path", "", "Path to the language server binary (defaults to the backend name)")
+	flag.Var(&cfg.serverArgs, "server-arg", "Extra argument for the language server (repeatable)")
+	flag.Var(&cfg.serverEnv, "server-env", "Extra KEY=VALUE environment entry for the language server (repeatable)")
+	flag.BoolVar(&cfg.incremental, "incremental", false, "Only re-parse files that changed since the last run with the same -output, carrying over the other chunks")
+	flag.StringVar(&cfg.connect, "connect", "", "Connect to a running language server at tcp://host:port or unix:///path instead of starting one; a server accepts a single session, so the socket must start a server per connection or lead to a multiplexer (implies -jobs 1)")
+	flag.StringVar(&cfg.record, "record", "", "Record the language server sessions to this transcript file (one file per worker)")
+	flag.StringVar(&cfg.replay, "replay", "", "Replay a recorded transcript instead of starting a language server")
 	flag.Parse()
 
+	// Ctrl-C cancels the requests in flight and stops the run
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, cfg); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+}
+
+func run(ctx context.Context, cfg config) error {
 	log.Println("Clangd C++ Parser - Complete Pipeline")
 	log.Println("======================================")
 
-	// Step 1: Start LSP Client
-	log.Println("\n→ Step 1: Starting clangd...")
-	client, err := lsp.NewClient(*compileDB, *rootPath)
+	spec, err := cfg.serverSpec()
+	if err != nil {
+		return err
+	}
+
+	if cfg.connect != "" {
+		socket, err := lsp.ParseSocket(cfg.connect)
+		if err != nil {
+			return fmt.Errorf("invalid -connect: %w", err)
+		}
+		cfg.newClient = func(ctx context.Context, spec lsp.ServerSpec, rootPath string) (*lsp.Client, error) {
+			client, err := lsp.NewClientWithTransport(ctx, socket, spec, rootPath)
+			if err != nil {
+				return nil, fmt.Errorf("%w (the server at %s must accept a new session per connection, e.g. through a multiplexer)", err, socket)
+			}
+			return client, nil
+		}
+		// Every worker would open a session of its own
+		cfg.jobs = 1
+		log.Printf("✓ Using the %s server at %s", spec.Name, socket)
+	}
+	var replay *replayer
+	if cfg.replay != "" {
+		replay, err = newReplayer(cfg.replay)
+		if err != nil {
+			return err
+		}
+		defer replay.close()
+		cfg.newClient = replay.newClient
+		// A transcript holds a single session
+		cfg.jobs = 1
+		log.Printf("✓ Replaying %s", cfg.replay)
+	}
+	if cfg.record != "" {
+		cfg.recorder = &recorder{path: cfg.record}
+		defer func() {
+			for _, path := range cfg.recorder.close() {
+				log.Printf("✓ Recorded transcript: %s", path)
+			}
+		}()
+	}
+
+	var allChunks []model.SemanticChunk
+	var server lsp.ServerInfo
+	var inc *incremental
+
+	if cfg.testFile != "" {
+		allChunks, server, err = runSequential(ctx, cfg, spec, []string{cfg.testFile})
+	} else {
+		if cfg.incremental {
+			inc = newIncremental(cfg, spec)
+		}
+		allChunks, server, err = runParallel(ctx, cfg, spec, inc)
+	}
+	if errors.Is(err, context.Canceled) {
+		return fmt.Errorf("interrupted: %w", err)
+	}
 	if err != nil {
-		log.Fatalf("❌ Failed to create LSP client: %v", err)
+		return err
+	}
+	if replay != nil {
+		// The clients are closed, so each replay has seen its whole session
+		if err := replay.close(); err != nil {
+			return err
+		}
 	}
-	defer client.Close()
 
-	info, _ := client.GetServerInfo()
-	log.Printf("✓ %s", info)
+	if cfg.types {
+		n := enrich.LinkOverrides(allChunks)
+		log.Printf("✓ Linked %d overriding methods", n)
+	}
+
+	n := enrich.ResolveScopes(allChunks)
+	log.Printf("✓ Resolved the scopes of %d chunks", n)
+
+	if cfg.decls {
+		allChunks, n = enrich.MergeDeclarations(allChunks)
+		log.Printf("✓ Merged %d declarations into their definitions", n)
+	}
+
+	if cfg.minTokens > 0 {
+		n = enrich.RedirectToGroups(allChunks)
+		log.Printf("✓ Pointed references to %d grouped chunks at their groups", n)
+	}
+
+	log.Printf("✓ Total chunks created: %d", len(allChunks))
+
+	// Step 4: Write output
+	log.Println("\n→ Step 4: Writing output...")
+
+	if cfg.compact {
+		err = output.WriteJSONCompact(allChunks, cfg.outputFile)
+	} else {
+		err = output.WriteJSON(allChunks, cfg.outputFile)
+	}
 
-	// Step 2: Discover C++ files
-	log.Println("\n→ Step 2: Discovering C++ files...")
-	files, err := parser.FindCppFiles(*rootPath)
 	if err != nil {
-		log.Fatalf("❌ Failed to find C++ files: %v", err)
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+
+	log.Printf("✓ Wrote output to: %s", cfg.outputFile)
+
+	info := model.IndexInfo{
+		Server:        server.Name,
+		ServerVersion: server.Version,
+		ServerArgs:    spec.Args,
+		Enrichments:   cfg.enrichmentNames(),
+		Kinds:         cfg.kinds.String(),
+		MinTokens:     cfg.minTokens,
+		MaxTokens:     cfg.maxTokens,
+		Chunks:        len(allChunks),
+	}
+	metaPath := output.MetaPath(cfg.outputFile)
+	if err := output.WriteIndexInfo(info, metaPath); err != nil {
+		return fmt.Errorf("failed to write index info: %w", err)
 	}
+	log.Printf("✓ Wrote index info to: %s", metaPath)
 
-	// If test file specified, only parse that
-	if *testFile != "" {
-		files = []string{*testFile}
+	if inc != nil {
+		if err := inc.save(allChunks); err != nil {
+			return fmt.Errorf("failed to write manifest: %w", err)
+		}
+		log.Printf("✓ Wrote manifest to: %s", inc.path)
 	}
 
+	// Show statistics
+	stats := output.GetOutputStats(allChunks)
+	log.Println("\n📊 Statistics:")
+	log.Printf("  Total chunks: %d", stats["total_chunks"])
+	log.Printf("  With docstrings: %d", stats["with_docstring"])
+	log.Printf("  From files with errors: %d", stats["with_errors"])
+
+	log.Println("  By type:")
+	byType := stats["by_type"].(map[string]int)
+	for codeType, count := range byType {
+		log.Printf("    %s: %d", codeType, count)
+	}
+
+	log.Println("\n✅ Complete! All steps finished successfully!")
+	return nil
+}
+
+// runSequential processes files one at a time through a single clangd
+// instance. It is used for -test-file runs.
+func runSequential(ctx context.Context, cfg config, spec lsp.ServerSpec, files []string) ([]model.SemanticChunk, lsp.ServerInfo, error) {
+	// Step 1: Start LSP Client
+	log.Printf("\n→ Step 1: Starting %s...", spec.Name)
+	client, err := cfg.startClient(ctx, spec)
+	if err != nil {
+		return nil, lsp.ServerInfo{}, fmt.Errorf("failed to create LSP client: %w", err)
+	}
+	defer client.Close(context.Background())
+
+	server := client.ServerInfo()
+	log.Printf("✓ Connected to %s", server)
+	cfg.warnUnsupported(client.Capabilities(), server.Name)
+
+	waitForIndex(ctx, client, cfg)
+
+	// Step 2: Files are given explicitly
+	log.Println("\n→ Step 2: Using explicitly given files...")
 	log.Printf("✓ Found %d C++ files to process", len(files))
 
 	// Step 3: Parse symbols and create chunks
@@ -54,16 +369,18 @@ func main() {
 	errorCount := 0
 
 	for i, file := range files {
+		if ctx.Err() != nil {
+			return nil, server, ctx.Err()
+		}
 		log.Printf("  [%d/%d] Processing %s", i+1, len(files), file)
 
-		symbols, err := client.GetDocumentSymbols(file)
+		chunks, err := cfg.processFile(ctx, client, file)
 		if err != nil {
 			log.Printf("  ⚠️  Warning: %v", err)
 			errorCount++
 			continue
 		}
 
-		chunks := parser.ConvertSymbolsToChunks(symbols, file)
 		allChunks = append(allChunks, chunks...)
 		successCount++
 
@@ -75,34 +392,282 @@ func main() {
 	}
 
 	log.Printf("\n✓ Processed %d files successfully (%d errors)", successCount, errorCount)
-	log.Printf("✓ Total chunks created: %d", len(allChunks))
+	return allChunks, server, nil
+}
 
-	// Step 4: Write output
-	log.Println("\n→ Step 4: Writing output...")
+// discoverFiles finds the files to process the way -discover asks for
+func discoverFiles(cfg config) ([]string, error) {
+	filter := parser.Filter{Include: cfg.include, Exclude: cfg.exclude}
 
-	if *compact {
-		err = output.WriteJSONCompact(allChunks, *outputFile)
-	} else {
-		err = output.WriteJSON(allChunks, *outputFile)
+	switch cfg.discover {
+	case "", "walk":
+		return parser.FindCppFiles(cfg.rootPath, filter)
+	case "compile-db":
+		if err := filter.Validate(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown discovery mode %q (want walk or compile-db)", cfg.discover)
 	}
 
+	d, err := parser.FindCompileDBFiles(cfg.compileDB, cfg.rootPath)
 	if err != nil {
-		log.Fatalf("❌ Failed to write output: %v", err)
+		return nil, err
 	}
 
-	log.Printf("✓ Wrote output to: %s", *outputFile)
+	log.Printf("  %d translation units and %d included headers", d.Units, len(d.Files)-d.Units)
+	for _, path := range d.Missing {
+		log.Printf("  ⚠️  In the compile database but missing: %s", path)
+	}
+	if len(d.NoCommand) > 0 {
+		log.Printf("  ℹ️  %d files have no compile command, their flags are inferred:", len(d.NoCommand))
+		for _, path := range d.NoCommand {
+			log.Printf("    %s", path)
+		}
+	}
 
-	// Show statistics
-	stats := output.GetOutputStats(allChunks)
-	log.Println("\n📊 Statistics:")
-	log.Printf("  Total chunks: %d", stats["total_chunks"])
-	log.Printf("  With docstrings: %d", stats["with_docstring"])
+	root := fileuri.Normalize(cfg.rootPath)
+	var files []string
+	for _, path := range d.Files {
+		if rel, err := filepath.Rel(root, path); err != nil || filter.Keep(rel) {
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
 
-	log.Println("  By type:")
-	byType := stats["by_type"].(map[string]int)
-	for codeType, count := range byType {
-		log.Printf("    %s: %d", codeType, count)
+// processFile runs a single file bounded by the file timeout
+func (cfg config) processFile(ctx context.Context, w indexer.Worker, file string) ([]model.SemanticChunk, error) {
+	if cfg.fileTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.fileTimeout)
+		defer cancel()
 	}
+	return indexer.ProcessFile(ctx, w, file, cfg.parseOptions(), cfg.enrichers()...)
+}
 
-	log.Println("\n✅ Complete! All steps finished successfully!")
+// parseOptions returns how symbols become chunks according to the command
+// line
+func (cfg config) parseOptions() parser.Options {
+	return parser.Options{
+		Kinds: cfg.kinds,
+		Size:  parser.ChunkSize{MinTokens: cfg.minTokens, MaxTokens: cfg.maxTokens},
+	}
+}
+
+// crossFile reports whether an enrichment pass enabled on the command line
+// queries the server's index for other files
+func (cfg config) crossFile() bool {
+	return cfg.calls || cfg.types || cfg.refs || cfg.decls
+}
+
+// enrichmentNames lists the enrichment passes enabled on the command line
+func (cfg config) enrichmentNames() []string {
+	var names []string
+	for _, e := range []struct {
+		name    string
+		enabled bool
+	}{{"hover", cfg.hover}, {"calls", cfg.calls}, {"types", cfg.types}, {"refs", cfg.refs}, {"decls", cfg.decls}} {
+		if e.enabled {
+			names = append(names, e.name)
+		}
+	}
+	return names
+}
+
+// warnUnsupported logs enabled enrichment passes the server can't serve
+func (cfg config) warnUnsupported(caps lsp.ServerCapabilities, server string) {
+	if cfg.hover && !bool(caps.HoverProvider) {
+		log.Printf("  ⚠️  %s doesn't support hover, -hover has no effect", server)
+	}
+	if cfg.calls && !bool(caps.CallHierarchyProvider) {
+		log.Printf("  ⚠️  %s doesn't support call hierarchy, -calls has no effect", server)
+	}
+	if cfg.types && !bool(caps.TypeHierarchyProvider) {
+		log.Printf("  ℹ️  %s doesn't advertise type hierarchy, -types falls back to clangd's extension", server)
+	}
+	if cfg.refs && !bool(caps.ReferencesProvider) {
+		log.Printf("  ⚠️  %s doesn't support references, -refs has no effect", server)
+	}
+	if cfg.decls && !bool(caps.DefinitionProvider) && !bool(caps.DeclarationProvider) {
+		log.Printf("  ⚠️  %s doesn't support definition or declaration, -decls has no effect", server)
+	}
+	if cfg.maxTokens > 0 && !bool(caps.FoldingRangeProvider) {
+		log.Printf("  ℹ️  %s doesn't support folding ranges, -max-tokens splits only between nested symbols and statements", server)
+	}
+}
+
+// enrichers returns the enrichment passes enabled on the command line
+func (cfg config) enrichers() []indexer.EnrichFunc {
+	var fns []indexer.EnrichFunc
+	if cfg.hover {
+		fns = append(fns, func(ctx context.Context, w indexer.Worker, filePath string, chunks []model.SemanticChunk) {
+			enrich.Hover(ctx, w, filePath, chunks)
+		})
+	}
+	if cfg.calls {
+		fns = append(fns, func(ctx context.Context, w indexer.Worker, filePath string, chunks []model.SemanticChunk) {
+			enrich.CallGraph(ctx, w, filePath, chunks)
+		})
+	}
+	if cfg.types {
+		fns = append(fns, func(ctx context.Context, w indexer.Worker, filePath string, chunks []model.SemanticChunk) {
+			enrich.TypeHierarchy(ctx, w, filePath, chunks)
+		})
+	}
+	if cfg.refs {
+		fns = append(fns, func(ctx context.Context, w indexer.Worker, filePath string, chunks []model.SemanticChunk) {
+			enrich.References(ctx, w, filePath, chunks, cfg.maxUsages)
+		})
+	}
+	if cfg.decls {
+		fns = append(fns, func(ctx context.Context, w indexer.Worker, filePath string, chunks []model.SemanticChunk) {
+			enrich.Declarations(ctx, w, filePath, chunks)
+		})
+	}
+	return fns
+}
+
+// backgroundIndexArg makes clangd index the whole project in the background
+const backgroundIndexArg = "--background-index"
+
+// indexStartGrace is how long a server gets to report index progress before
+// its index is taken as ready
+const indexStartGrace = 2 * time.Second
+
+// waitForIndex waits up to -index-timeout for the server's background index,
+// logging progress. Timing out is not fatal: symbols are still available,
+// only cross-file results may be incomplete.
+func waitForIndex(ctx context.Context, client *lsp.Client, cfg config) {
+	timeout := cfg.indexTimeout
+	if timeout <= 0 {
+		return
+	}
+
+	// A running server may have indexed the project before we connected,
+	// and a new one finds an index on disk that is up to date
+	if !client.IndexStarted(ctx, min(indexStartGrace, timeout)) {
+		log.Printf("✓ No index progress from the server, taking its index as ready")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := client.WaitForIndex(ctx, func(ev lsp.ProgressEvent) {
+		log.Printf("  ⏳ %s: %d%% %s", ev.Title, ev.Percentage, ev.Message)
+	})
+	if err != nil {
+		log.Printf("  ⚠️  Background index not ready after %v, continuing", timeout)
+		return
+	}
+	log.Printf("✓ Background index ready")
+}
+
+// runParallel discovers all C++ files under the root and processes them with
+// a pool of clangd workers. With inc set, only changed files are processed
+// and the chunks of the others are carried over.
+func runParallel(ctx context.Context, cfg config, spec lsp.ServerSpec, inc *incremental) ([]model.SemanticChunk, lsp.ServerInfo, error) {
+	server := lsp.ServerInfo{Name: spec.Name}
+
+	// Step 1: Discover C++ files
+	log.Println("\n→ Step 1: Discovering C++ files...")
+	files, err := discoverFiles(cfg)
+	if err != nil {
+		return nil, server, fmt.Errorf("failed to find C++ files: %w", err)
+	}
+	log.Printf("✓ Found %d C++ files to process", len(files))
+
+	all := files
+	if inc != nil {
+		for i, file := range files {
+			files[i] = fileuri.Normalize(file)
+		}
+		if files, err = inc.planFiles(cfg, all); err != nil {
+			return nil, server, fmt.Errorf("failed to plan incremental run: %w", err)
+		}
+		if len(files) == 0 {
+			log.Println("✓ Nothing changed")
+			return inc.combine(all, nil), inc.previousServer(cfg, server), nil
+		}
+	}
+
+	// Step 2: Start LSP workers
+	log.Printf("\n→ Step 2: Starting %d %s workers...", cfg.jobs, spec.Name)
+	var once sync.Once
+	var started atomic.Int32
+	factory := func(ctx context.Context) (indexer.Worker, error) {
+		// The first cfg.jobs calls start the pool, later ones restart a
+		// crashed worker
+		n := int(started.Add(1))
+		workerSpec := spec
+		if n > 1 && !cfg.crossFile() {
+			// Only cross-file enrichment needs an index in every worker
+			workerSpec.Args = slices.DeleteFunc(slices.Clone(spec.Args), func(arg string) bool {
+				return arg == backgroundIndexArg
+			})
+		}
+
+		client, err := cfg.startClient(ctx, workerSpec)
+		if err != nil {
+			return nil, err
+		}
+		once.Do(func() {
+			server = client.ServerInfo()
+			log.Printf("✓ Connected to %s", server)
+			cfg.warnUnsupported(client.Capabilities(), server.Name)
+		})
+		// A restarted worker picks up the index already written to disk
+		if n <= cfg.jobs && slices.Contains(workerSpec.Args, backgroundIndexArg) {
+			waitForIndex(ctx, client, cfg)
+		}
+		return client, nil
+	}
+
+	// Step 3: Parse symbols and create chunks
+	log.Println("\n→ Step 3: Parsing document symbols...")
+	done := 0
+	result, err := indexer.Run(ctx, files, indexer.Options{
+		Jobs:           cfg.jobs,
+		Factory:        factory,
+		Enrich:         cfg.enrichers(),
+		Parse:          cfg.parseOptions(),
+		MaxCrashes:     cfg.maxCrashes,
+		RestartBackoff: cfg.restartBackoff,
+		FileTimeout:    cfg.fileTimeout,
+		Progress: func(r indexer.FileResult) {
+			done++
+			if r.Err != nil {
+				if inc != nil {
+					inc.failed[r.File] = true
+				}
+				log.Printf("  [%d/%d] worker %d: ⚠️  %s: %v", done, len(files), r.Worker, r.File, r.Err)
+				return
+			}
+			log.Printf("  [%d/%d] worker %d: %s (%d chunks)", done, len(files), r.Worker, r.File, len(r.Chunks))
+		},
+	})
+	if ctx.Err() != nil {
+		return nil, server, ctx.Err()
+	}
+	if err != nil {
+		return nil, server, fmt.Errorf("failed to start LSP workers: %w", err)
+	}
+
+	log.Printf("\n✓ Processed %d files successfully (%d errors)", result.SuccessCount, result.ErrorCount)
+	for _, w := range result.Workers {
+		log.Printf("  worker %d: %d files, %d chunks, %d errors, %d restarts", w.ID, w.Files, w.Chunks, w.Errors, w.Restarts)
+	}
+
+	if len(result.Quarantined) > 0 {
+		log.Printf("\n⚠️  Quarantined %d files that repeatedly crashed %s:", len(result.Quarantined), spec.Name)
+		for _, q := range result.Quarantined {
+			log.Printf("  %s (%d crashes): %s", q.File, q.Crashes, q.Reason)
+		}
+	}
+
+	if inc != nil {
+		return inc.combine(all, result.Chunks), server, nil
+	}
+	return result.Chunks, server, nil
 }
@@ -0,0 +1,835 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"clangd-parser/internal/fileuri"
+	"clangd-parser/internal/lsp"
+	"clangd-parser/internal/lsp/lsptest"
+	"clangd-parser/internal/manifest"
+	"clangd-parser/internal/model"
+	"clangd-parser/internal/output"
+)
+
+// fakeClients returns a newClient func that connects every client to its own
+// in-process fake server running script
+func fakeClients(t *testing.T, script lsptest.Script) func(context.Context, lsp.ServerSpec, string) (*lsp.Client, error) {
+	return func(ctx context.Context, spec lsp.ServerSpec, rootPath string) (*lsp.Client, error) {
+		server, rwc := lsptest.Start(script)
+		t.Cleanup(func() { server.Close() })
+		return lsp.NewClientFromStream(ctx, rwc, spec, rootPath)
+	}
+}
+
+func writeProject(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+	return root
+}
+
+func readChunks(t *testing.T, path string) []model.SemanticChunk {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	var chunks []model.SemanticChunk
+	if err := json.Unmarshal(data, &chunks); err != nil {
+		t.Fatalf("Failed to parse output: %v", err)
+	}
+	return chunks
+}
+
+func functionSymbol(name string, line int) lsp.DocumentSymbol {
+	return lsp.DocumentSymbol{
+		Name:   name,
+		Detail: "void ()",
+		Kind:   lsp.SymbolKindFunction,
+		Range: lsp.Range{
+			Start: lsp.Position{Line: line},
+			End:   lsp.Position{Line: line},
+		},
+	}
+}
+
+func TestRunPipeline(t *testing.T) {
+	root := writeProject(t, map[string]string{
+		"a.cpp":        "/// Does a\nvoid a() {}\n",
+		"src/b.cpp":    "void b() {}\n",
+		"src/slow.cpp": "void slow() {}\n",
+		"src/bad.cpp":  "void bad() {}\n",
+		"src/dies.cpp": "void dies() {}\n",
+	})
+
+	script := lsptest.Script{
+		Symbols: map[string][]lsp.DocumentSymbol{
+			filepath.Join(root, "a.cpp"):        {functionSymbol("a", 1)},
+			filepath.Join(root, "src/b.cpp"):    {functionSymbol("b", 0)},
+			filepath.Join(root, "src/slow.cpp"): {functionSymbol("slow", 0)},
+		},
+		Errors: map[string]string{
+			"textDocument/documentSymbol " + filepath.Join(root, "src/bad.cpp"): "invalid AST",
+		},
+		Crashes: map[string]bool{
+			"textDocument/documentSymbol " + filepath.Join(root, "src/dies.cpp"): true,
+		},
+		Diagnostics: map[string][]lsp.Diagnostic{
+			filepath.Join(root, "src/b.cpp"): {
+				{Severity: lsp.DiagnosticSeverityError, Message: "expected ';'"},
+			},
+		},
+		Delays: map[string]time.Duration{
+			"textDocument/documentSymbol " + filepath.Join(root, "src/slow.cpp"): time.Minute,
+		},
+	}
+
+	outputFile := filepath.Join(t.TempDir(), "chunks.json")
+	err := run(context.Background(), config{
+		server:             "clangd",
+		rootPath:           root,
+		outputFile:         outputFile,
+		jobs:               2,
+		requestTimeout:     200 * time.Millisecond,
+		diagnosticsTimeout: time.Second,
+		restartBackoff:     time.Millisecond,
+		newClient:          fakeClients(t, script),
+	})
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	chunks := readChunks(t, outputFile)
+	if len(chunks) != 2 {
+		t.Fatalf("Expected 2 chunks (error, timeout and crashing files dropped), got %d", len(chunks))
+	}
+	if chunks[0].Name != "a" || chunks[1].Name != "b" {
+		t.Errorf("Expected chunks a, b in discovery order, got %s, %s", chunks[0].Name, chunks[1].Name)
+	}
+	if chunks[0].Docstring != "Does a" {
+		t.Errorf("Expected docstring 'Does a', got %q", chunks[0].Docstring)
+	}
+	if chunks[0].ParseQuality != model.ParseQualityClean || chunks[1].ParseQuality != model.ParseQualityErrors {
+		t.Errorf("Unexpected parse quality: a=%q b=%q", chunks[0].ParseQuality, chunks[1].ParseQuality)
+	}
+	if chunks[1].Diagnostics == nil || chunks[1].Diagnostics.Errors != 1 {
+		t.Errorf("Expected one error attached to b, got %+v", chunks[1].Diagnostics)
+	}
+
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(outputFile), "chunks.meta.json"))
+	if err != nil {
+		t.Fatalf("Failed to read index info: %v", err)
+	}
+	var info model.IndexInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		t.Fatalf("Failed to parse index info: %v", err)
+	}
+	if info.Server != "fake-clangd" || info.ServerVersion != "0.0.0" || info.Chunks != 2 {
+		t.Errorf("Unexpected index info: %+v", info)
+	}
+}
+
+func TestRunMergesDeclarations(t *testing.T) {
+	root := writeProject(t, map[string]string{
+		"lib.h":   "/// Adds one\nint inc(int x);\n",
+		"lib.cpp": "#include \"lib.h\"\nint inc(int x) { return x + 1; }\n",
+	})
+	header, source := filepath.Join(root, "lib.h"), filepath.Join(root, "lib.cpp")
+	at := func(path string) []lsp.Location {
+		return []lsp.Location{{URI: fileuri.FromPath(path)}}
+	}
+
+	// functionSymbol has no selection range, so names sit at 0:0
+	script := lsptest.Script{
+		Symbols: map[string][]lsp.DocumentSymbol{
+			header: {functionSymbol("inc", 1)},
+			source: {functionSymbol("inc", 1)},
+		},
+		Definitions: map[string]map[string][]lsp.Location{
+			header: {"0:0": at(source)},
+			source: {"0:0": at(source)},
+		},
+		Declarations: map[string]map[string][]lsp.Location{
+			source: {"0:0": at(header)},
+		},
+	}
+
+	outputFile := filepath.Join(t.TempDir(), "chunks.json")
+	err := run(context.Background(), config{
+		server:     "clangd",
+		rootPath:   root,
+		outputFile: outputFile,
+		jobs:       2,
+		decls:      true,
+		newClient:  fakeClients(t, script),
+	})
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	chunks := readChunks(t, outputFile)
+	if len(chunks) != 1 {
+		t.Fatalf("Expected the declaration merged into the definition, got %+v", chunks)
+	}
+	inc := chunks[0]
+	if inc.Context.FilePath != source || inc.Docstring != "Adds one" {
+		t.Errorf("Expected the definition with the header docstring, got %q in %s", inc.Docstring, inc.Context.FilePath)
+	}
+	if inc.Declaration == nil || inc.Declaration.FilePath != header {
+		t.Errorf("Expected a link to the header declaration, got %+v", inc.Declaration)
+	}
+}
+
+func TestRunSplitsOversizedChunks(t *testing.T) {
+	root := writeProject(t, map[string]string{
+		"long.cpp": "void long_one() {\n  step_one();\n  step_two();\n  step_three();\n}\n",
+	})
+	source := filepath.Join(root, "long.cpp")
+
+	symbol := functionSymbol("long_one", 0)
+	symbol.Range.End.Line = 4
+	script := lsptest.Script{Symbols: map[string][]lsp.DocumentSymbol{source: {symbol}}}
+
+	outputFile := filepath.Join(t.TempDir(), "chunks.json")
+	err := run(context.Background(), config{
+		server:     "clangd",
+		rootPath:   root,
+		outputFile: outputFile,
+		jobs:       1,
+		maxTokens:  16,
+		newClient:  fakeClients(t, script),
+	})
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	chunks := readChunks(t, outputFile)
+	if len(chunks) < 3 || len(chunks[0].Parts) != len(chunks)-1 {
+		t.Fatalf("Expected the function followed by its parts, got %+v", chunks)
+	}
+	for _, part := range chunks[1:] {
+		if part.Parent == nil || part.Parent.ID != chunks[0].ID || part.Header != "void long_one() {" {
+			t.Errorf("Expected part %d to link to its parent, got %+v", part.Part, part.Parent)
+		}
+	}
+	t.Logf("✓ Split into %d parts", len(chunks)-1)
+}
+
+func TestRunGroupsEnrichedChunks(t *testing.T) {
+	root := writeProject(t, map[string]string{
+		"lib.h":    "/// Adds one\nint inc(int x);\nint dec(int x);\n",
+		"lib.cpp":  "int inc(int x) { return x + 1; }\nint dec(int x) { return x - 1; }\n",
+		"util.cpp": "int one() { return 1; }\nint two() { return 2; }\n",
+		"use.cpp":  "int use() { return one() + two(); }\n",
+	})
+	header, source := filepath.Join(root, "lib.h"), filepath.Join(root, "lib.cpp")
+	util, use := filepath.Join(root, "util.cpp"), filepath.Join(root, "use.cpp")
+
+	// Names sit at the start of their line
+	named := func(name string, line int) lsp.DocumentSymbol {
+		symbol := functionSymbol(name, line)
+		symbol.SelectionRange = symbol.Range
+		return symbol
+	}
+	at := func(path string, line int) []lsp.Location {
+		return []lsp.Location{{URI: fileuri.FromPath(path), Range: lsp.Range{Start: lsp.Position{Line: line}}}}
+	}
+	item := func(name, path string, line int) lsp.CallHierarchyItem {
+		rng := lsp.Range{Start: lsp.Position{Line: line}, End: lsp.Position{Line: line}}
+		return lsp.CallHierarchyItem{Name: name, Kind: lsp.SymbolKindFunction, URI: fileuri.FromPath(path), Range: rng, SelectionRange: rng}
+	}
+
+	script := lsptest.Script{
+		Symbols: map[string][]lsp.DocumentSymbol{
+			header: {named("inc", 1), named("dec", 2)},
+			source: {named("inc", 0), named("dec", 1)},
+			util:   {named("one", 0), named("two", 1)},
+			use:    {named("use", 0)},
+		},
+		Definitions: map[string]map[string][]lsp.Location{
+			header: {"1:0": at(source, 0), "2:0": at(source, 1)},
+			source: {"0:0": at(source, 0), "1:0": at(source, 1)},
+		},
+		Declarations: map[string]map[string][]lsp.Location{
+			source: {"0:0": at(header, 1), "1:0": at(header, 2)},
+		},
+		CallHierarchy: map[string]map[string][]lsp.CallHierarchyItem{
+			util: {"0:0": {item("one", util, 0)}, "1:0": {item("two", util, 1)}},
+			use:  {"0:0": {item("use", use, 0)}},
+		},
+		OutgoingCalls: map[string][]lsp.CallHierarchyOutgoingCall{
+			"use": {{To: item("one", util, 0)}, {To: item("two", util, 1)}},
+		},
+		IncomingCalls: map[string][]lsp.CallHierarchyIncomingCall{
+			"one": {{From: item("use", use, 0)}},
+			"two": {{From: item("use", use, 0)}},
+		},
+	}
+
+	outputFile := filepath.Join(t.TempDir(), "chunks.json")
+	err := run(context.Background(), config{
+		server:     "clangd",
+		rootPath:   root,
+		outputFile: outputFile,
+		jobs:       1,
+		calls:      true,
+		decls:      true,
+		minTokens:  64,
+		newClient:  fakeClients(t, script),
+	})
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	byName := make(map[string]model.SemanticChunk)
+	for _, c := range readChunks(t, outputFile) {
+		byName[c.Name] = c
+	}
+
+	// Declarations stay out of groups so they can be merged
+	if group, ok := byName["inc, dec"]; ok {
+		t.Errorf("Expected linked declarations to stay ungrouped, got %+v", group.Members)
+	}
+	if inc := byName["inc"]; inc.Context.FilePath != source || inc.Docstring != "Adds one" {
+		t.Errorf("Expected inc merged with its header declaration, got %q in %s", inc.Docstring, inc.Context.FilePath)
+	}
+
+	group, ok := byName["one, two"]
+	if !ok {
+		t.Fatalf("Expected one and two grouped, got %v", byName)
+	}
+	if len(group.CalledBy) != 1 || group.CalledBy[0].Name != "use" {
+		t.Errorf("Expected the group to keep its members' callers, got %+v", group.CalledBy)
+	}
+	calls := byName["use"].Calls
+	if len(calls) != 1 || calls[0].ID != group.ID {
+		t.Errorf("Expected calls into the group to point at it, got %+v", calls)
+	}
+	t.Logf("✓ Grouped %s after enrichment", group.Name)
+}
+
+func TestRunConnect(t *testing.T) {
+	root := writeProject(t, map[string]string{
+		"a.cpp": "void a() {}\n",
+		"b.cpp": "void b() {}\n",
+	})
+	server := lsptest.NewServer(lsptest.Script{
+		Symbols: map[string][]lsp.DocumentSymbol{
+			filepath.Join(root, "a.cpp"): {functionSymbol("a", 0)},
+			filepath.Join(root, "b.cpp"): {functionSymbol("b", 0)},
+		},
+	})
+
+	// One warm server behind a socket, which accepts a single session
+	socket := filepath.Join(t.TempDir(), "clangd.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() {
+		ln.Close()
+		server.Close()
+	})
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			server.Serve(conn)
+		}
+	}()
+
+	outputFile := filepath.Join(t.TempDir(), "chunks.json")
+	err = run(context.Background(), config{
+		server:       "clangd",
+		rootPath:     root,
+		outputFile:   outputFile,
+		jobs:         4,
+		connect:      "unix://" + socket,
+		indexTimeout: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	if chunks := readChunks(t, outputFile); len(chunks) != 2 {
+		t.Errorf("Expected 2 chunks, got %+v", chunks)
+	}
+	sessions := 0
+	for _, method := range server.Received() {
+		if method == "initialize" {
+			sessions++
+		}
+	}
+	if sessions != 1 {
+		t.Errorf("Expected a single session on the server, got %d", sessions)
+	}
+	t.Logf("✓ Indexed over %s", socket)
+}
+
+func TestRunBackgroundIndexWorkers(t *testing.T) {
+	root := writeProject(t, map[string]string{
+		"a.cpp":    "void a() {}\n",
+		"b.cpp":    "void b() {}\n",
+		"dies.cpp": "void dies() {}\n",
+	})
+	script := lsptest.Script{
+		Symbols: map[string][]lsp.DocumentSymbol{
+			filepath.Join(root, "a.cpp"): {functionSymbol("a", 0)},
+			filepath.Join(root, "b.cpp"): {functionSymbol("b", 0)},
+		},
+		Crashes: map[string]bool{
+			"textDocument/documentSymbol " + filepath.Join(root, "dies.cpp"): true,
+		},
+		Notifications: lsptest.IndexProgress(50, 100),
+	}
+
+	tests := []struct {
+		name      string
+		calls     bool
+		wantIndex int // starts with the background index
+	}{
+		{"symbols only", false, 1},
+		{"cross-file", true, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const jobs = 2
+			var mu sync.Mutex
+			var starts, indexed int
+			newClient := func(ctx context.Context, spec lsp.ServerSpec, rootPath string) (*lsp.Client, error) {
+				mu.Lock()
+				starts++
+				restart := starts > jobs
+				if slices.Contains(spec.Args, backgroundIndexArg) {
+					indexed++
+				}
+				mu.Unlock()
+
+				// A restarted server reports no progress, so waiting on it
+				// would take the whole -index-timeout
+				s := script
+				if restart {
+					s.Notifications = nil
+				}
+				return fakeClients(t, s)(ctx, spec, rootPath)
+			}
+
+			start := time.Now()
+			err := run(context.Background(), config{
+				server:         "clangd",
+				rootPath:       root,
+				outputFile:     filepath.Join(t.TempDir(), "chunks.json"),
+				jobs:           jobs,
+				calls:          tt.calls,
+				indexTimeout:   30 * time.Second,
+				maxCrashes:     2,
+				restartBackoff: time.Millisecond,
+				newClient:      newClient,
+			})
+			if err != nil {
+				t.Fatalf("run failed: %v", err)
+			}
+
+			if starts != jobs+1 {
+				t.Errorf("Expected %d workers and a restart, got %d starts", jobs, starts)
+			}
+			if indexed != tt.wantIndex {
+				t.Errorf("Expected %d starts with %s, got %d", tt.wantIndex, backgroundIndexArg, indexed)
+			}
+			if elapsed := time.Since(start); elapsed > 10*time.Second {
+				t.Errorf("Expected the restart not to wait for the index, took %v", elapsed)
+			}
+			t.Logf("✓ Background index in %d of %d starts", indexed, starts)
+		})
+	}
+}
+
+func TestRunIndexUpToDate(t *testing.T) {
+	root := writeProject(t, map[string]string{
+		"a.cpp": "void a() {}\n",
+		"b.cpp": "void b() {}\n",
+	})
+	// No index progress, as from clangd with an up to date index on disk
+	script := lsptest.Script{
+		Symbols: map[string][]lsp.DocumentSymbol{
+			filepath.Join(root, "a.cpp"): {functionSymbol("a", 0)},
+			filepath.Join(root, "b.cpp"): {functionSymbol("b", 0)},
+		},
+	}
+
+	start := time.Now()
+	err := run(context.Background(), config{
+		server:       "clangd",
+		rootPath:     root,
+		outputFile:   filepath.Join(t.TempDir(), "chunks.json"),
+		jobs:         2,
+		calls:        true,
+		indexTimeout: time.Minute,
+		newClient:    fakeClients(t, script),
+	})
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed < indexStartGrace || elapsed > indexStartGrace+10*time.Second {
+		t.Errorf("Expected to wait out the %v grace only, took %v", indexStartGrace, elapsed)
+	}
+	t.Logf("✓ Took the index as ready after %v", time.Since(start).Round(time.Millisecond))
+}
+
+func TestRunCompileDBDiscovery(t *testing.T) {
+	root := writeProject(t, map[string]string{
+		"src/a.cpp":      "#include \"a.h\"\nvoid a() {}\n",
+		"src/a.h":        "void a();\n",
+		"tools/skip.cpp": "void skip() {}\n",
+	})
+	entry := `[{"directory": "` + filepath.Join(root, "src") + `", "file": "a.cpp", "command": "c++ -c a.cpp"}]`
+	if err := os.WriteFile(filepath.Join(root, "compile_commands.json"), []byte(entry), 0644); err != nil {
+		t.Fatalf("Failed to write compile database: %v", err)
+	}
+
+	script := lsptest.Script{
+		Symbols: map[string][]lsp.DocumentSymbol{
+			filepath.Join(root, "src/a.cpp"):      {functionSymbol("a", 1)},
+			filepath.Join(root, "src/a.h"):        {functionSymbol("a_decl", 0)},
+			filepath.Join(root, "tools/skip.cpp"): {functionSymbol("skip", 0)},
+		},
+	}
+
+	outputFile := filepath.Join(t.TempDir(), "chunks.json")
+	err := run(context.Background(), config{
+		server:     "clangd",
+		rootPath:   root,
+		compileDB:  root,
+		discover:   "compile-db",
+		outputFile: outputFile,
+		jobs:       1,
+		newClient:  fakeClients(t, script),
+	})
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	chunks := readChunks(t, outputFile)
+	if len(chunks) != 2 || chunks[0].Name != "a" || chunks[1].Name != "a_decl" {
+		t.Fatalf("Expected the unit and its header only, got %+v", chunks)
+	}
+}
+
+func TestRunIncremental(t *testing.T) {
+	root := writeProject(t, map[string]string{
+		"same.cpp":    "void same() {}\n",
+		"edited.cpp":  "void edited() {}\n",
+		"removed.cpp": "void removed() {}\n",
+	})
+	outputFile := filepath.Join(t.TempDir(), "chunks.json")
+
+	index := func(symbols map[string][]lsp.DocumentSymbol) []model.SemanticChunk {
+		t.Helper()
+		err := run(context.Background(), config{
+			server:      "clangd",
+			rootPath:    root,
+			outputFile:  outputFile,
+			jobs:        1,
+			incremental: true,
+			newClient:   fakeClients(t, lsptest.Script{Symbols: symbols}),
+		})
+		if err != nil {
+			t.Fatalf("run failed: %v", err)
+		}
+		return readChunks(t, outputFile)
+	}
+
+	index(map[string][]lsp.DocumentSymbol{
+		filepath.Join(root, "same.cpp"):    {functionSymbol("same", 0)},
+		filepath.Join(root, "edited.cpp"):  {functionSymbol("edited", 0)},
+		filepath.Join(root, "removed.cpp"): {functionSymbol("removed", 0)},
+	})
+	if _, err := os.Stat(output.ManifestPath(outputFile)); err != nil {
+		t.Fatalf("Expected a manifest after the first run: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "edited.cpp"), []byte("void edited2() {}\n"), 0644); err != nil {
+		t.Fatalf("Failed to edit file: %v", err)
+	}
+	if err := os.Remove(filepath.Join(root, "removed.cpp")); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "added.cpp"), []byte("void added() {}\n"), 0644); err != nil {
+		t.Fatalf("Failed to add file: %v", err)
+	}
+
+	// same.cpp would come back renamed if it were parsed again
+	chunks := index(map[string][]lsp.DocumentSymbol{
+		filepath.Join(root, "same.cpp"):   {functionSymbol("reparsed", 0)},
+		filepath.Join(root, "edited.cpp"): {functionSymbol("edited2", 0)},
+		filepath.Join(root, "added.cpp"):  {functionSymbol("added", 0)},
+	})
+
+	var names []string
+	for _, c := range chunks {
+		names = append(names, c.Name)
+	}
+	sort.Strings(names)
+	if want := []string{"added", "edited2", "same"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("Got chunks %q, expected %q", names, want)
+	}
+
+	m, err := manifest.Load(output.ManifestPath(outputFile))
+	if err != nil || m == nil {
+		t.Fatalf("Failed to load manifest: %v", err)
+	}
+	if _, ok := m.Files[filepath.Join(root, "removed.cpp")]; ok || len(m.Files) != 3 {
+		t.Errorf("Expected the deleted file to leave the manifest, got %d entries", len(m.Files))
+	}
+
+	// Nothing changed: no server is started, but the index info keeps it
+	if chunks := index(nil); len(chunks) != 3 {
+		t.Errorf("Expected all 3 chunks carried over, got %d", len(chunks))
+	}
+	info, err := output.ReadIndexInfo(output.MetaPath(outputFile))
+	if err != nil || info.Server != "fake-clangd" || info.ServerVersion != "0.0.0" {
+		t.Errorf("Expected the previous server in the index info, got %+v (%v)", info, err)
+	}
+	t.Logf("✓ Carried over 1 file, parsed 2")
+}
+
+func TestRunIncrementalDeclarations(t *testing.T) {
+	root := writeProject(t, map[string]string{
+		"lib.h":     "/// Adds one\nint inc(int x);\n",
+		"lib.cpp":   "#include \"lib.h\"\nint inc(int x) { return x + 1; }\n",
+		"other.cpp": "void other() {}\n",
+	})
+	header, source := filepath.Join(root, "lib.h"), filepath.Join(root, "lib.cpp")
+	at := func(path string) []lsp.Location {
+		return []lsp.Location{{URI: fileuri.FromPath(path)}}
+	}
+	script := lsptest.Script{
+		Symbols: map[string][]lsp.DocumentSymbol{
+			header:                           {functionSymbol("inc", 1)},
+			source:                           {functionSymbol("inc", 1)},
+			filepath.Join(root, "other.cpp"): {functionSymbol("other", 0)},
+		},
+		Definitions: map[string]map[string][]lsp.Location{
+			header: {"0:0": at(source)},
+			source: {"0:0": at(source)},
+		},
+		Declarations: map[string]map[string][]lsp.Location{
+			source: {"0:0": at(header)},
+		},
+	}
+	outputFile := filepath.Join(t.TempDir(), "chunks.json")
+
+	index := func() model.SemanticChunk {
+		t.Helper()
+		err := run(context.Background(), config{
+			server:      "clangd",
+			rootPath:    root,
+			outputFile:  outputFile,
+			jobs:        1,
+			decls:       true,
+			incremental: true,
+			newClient:   fakeClients(t, script),
+		})
+		if err != nil {
+			t.Fatalf("run failed: %v", err)
+		}
+		for _, c := range readChunks(t, outputFile) {
+			if c.Name == "inc" {
+				return c
+			}
+		}
+		t.Fatal("Expected a chunk for inc")
+		return model.SemanticChunk{}
+	}
+
+	index()
+
+	// Only the definition changes: the declaration is parsed again to be
+	// merged into it
+	if err := os.WriteFile(source, []byte("#include \"lib.h\"\nint inc(int x) { return 1 + x; }\n"), 0644); err != nil {
+		t.Fatalf("Failed to edit file: %v", err)
+	}
+	inc := index()
+	if inc.Context.FilePath != source || inc.Docstring != "Adds one" || inc.Declaration == nil {
+		t.Errorf("Expected the header declaration merged after editing the source, got %q %+v", inc.Docstring, inc.Declaration)
+	}
+
+	// Only the declaration changes: the carried over definition would keep
+	// the old docstring
+	if err := os.WriteFile(header, []byte("/// Increments\nint inc(int x);\n"), 0644); err != nil {
+		t.Fatalf("Failed to edit file: %v", err)
+	}
+	if inc := index(); inc.Docstring != "Increments" {
+		t.Errorf("Expected the new header docstring after editing the header, got %q", inc.Docstring)
+	}
+	t.Logf("✓ Kept the declaration merged across incremental runs")
+}
+
+func TestRunTestFile(t *testing.T) {
+	root := writeProject(t, map[string]string{
+		"only.cpp":  "void only() {}\n",
+		"other.cpp": "void other() {}\n",
+	})
+	file := filepath.Join(root, "only.cpp")
+
+	script := lsptest.Script{
+		Symbols: map[string][]lsp.DocumentSymbol{
+			file: {functionSymbol("only", 0)},
+		},
+		Notifications: lsptest.IndexProgress(50),
+	}
+
+	outputFile := filepath.Join(t.TempDir(), "chunks.json")
+	err := run(context.Background(), config{
+		server:       "clangd",
+		rootPath:     root,
+		outputFile:   outputFile,
+		testFile:     file,
+		compact:      true,
+		indexTimeout: 5 * time.Second,
+		newClient:    fakeClients(t, script),
+	})
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	chunks := readChunks(t, outputFile)
+	if len(chunks) != 1 || chunks[0].Name != "only" {
+		t.Fatalf("Expected only the test file's chunk, got %+v", chunks)
+	}
+}
+
+func TestRunRecordReplay(t *testing.T) {
+	root := writeProject(t, map[string]string{"a.cpp": "void a() {}\nvoid b() {}\n"})
+	file := filepath.Join(root, "a.cpp")
+
+	script := lsptest.Script{
+		Symbols: map[string][]lsp.DocumentSymbol{
+			file: {functionSymbol("a", 0), functionSymbol("b", 1)},
+		},
+	}
+
+	dir := t.TempDir()
+	recorded := filepath.Join(dir, "recorded.json")
+	transcriptPath := filepath.Join(dir, "session.jsonl")
+	err := run(context.Background(), config{
+		server:     "clangd",
+		rootPath:   root,
+		outputFile: recorded,
+		testFile:   file,
+		record:     transcriptPath,
+		newClient:  fakeClients(t, script),
+	})
+	if err != nil {
+		t.Fatalf("Recording run failed: %v", err)
+	}
+
+	// No fake server this time: everything comes from the transcript
+	replayed := filepath.Join(dir, "replayed.json")
+	err = run(context.Background(), config{
+		server:     "clangd",
+		rootPath:   root,
+		outputFile: replayed,
+		testFile:   file,
+		replay:     transcriptPath,
+	})
+	if err != nil {
+		t.Fatalf("Replay run failed: %v", err)
+	}
+
+	want, got := readChunks(t, recorded), readChunks(t, replayed)
+	if len(got) != 2 || !reflect.DeepEqual(got, want) {
+		t.Fatalf("Replay produced %+v, recording produced %+v", got, want)
+	}
+
+	// Hover requests weren't recorded, so this session doesn't match
+	diverged := filepath.Join(dir, "diverged.json")
+	err = run(context.Background(), config{
+		server:     "clangd",
+		rootPath:   root,
+		outputFile: diverged,
+		testFile:   file,
+		hover:      true,
+		replay:     transcriptPath,
+	})
+	if err == nil || !strings.Contains(err.Error(), "diverged") {
+		t.Fatalf("Expected the replay to fail on a session that doesn't match, got %v", err)
+	}
+	if _, statErr := os.Stat(diverged); !os.IsNotExist(statErr) {
+		t.Errorf("Expected no output from a diverged replay, got %v", statErr)
+	}
+	t.Logf("✓ Replayed %d chunks without a language server; %v", len(got), err)
+}
+
+func TestRunServerStartFailure(t *testing.T) {
+	root := writeProject(t, map[string]string{"a.cpp": "void a() {}\n"})
+
+	script := lsptest.Script{
+		Errors: map[string]string{"initialize": "cannot start"},
+	}
+
+	err := run(context.Background(), config{
+		server:     "clangd",
+		rootPath:   root,
+		outputFile: filepath.Join(t.TempDir(), "chunks.json"),
+		jobs:       1,
+		newClient:  fakeClients(t, script),
+	})
+	if err == nil {
+		t.Fatal("Expected run to fail when the server cannot initialize")
+	}
+}
+
+func TestRunInterrupted(t *testing.T) {
+	root := writeProject(t, map[string]string{"a.cpp": "void a() {}\n"})
+
+	script := lsptest.Script{
+		Delays: map[string]time.Duration{"textDocument/documentSymbol": time.Minute},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	outputFile := filepath.Join(t.TempDir(), "chunks.json")
+	start := time.Now()
+	err := run(ctx, config{
+		server:     "clangd",
+		rootPath:   root,
+		outputFile: outputFile,
+		jobs:       1,
+		newClient:  fakeClients(t, script),
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected run to be interrupted, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Interrupt took too long: %v", elapsed)
+	}
+	if _, err := os.Stat(outputFile); err == nil {
+		t.Error("Expected no output after an interrupted run")
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"clangd-parser/internal/lsp"
+	"clangd-parser/internal/lsp/transcript"
+)
+
+// recorder hands out one transcript file per started client. The first
+// client writes to path itself, later ones (other workers and restarts) to
+// path with ".N" inserted before the extension.
+type recorder struct {
+	path string
+
+	mu    sync.Mutex
+	files []*os.File
+}
+
+// create opens the transcript file for the next client
+func (r *recorder) create() (*os.File, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	path := r.path
+	if n := len(r.files); n > 0 {
+		ext := filepath.Ext(path)
+		path = fmt.Sprintf("%s.%d%s", strings.TrimSuffix(path, ext), n, ext)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("create transcript: %w", err)
+	}
+	r.files = append(r.files, f)
+	return f, nil
+}
+
+// close closes all transcript files and returns their paths
+func (r *recorder) close() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	paths := make([]string, len(r.files))
+	for i, f := range r.files {
+		f.Close()
+		paths[i] = f.Name()
+	}
+	r.files = nil
+	return paths
+}
+
+// replayer replays the transcript at path instead of starting a language
+// server. Every client replays the whole session from the start.
+type replayer struct {
+	path    string
+	entries []transcript.Entry
+
+	mu        sync.Mutex
+	replayers []*transcript.Replayer
+}
+
+// newReplayer loads the transcript at path
+func newReplayer(path string) (*replayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open transcript: %w", err)
+	}
+	defer f.Close()
+
+	entries, err := transcript.Load(f)
+	if err != nil {
+		return nil, err
+	}
+	return &replayer{path: path, entries: entries}, nil
+}
+
+// newClient connects a client to a new replay of the transcript
+func (r *replayer) newClient(ctx context.Context, spec lsp.ServerSpec, rootPath string) (*lsp.Client, error) {
+	rep, rwc := transcript.Replay(r.entries)
+
+	r.mu.Lock()
+	r.replayers = append(r.replayers, rep)
+	r.mu.Unlock()
+
+	client, err := lsp.NewClientFromStream(ctx, rwc, spec, rootPath)
+	if err != nil {
+		if rerr := rep.Err(); rerr != nil {
+			return nil, fmt.Errorf("%w (replay: %v)", err, rerr)
+		}
+		return nil, err
+	}
+	return client, nil
+}
+
+// close stops all replays and returns the first point where a client
+// diverged from the transcript, since the session's results can't be
+// trusted from there on
+func (r *replayer) close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var first error
+	for _, rep := range r.replayers {
+		rep.Close()
+		if err := rep.Err(); err != nil && first == nil {
+			first = fmt.Errorf("replay of %s diverged: %w", r.path, err)
+		}
+	}
+	r.replayers = nil
+	return first
+}
@@ -4,4 +4,6 @@ go 1.21.6
 
 require github.com/sourcegraph/jsonrpc2 v0.2.1
 
-require github.com/fatih/camelcase v1.0.0 // indirect
+require github.com/fatih/camelcase v1.0.0
+
+require github.com/bmatcuk/doublestar/v4 v4.10.0
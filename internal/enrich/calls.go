@@ -0,0 +1,66 @@
+package enrich
+
+import (
+	"context"
+
+	"clangd-parser/internal/lsp"
+	"clangd-parser/internal/model"
+)
+
+// CallHierarchySource is the part of lsp.Client call graph extraction needs
+type CallHierarchySource interface {
+	PrepareCallHierarchy(ctx context.Context, filePath string, pos lsp.Position) ([]lsp.CallHierarchyItem, error)
+	IncomingCalls(ctx context.Context, item lsp.CallHierarchyItem) ([]lsp.CallHierarchyIncomingCall, error)
+	OutgoingCalls(ctx context.Context, item lsp.CallHierarchyItem) ([]lsp.CallHierarchyOutgoingCall, error)
+	// PositionEncoding is the encoding of the positions the server reports
+	PositionEncoding() lsp.PositionEncoding
+}
+
+// callableTypes are the chunk types that take part in the call graph
+var callableTypes = map[string]bool{
+	"Function":    true,
+	"Method":      true,
+	"Constructor": true,
+}
+
+// CallGraph records callers and callees of every function, method and
+// constructor chunk. The document must be open. Cross-file results are only
+// complete once the server's index is ready. It returns the number of chunks
+// with at least one edge.
+func CallGraph(ctx context.Context, src CallHierarchySource, filePath string, chunks []model.SemanticChunk) int {
+	cols := newColumns(src.PositionEncoding())
+	linked := 0
+
+	for i := range chunks {
+		chunk := &chunks[i]
+
+		pos, ok := cols.namePosition(*chunk)
+		if !callableTypes[chunk.CodeType] || !ok {
+			continue
+		}
+
+		items, err := src.PrepareCallHierarchy(ctx, filePath, pos)
+		if err != nil || len(items) == 0 {
+			continue
+		}
+		item := items[0]
+
+		if incoming, err := src.IncomingCalls(ctx, item); err == nil {
+			for _, call := range incoming {
+				chunk.CalledBy = appendRef(chunk.CalledBy, cols.ref(call.From.Name, call.From.URI, call.From.SelectionRange))
+			}
+		}
+
+		if outgoing, err := src.OutgoingCalls(ctx, item); err == nil {
+			for _, call := range outgoing {
+				chunk.Calls = appendRef(chunk.Calls, cols.ref(call.To.Name, call.To.URI, call.To.SelectionRange))
+			}
+		}
+
+		if len(chunk.Calls) > 0 || len(chunk.CalledBy) > 0 {
+			linked++
+		}
+	}
+
+	return linked
+}
@@ -0,0 +1,89 @@
+package enrich
+
+import (
+	"context"
+	"testing"
+
+	"clangd-parser/internal/fileuri"
+	"clangd-parser/internal/lsp"
+	"clangd-parser/internal/model"
+)
+
+// fakeCallHierarchy serves call hierarchy items by name position
+type fakeCallHierarchy struct {
+	items    map[lsp.Position]lsp.CallHierarchyItem
+	incoming map[string][]lsp.CallHierarchyIncomingCall
+	outgoing map[string][]lsp.CallHierarchyOutgoingCall
+}
+
+func (f fakeCallHierarchy) PrepareCallHierarchy(ctx context.Context, filePath string, pos lsp.Position) ([]lsp.CallHierarchyItem, error) {
+	if item, ok := f.items[pos]; ok {
+		return []lsp.CallHierarchyItem{item}, nil
+	}
+	return nil, nil
+}
+
+func (f fakeCallHierarchy) PositionEncoding() lsp.PositionEncoding {
+	return lsp.PositionEncodingUTF8
+}
+
+func (f fakeCallHierarchy) IncomingCalls(ctx context.Context, item lsp.CallHierarchyItem) ([]lsp.CallHierarchyIncomingCall, error) {
+	return f.incoming[item.Name], nil
+}
+
+func (f fakeCallHierarchy) OutgoingCalls(ctx context.Context, item lsp.CallHierarchyItem) ([]lsp.CallHierarchyOutgoingCall, error) {
+	return f.outgoing[item.Name], nil
+}
+
+func itemAt(name, path string, line, character int) lsp.CallHierarchyItem {
+	pos := lsp.Position{Line: line, Character: character}
+	return lsp.CallHierarchyItem{
+		Name:           name,
+		Kind:           lsp.SymbolKindFunction,
+		URI:            fileuri.FromPath(path),
+		SelectionRange: lsp.Range{Start: pos, End: pos},
+	}
+}
+
+func TestCallGraph(t *testing.T) {
+	chunks := []model.SemanticChunk{
+		{Name: "process", CodeType: "Function", NameLine: 5, NameColumn: 6},
+		{Name: "Config", CodeType: "Class", NameLine: 1, NameColumn: 7},
+	}
+
+	process := itemAt("process", "/src/a.cpp", 4, 5)
+	main := itemAt("main", "/src/main.cpp", 9, 4)
+	helper := itemAt("helper", "/src/util.cpp", 2, 5)
+
+	src := fakeCallHierarchy{
+		items: map[lsp.Position]lsp.CallHierarchyItem{
+			{Line: 4, Character: 5}: process,
+			{Line: 0, Character: 6}: itemAt("Config", "/src/a.cpp", 0, 6),
+		},
+		incoming: map[string][]lsp.CallHierarchyIncomingCall{
+			"process": {{From: main}, {From: main}},
+		},
+		outgoing: map[string][]lsp.CallHierarchyOutgoingCall{
+			"process": {{To: helper}},
+		},
+	}
+
+	if n := CallGraph(context.Background(), src, "/src/a.cpp", chunks); n != 1 {
+		t.Errorf("Expected 1 linked chunk, got %d", n)
+	}
+
+	fn := chunks[0]
+	if len(fn.CalledBy) != 1 {
+		t.Fatalf("Expected duplicate callers to collapse into 1, got %+v", fn.CalledBy)
+	}
+	if fn.CalledBy[0].ID != model.ChunkID("/src/main.cpp", 10, 5) || fn.CalledBy[0].Line != 10 {
+		t.Errorf("Unexpected caller ref: %+v", fn.CalledBy[0])
+	}
+	if len(fn.Calls) != 1 || fn.Calls[0].Name != "helper" || fn.Calls[0].FilePath != "/src/util.cpp" {
+		t.Errorf("Unexpected callee refs: %+v", fn.Calls)
+	}
+
+	if chunks[1].Calls != nil || chunks[1].CalledBy != nil {
+		t.Errorf("Class chunks should not get call edges: %+v", chunks[1])
+	}
+}
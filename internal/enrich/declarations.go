@@ -0,0 +1,150 @@
+package enrich
+
+import (
+	"context"
+
+	"clangd-parser/internal/lsp"
+	"clangd-parser/internal/model"
+)
+
+// DefinitionSource is the part of lsp.Client declaration pairing needs
+type DefinitionSource interface {
+	Definition(ctx context.Context, filePath string, pos lsp.Position) ([]lsp.Location, error)
+	Declaration(ctx context.Context, filePath string, pos lsp.Position) ([]lsp.Location, error)
+	// PositionEncoding is the encoding of the positions the server reports
+	PositionEncoding() lsp.PositionEncoding
+}
+
+// Declarations links function, method and constructor chunks to their
+// definition or declaration elsewhere, such as a method declared in a header
+// and defined in a source file. The document must be open. It returns the
+// number of chunks linked.
+func Declarations(ctx context.Context, src DefinitionSource, filePath string, chunks []model.SemanticChunk) int {
+	cols := newColumns(src.PositionEncoding())
+	linked := 0
+
+	for i := range chunks {
+		chunk := &chunks[i]
+
+		pos, ok := cols.namePosition(*chunk)
+		if !callableTypes[chunk.CodeType] || !ok {
+			continue
+		}
+
+		if locations, err := src.Definition(ctx, filePath, pos); err == nil {
+			chunk.Definition = otherLocation(cols, *chunk, locations)
+		}
+		if locations, err := src.Declaration(ctx, filePath, pos); err == nil {
+			chunk.Declaration = otherLocation(cols, *chunk, locations)
+		}
+
+		if chunk.Definition != nil || chunk.Declaration != nil {
+			linked++
+		}
+	}
+
+	return linked
+}
+
+// otherLocation returns a reference to the first location that isn't the
+// chunk's own name, or nil
+func otherLocation(cols *columns, chunk model.SemanticChunk, locations []lsp.Location) *model.ChunkRef {
+	for _, loc := range locations {
+		ref := cols.ref(chunk.Name, loc.URI, loc.Range)
+		if ref.ID != chunk.ID {
+			return &ref
+		}
+	}
+	return nil
+}
+
+// MergeDeclarations folds declaration chunks into the chunks of their
+// definitions. It runs over the chunks of a whole run after Declarations,
+// because declarations and definitions usually live in different files.
+//
+// The merged chunk keeps the definition's ID, position and snippet, takes
+// over the declaration's docstring, class and other details it lacks, and
+// points at the declaration with Declaration. References to the declaration
+// chunk are redirected to the merged one. Declarations whose definition
+// isn't among the chunks stay as they are, linked by Definition. It returns
+// the merged chunks and the number of declarations folded in.
+func MergeDeclarations(chunks []model.SemanticChunk) ([]model.SemanticChunk, int) {
+	byID := make(map[string]int, len(chunks))
+	for i, c := range chunks {
+		byID[c.ID] = i
+	}
+
+	merged := make(map[string]int) // declaration ID -> definition index
+	for i, decl := range chunks {
+		if decl.Definition == nil {
+			continue
+		}
+		d, ok := byID[decl.Definition.ID]
+		if !ok || d == i || chunks[d].Definition != nil {
+			continue
+		}
+		def := &chunks[d]
+		if def.Declaration != nil && def.Declaration.ID != decl.ID {
+			continue // the definition names a different declaration
+		}
+
+		mergeDeclaration(def, decl)
+		merged[decl.ID] = d
+	}
+
+	if len(merged) == 0 {
+		return chunks, 0
+	}
+
+	targets := make(map[string]model.SemanticChunk, len(merged))
+	for id, d := range merged {
+		targets[id] = chunks[d]
+	}
+
+	out := make([]model.SemanticChunk, 0, len(chunks)-len(merged))
+	for _, c := range chunks {
+		if _, ok := merged[c.ID]; !ok {
+			out = append(out, c)
+		}
+	}
+	redirectRefs(out, targets)
+
+	return out, len(merged)
+}
+
+// mergeDeclaration copies what the definition chunk lacks from its
+// declaration
+func mergeDeclaration(def *model.SemanticChunk, decl model.SemanticChunk) {
+	def.Declaration = &model.ChunkRef{
+		ID:       decl.ID,
+		Name:     decl.Name,
+		FilePath: decl.Context.FilePath,
+		Line:     decl.NameLine,
+	}
+
+	if def.Docstring == "" {
+		def.Docstring = decl.Docstring
+	}
+	if def.Doc == nil {
+		def.Doc = decl.Doc
+	}
+	if def.Context.StructName == "" {
+		def.Context.StructName = decl.Context.StructName
+	}
+	if def.SignatureInfo == nil {
+		def.SignatureInfo = decl.SignatureInfo
+	}
+	if def.Overrides == nil {
+		def.Overrides = decl.Overrides
+	}
+	if def.ReferenceCount < decl.ReferenceCount {
+		def.ReferenceCount = decl.ReferenceCount
+		def.Usages = decl.Usages
+	}
+	for _, ref := range decl.Calls {
+		def.Calls = appendRef(def.Calls, ref)
+	}
+	for _, ref := range decl.CalledBy {
+		def.CalledBy = appendRef(def.CalledBy, ref)
+	}
+}
@@ -0,0 +1,95 @@
+package enrich
+
+import (
+	"context"
+	"testing"
+
+	"clangd-parser/internal/fileuri"
+	"clangd-parser/internal/lsp"
+	"clangd-parser/internal/model"
+)
+
+// fakeDefinitions serves definition and declaration locations by file and
+// name position
+type fakeDefinitions struct {
+	definitions  map[string]map[lsp.Position][]lsp.Location
+	declarations map[string]map[lsp.Position][]lsp.Location
+}
+
+func (f fakeDefinitions) Definition(ctx context.Context, filePath string, pos lsp.Position) ([]lsp.Location, error) {
+	return f.definitions[filePath][pos], nil
+}
+
+func (f fakeDefinitions) PositionEncoding() lsp.PositionEncoding {
+	return lsp.PositionEncodingUTF8
+}
+
+func (f fakeDefinitions) Declaration(ctx context.Context, filePath string, pos lsp.Position) ([]lsp.Location, error) {
+	return f.declarations[filePath][pos], nil
+}
+
+func nameLocation(path string, line, character int) lsp.Location {
+	pos := lsp.Position{Line: line, Character: character}
+	return lsp.Location{URI: fileuri.FromPath(path), Range: lsp.Range{Start: pos, End: pos}}
+}
+
+func TestDeclarationsAndMerge(t *testing.T) {
+	header, source := "/src/shape.h", "/src/shape.cpp"
+
+	// shape.h declares Shape::area with a doc comment, shape.cpp defines it
+	headerChunks := []model.SemanticChunk{
+		{ID: model.ChunkID(header, 1, 7), Name: "Shape", CodeType: "Class", LineFrom: 1, LineTo: 5, NameLine: 1, NameColumn: 7, Context: model.ChunkContext{FilePath: header}},
+		{ID: model.ChunkID(header, 3, 9), Name: "area", CodeType: "Method", Docstring: "Area in square units", LineFrom: 3, LineTo: 3, NameLine: 3, NameColumn: 9, Context: model.ChunkContext{FilePath: header, StructName: "Shape"}},
+		{ID: model.ChunkID(header, 4, 10), Name: "reset", CodeType: "Method", LineFrom: 4, LineTo: 4, NameLine: 4, NameColumn: 10, Context: model.ChunkContext{FilePath: header, StructName: "Shape"}},
+	}
+	sourceChunks := []model.SemanticChunk{
+		{ID: model.ChunkID(source, 3, 12), Name: "Shape::area", CodeType: "Method", LineFrom: 3, LineTo: 5, NameLine: 3, NameColumn: 12, Context: model.ChunkContext{FilePath: source, Snippet: "int Shape::area() const {\n  return w * h;\n}"}},
+		{ID: model.ChunkID(source, 7, 6), Name: "report", CodeType: "Function", LineFrom: 7, LineTo: 7, NameLine: 7, NameColumn: 6, Context: model.ChunkContext{FilePath: source},
+			Calls: []model.ChunkRef{{ID: model.ChunkID(header, 3, 9), Name: "area", FilePath: header, Line: 3}}},
+	}
+
+	src := fakeDefinitions{
+		definitions: map[string]map[lsp.Position][]lsp.Location{
+			header: {{Line: 2, Character: 8}: {nameLocation(source, 2, 11)}},
+			// Definitions of a definition point at itself
+			source: {{Line: 2, Character: 11}: {nameLocation(source, 2, 11)}},
+		},
+		declarations: map[string]map[lsp.Position][]lsp.Location{
+			source: {{Line: 2, Character: 11}: {nameLocation(header, 2, 8)}},
+		},
+	}
+
+	if n := Declarations(context.Background(), src, header, headerChunks); n != 1 {
+		t.Errorf("Expected 1 linked header chunk, got %d", n)
+	}
+	if n := Declarations(context.Background(), src, source, sourceChunks); n != 1 {
+		t.Errorf("Expected 1 linked source chunk, got %d", n)
+	}
+	if def := headerChunks[1].Definition; def == nil || def.ID != sourceChunks[0].ID {
+		t.Fatalf("Expected the declaration to link to its definition, got %+v", def)
+	}
+	if sourceChunks[0].Definition != nil {
+		t.Errorf("Expected no definition link on the definition itself, got %+v", sourceChunks[0].Definition)
+	}
+
+	merged, n := MergeDeclarations(append(headerChunks, sourceChunks...))
+	if n != 1 || len(merged) != 4 {
+		t.Fatalf("Expected 1 declaration merged into 4 chunks, got %d and %d", n, len(merged))
+	}
+
+	area := merged[2]
+	if area.ID != model.ChunkID(source, 3, 12) || area.Context.Snippet == "" {
+		t.Errorf("Expected the merged chunk to keep the definition, got %+v", area)
+	}
+	if area.Docstring != "Area in square units" || area.Context.StructName != "Shape" {
+		t.Errorf("Expected the header docstring and class, got %q in %q", area.Docstring, area.Context.StructName)
+	}
+	if area.Declaration == nil || area.Declaration.FilePath != header || area.Declaration.Line != 3 {
+		t.Errorf("Expected a link back to the header declaration, got %+v", area.Declaration)
+	}
+
+	if call := merged[3].Calls[0]; call.ID != area.ID || call.FilePath != source {
+		t.Errorf("Expected calls to the declaration to be redirected, got %+v", call)
+	}
+	t.Logf("✓ Merged %s:%d into %s:%d", area.Declaration.FilePath, area.Declaration.Line, area.Context.FilePath, area.NameLine)
+}
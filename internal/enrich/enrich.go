@@ -0,0 +1,115 @@
+// Package enrich adds information from additional LSP requests to chunks
+// produced by the parser.
+package enrich
+
+import (
+	"clangd-parser/internal/fileuri"
+	"clangd-parser/internal/lsp"
+	"clangd-parser/internal/model"
+	"clangd-parser/internal/parser"
+)
+
+// columns converts between the byte columns of chunks and the columns of the
+// position encoding the server uses, reading each file at most once per
+// enrichment pass. Columns on lines that can't be read are used as they are.
+type columns struct {
+	encoding lsp.PositionEncoding
+	lines    map[string][]string
+}
+
+func newColumns(encoding lsp.PositionEncoding) *columns {
+	if encoding == "" {
+		encoding = lsp.PositionEncodingUTF16
+	}
+	return &columns{encoding: encoding, lines: make(map[string][]string)}
+}
+
+// line returns the 0-based line of path, or false if it can't be read
+func (c *columns) line(path string, n int) (string, bool) {
+	if c.encoding == lsp.PositionEncodingUTF8 {
+		return "", false // columns are bytes already
+	}
+	lines, ok := c.lines[path]
+	if !ok {
+		lines = parser.ReadFileLines(path)
+		c.lines[path] = lines
+	}
+	if n < 0 || n >= len(lines) {
+		return "", false
+	}
+	return lines[n], true
+}
+
+// namePosition returns the LSP position of a chunk's name, where requests
+// about the symbol are sent
+func (c *columns) namePosition(chunk model.SemanticChunk) (lsp.Position, bool) {
+	if chunk.NameLine == 0 {
+		return lsp.Position{}, false
+	}
+	pos := lsp.Position{Line: chunk.NameLine - 1, Character: chunk.NameColumn - 1}
+	if line, ok := c.line(chunk.Context.FilePath, pos.Line); ok {
+		pos.Character = c.encoding.Character(line, pos.Character)
+	}
+	return pos, true
+}
+
+// ref builds a chunk reference from a symbol the server reported by URI and
+// name position
+func (c *columns) ref(name, uri string, selection lsp.Range) model.ChunkRef {
+	path := fileuri.ToPath(uri)
+	column := selection.Start.Character
+	if line, ok := c.line(path, selection.Start.Line); ok {
+		column = c.encoding.ByteColumn(line, column)
+	}
+	return model.ChunkRef{
+		ID:       model.ChunkID(path, selection.Start.Line+1, column+1),
+		Name:     name,
+		FilePath: path,
+		Line:     selection.Start.Line + 1,
+	}
+}
+
+// appendRef appends ref unless a reference with the same ID is present
+func appendRef(refs []model.ChunkRef, ref model.ChunkRef) []model.ChunkRef {
+	for _, r := range refs {
+		if r.ID == ref.ID {
+			return refs
+		}
+	}
+	return append(refs, ref)
+}
+
+// redirectRefs points the references to chunk IDs in targets, such as
+// declarations folded into their definitions, at the chunks standing in for
+// them. Redirected references that end up duplicated or pointing at the
+// chunk holding them, such as calls between members of one group, are
+// dropped.
+func redirectRefs(chunks []model.SemanticChunk, targets map[string]model.SemanticChunk) {
+	redirect := func(ref *model.ChunkRef) bool {
+		t, ok := targets[ref.ID]
+		if ok {
+			ref.ID = t.ID
+			ref.FilePath = t.Context.FilePath
+			ref.Line = t.NameLine
+		}
+		return ok
+	}
+
+	for i := range chunks {
+		c := &chunks[i]
+		for _, refs := range []*[]model.ChunkRef{&c.Calls, &c.CalledBy, &c.Bases, &c.Derived} {
+			kept := (*refs)[:0]
+			for _, ref := range *refs {
+				if !redirect(&ref) {
+					kept = append(kept, ref)
+				} else if ref.ID != c.ID {
+					kept = appendRef(kept, ref)
+				}
+			}
+			*refs = kept
+		}
+		if c.Overrides != nil {
+			redirect(c.Overrides)
+		}
+	}
+}
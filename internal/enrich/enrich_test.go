@@ -0,0 +1,50 @@
+package enrich
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"clangd-parser/internal/fileuri"
+	"clangd-parser/internal/lsp"
+	"clangd-parser/internal/model"
+)
+
+func TestColumns(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cafe.cpp")
+	// "😀" is 4 bytes and 2 UTF-16 code units, so café starts at byte
+	// column 16 and UTF-16 column 13
+	if err := os.WriteFile(file, []byte("/* 😀 */ int café(int x) { return x; }\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	chunk := model.SemanticChunk{NameLine: 1, NameColumn: 16, Context: model.ChunkContext{FilePath: file}}
+	selection := func(character int) lsp.Range {
+		return lsp.Range{Start: lsp.Position{Character: character}}
+	}
+
+	tests := []struct {
+		encoding lsp.PositionEncoding
+		path     string
+		want     int // the server's 0-based column of the name
+	}{
+		{lsp.PositionEncodingUTF16, file, 13},
+		{lsp.PositionEncodingUTF32, file, 12},
+		{lsp.PositionEncodingUTF8, file, 15},
+		{lsp.PositionEncodingUTF16, "/missing.cpp", 15},
+	}
+
+	for _, tt := range tests {
+		cols := newColumns(tt.encoding)
+		c := chunk
+		c.Context.FilePath = tt.path
+		pos, ok := cols.namePosition(c)
+		if !ok || pos.Character != tt.want {
+			t.Errorf("%s %s: namePosition = %+v, expected character %d", tt.encoding, tt.path, pos, tt.want)
+		}
+
+		ref := cols.ref("café", fileuri.FromPath(tt.path), selection(tt.want))
+		if want := model.ChunkID(tt.path, 1, 16); ref.ID != want {
+			t.Errorf("%s %s: ref ID = %q, expected %q", tt.encoding, tt.path, ref.ID, want)
+		}
+	}
+}
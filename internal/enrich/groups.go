@@ -0,0 +1,21 @@
+package enrich
+
+import "clangd-parser/internal/model"
+
+// RedirectToGroups points references to chunks that were grouped, such as
+// calls to a getter from another file, at the group chunk that lists them
+// as a member. It runs over the chunks of a whole run, because references
+// cross files. It returns the number of grouped chunks found.
+func RedirectToGroups(chunks []model.SemanticChunk) int {
+	targets := make(map[string]model.SemanticChunk)
+	for _, c := range chunks {
+		for _, m := range c.Members {
+			targets[m.ID] = c
+		}
+	}
+
+	if len(targets) > 0 {
+		redirectRefs(chunks, targets)
+	}
+	return len(targets)
+}
@@ -0,0 +1,27 @@
+package enrich
+
+import (
+	"testing"
+
+	"clangd-parser/internal/model"
+)
+
+func TestRedirectToGroups(t *testing.T) {
+	getter := model.ChunkRef{ID: "/src/point.h:3:9", Name: "x", FilePath: "/src/point.h", Line: 3}
+	setter := model.ChunkRef{ID: "/src/point.h:4:10", Name: "setX", FilePath: "/src/point.h", Line: 4}
+
+	chunks := []model.SemanticChunk{
+		{ID: model.GroupID(getter.ID), Name: "x, setX", CodeType: "Group", NameLine: 3, Context: model.ChunkContext{FilePath: "/src/point.h"},
+			Members: []model.GroupMember{{ID: getter.ID, Name: "x"}, {ID: setter.ID, Name: "setX"}}},
+		{ID: "/src/main.cpp:1:5", Name: "main", CodeType: "Function", Calls: []model.ChunkRef{getter, setter, {ID: "/src/main.cpp:1:5", Name: "main"}}},
+	}
+
+	if n := RedirectToGroups(chunks); n != 2 {
+		t.Errorf("Expected 2 grouped chunks, got %d", n)
+	}
+	calls := chunks[1].Calls
+	if len(calls) != 2 || calls[0].ID != chunks[0].ID || calls[0].Line != 3 || calls[1].Name != "main" {
+		t.Errorf("Expected one call into the group and the recursive call, got %+v", calls)
+	}
+	t.Logf("✓ Redirected calls to %s", chunks[0].Name)
+}
@@ -0,0 +1,195 @@
+package enrich
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"clangd-parser/internal/lsp"
+	"clangd-parser/internal/model"
+)
+
+// HoverSource is the part of lsp.Client hover enrichment needs
+type HoverSource interface {
+	Hover(ctx context.Context, filePath string, pos lsp.Position) (*lsp.Hover, error)
+	// PositionEncoding is the encoding of the positions the server reports
+	PositionEncoding() lsp.PositionEncoding
+}
+
+// HoverInfo is the structured content of a clangd hover
+type HoverInfo struct {
+	Kind          string // e.g. "function", "class", "instance-method"
+	Name          string
+	ReturnType    string
+	Parameters    []string
+	Scope         string // owning namespace or class
+	Definition    string // declaration from the code block
+	Documentation string
+}
+
+// Hover fills signatures and docstrings that the symbol heuristics left empty
+// with information from textDocument/hover. The document must be open.
+// It returns the number of chunks that were changed.
+func Hover(ctx context.Context, src HoverSource, filePath string, chunks []model.SemanticChunk) int {
+	cols := newColumns(src.PositionEncoding())
+	enriched := 0
+
+	for i := range chunks {
+		chunk := &chunks[i]
+
+		needSignature := chunk.Signature == "" || chunk.Signature == chunk.Name
+		needDocstring := chunk.Docstring == ""
+		pos, ok := cols.namePosition(*chunk)
+		if (!needSignature && !needDocstring) || !ok {
+			continue
+		}
+
+		hover, err := src.Hover(ctx, filePath, pos)
+		if err != nil || hover == nil {
+			continue
+		}
+
+		info := ParseHover(hover.Contents.Value)
+		changed := false
+
+		if needSignature {
+			if sig := info.Signature(); sig != "" {
+				chunk.Signature = sig
+				changed = true
+			}
+		}
+		if needDocstring && info.Documentation != "" {
+			chunk.Docstring = info.Documentation
+			changed = true
+		}
+		if info.ReturnType != "" || len(info.Parameters) > 0 || info.Scope != "" || info.Definition != "" {
+			chunk.SignatureInfo = &model.SignatureInfo{
+				ReturnType: info.ReturnType,
+				Parameters: info.Parameters,
+				Scope:      info.Scope,
+				Definition: info.Definition,
+			}
+			changed = true
+		}
+
+		if changed {
+			enriched++
+		}
+	}
+
+	return enriched
+}
+
+// Signature returns the best signature the hover provides: the declaration
+// from the code block, or one assembled from return type and parameters
+func (h HoverInfo) Signature() string {
+	if h.Definition != "" {
+		return h.Definition
+	}
+	if h.ReturnType == "" && len(h.Parameters) == 0 {
+		return ""
+	}
+
+	sig := h.Name + "(" + strings.Join(h.Parameters, ", ") + ")"
+	if h.ReturnType != "" {
+		sig = h.ReturnType + " " + sig
+	}
+	return sig
+}
+
+var (
+	hoverTitle      = regexp.MustCompile("^#+\\s+(.*?)\\s*`(.+)`$")
+	hoverCodeTicks  = regexp.MustCompile("^`(.*)`$")
+	hoverAccess     = regexp.MustCompile(`^(public|protected|private):\s*`)
+	markdownEscapes = regexp.MustCompile(`\\([!-/:-@\[-` + "`" + `{-~])`)
+)
+
+// hoverMetadata are prefixes of hover lines that describe layout or values
+// rather than documentation
+var hoverMetadata = []string{
+	"Type:", "Value =", "Offset:", "Size:", "size =", "Provided by", "Passed ",
+}
+
+// ParseHover parses clangd's markdown hover into its parts. Plaintext hovers
+// have the same layout without markup and are handled as well.
+func ParseHover(text string) HoverInfo {
+	var info HoverInfo
+	var code, doc []string
+	inCode := false
+	inParams := false
+
+	for _, raw := range strings.Split(text, "\n") {
+		line := strings.TrimSpace(raw)
+
+		if strings.HasPrefix(line, "```") {
+			inCode = !inCode
+			continue
+		}
+
+		if inCode {
+			if strings.HasPrefix(line, "// In ") {
+				info.Scope = strings.TrimPrefix(strings.TrimPrefix(line, "// In "), "namespace ")
+				continue
+			}
+			if line != "" {
+				code = append(code, hoverAccess.ReplaceAllString(line, ""))
+			}
+			continue
+		}
+
+		switch {
+		case line == "":
+			inParams = false
+			if len(doc) > 0 && doc[len(doc)-1] != "" {
+				doc = append(doc, "")
+			}
+
+		case line == "---":
+			inParams = false
+
+		case hoverTitle.MatchString(line):
+			m := hoverTitle.FindStringSubmatch(line)
+			info.Kind, info.Name = m[1], m[2]
+
+		case strings.HasPrefix(line, "→"):
+			info.ReturnType = unquoteCode(strings.TrimSpace(strings.TrimPrefix(line, "→")))
+
+		case line == "Parameters:":
+			inParams = true
+
+		case inParams && strings.HasPrefix(line, "- "):
+			info.Parameters = append(info.Parameters, unquoteCode(strings.TrimPrefix(line, "- ")))
+
+		case isHoverMetadata(line):
+
+		default:
+			doc = append(doc, unescapeMarkdown(line))
+		}
+	}
+
+	definition := strings.TrimSpace(strings.Join(code, " "))
+	definition = strings.TrimSuffix(strings.TrimSuffix(definition, ";"), "{}")
+	info.Definition = strings.TrimSpace(definition)
+	info.Documentation = strings.TrimSpace(strings.Join(strings.Fields(strings.Join(doc, " ")), " "))
+	return info
+}
+
+func isHoverMetadata(line string) bool {
+	for _, prefix := range hoverMetadata {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func unquoteCode(s string) string {
+	if m := hoverCodeTicks.FindStringSubmatch(s); m != nil {
+		return m[1]
+	}
+	return s
+}
+
+func unescapeMarkdown(s string) string {
+	return markdownEscapes.ReplaceAllString(s, "$1")
+}
@@ -0,0 +1,109 @@
+package enrich
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"clangd-parser/internal/lsp"
+	"clangd-parser/internal/model"
+)
+
+const methodHover = "### instance-method `resize`  \n\n---\n→ `bool`  \nParameters:  \n- `int width`  \n- `int height`  \n\nResizes the widget\\. Returns `false` if the size is invalid\\.  \n\n---\n```cpp\n// In ui::Widget\npublic: bool resize(int width, int height)\n```"
+
+const classHover = "### class `Widget`  \n\n---\nsize = 16 bytes, alignment = 8 bytes  \nA basic UI element\\.  \n\n---\n```cpp\n// In namespace ui\nclass Widget {}\n```"
+
+func TestParseHoverMethod(t *testing.T) {
+	info := ParseHover(methodHover)
+
+	if info.Kind != "instance-method" || info.Name != "resize" {
+		t.Errorf("Unexpected title: kind=%q name=%q", info.Kind, info.Name)
+	}
+	if info.ReturnType != "bool" {
+		t.Errorf("Expected return type 'bool', got %q", info.ReturnType)
+	}
+	if !reflect.DeepEqual(info.Parameters, []string{"int width", "int height"}) {
+		t.Errorf("Unexpected parameters: %v", info.Parameters)
+	}
+	if info.Scope != "ui::Widget" {
+		t.Errorf("Expected scope 'ui::Widget', got %q", info.Scope)
+	}
+	if info.Definition != "bool resize(int width, int height)" {
+		t.Errorf("Unexpected definition: %q", info.Definition)
+	}
+	if info.Documentation != "Resizes the widget. Returns `false` if the size is invalid." {
+		t.Errorf("Unexpected documentation: %q", info.Documentation)
+	}
+}
+
+func TestParseHoverClass(t *testing.T) {
+	info := ParseHover(classHover)
+
+	if info.Scope != "ui" {
+		t.Errorf("Expected scope 'ui', got %q", info.Scope)
+	}
+	if info.Definition != "class Widget" {
+		t.Errorf("Unexpected definition: %q", info.Definition)
+	}
+	if info.Documentation != "A basic UI element." {
+		t.Errorf("Expected layout line to be skipped, got %q", info.Documentation)
+	}
+}
+
+func TestHoverInfoSignature(t *testing.T) {
+	info := HoverInfo{Name: "add", ReturnType: "int", Parameters: []string{"int a", "int b"}}
+	if sig := info.Signature(); sig != "int add(int a, int b)" {
+		t.Errorf("Unexpected assembled signature: %q", sig)
+	}
+	if sig := (HoverInfo{Name: "x"}).Signature(); sig != "" {
+		t.Errorf("Expected empty signature without type information, got %q", sig)
+	}
+}
+
+// fakeHovers answers hover requests from a map keyed by position
+type fakeHovers map[lsp.Position]string
+
+func (f fakeHovers) Hover(ctx context.Context, filePath string, pos lsp.Position) (*lsp.Hover, error) {
+	text, ok := f[pos]
+	if !ok {
+		return nil, nil
+	}
+	return &lsp.Hover{Contents: lsp.HoverContents{Kind: "markdown", Value: text}}, nil
+}
+
+func (f fakeHovers) PositionEncoding() lsp.PositionEncoding {
+	return lsp.PositionEncodingUTF8
+}
+
+func TestHoverEnrichment(t *testing.T) {
+	chunks := []model.SemanticChunk{
+		// Detail was empty, so the signature fell back to the name
+		{Name: "Widget", Signature: "Widget", NameLine: 3, NameColumn: 7},
+		// Already complete, must not be touched
+		{Name: "draw", Signature: "void ()", Docstring: "Draws", NameLine: 10, NameColumn: 8},
+		{Name: "resize", Signature: "bool (int, int)", NameLine: 12, NameColumn: 8},
+	}
+
+	src := fakeHovers{
+		{Line: 2, Character: 6}:  classHover,
+		{Line: 9, Character: 7}:  methodHover,
+		{Line: 11, Character: 7}: methodHover,
+	}
+
+	if n := Hover(context.Background(), src, "widget.h", chunks); n != 2 {
+		t.Errorf("Expected 2 enriched chunks, got %d", n)
+	}
+
+	if chunks[0].Signature != "class Widget" || chunks[0].Docstring != "A basic UI element." {
+		t.Errorf("Class not enriched: %+v", chunks[0])
+	}
+	if chunks[1].Signature != "void ()" || chunks[1].SignatureInfo != nil {
+		t.Errorf("Complete chunk was modified: %+v", chunks[1])
+	}
+	if chunks[2].Signature != "bool (int, int)" {
+		t.Errorf("Existing signature was overwritten: %q", chunks[2].Signature)
+	}
+	if chunks[2].SignatureInfo == nil || chunks[2].SignatureInfo.Scope != "ui::Widget" {
+		t.Errorf("Expected structured signature on resize, got %+v", chunks[2].SignatureInfo)
+	}
+}
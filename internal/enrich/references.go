@@ -0,0 +1,86 @@
+package enrich
+
+import (
+	"context"
+	"strings"
+
+	"clangd-parser/internal/fileuri"
+	"clangd-parser/internal/lsp"
+	"clangd-parser/internal/model"
+	"clangd-parser/internal/parser"
+)
+
+// ReferenceSource is the part of lsp.Client reference counting needs
+type ReferenceSource interface {
+	References(ctx context.Context, filePath string, pos lsp.Position, includeDeclaration bool) ([]lsp.Location, error)
+	// PositionEncoding is the encoding of the positions the server reports
+	PositionEncoding() lsp.PositionEncoding
+}
+
+// References stores the number of references to each chunk's symbol and up
+// to maxUsages usage sites outside the chunk itself. The declaration is not
+// counted. The document must be open. It returns the number of chunks with
+// at least one reference.
+func References(ctx context.Context, src ReferenceSource, filePath string, chunks []model.SemanticChunk, maxUsages int) int {
+	cols := newColumns(src.PositionEncoding())
+	lines := newLineCache()
+	referenced := 0
+
+	for i := range chunks {
+		chunk := &chunks[i]
+
+		pos, ok := cols.namePosition(*chunk)
+		if chunk.CodeType == "Namespace" || !ok {
+			continue
+		}
+
+		locations, err := src.References(ctx, filePath, pos, false)
+		if err != nil || len(locations) == 0 {
+			continue
+		}
+
+		chunk.ReferenceCount = len(locations)
+		referenced++
+
+		for _, loc := range locations {
+			if len(chunk.Usages) >= maxUsages {
+				break
+			}
+
+			path := fileuri.ToPath(loc.URI)
+			line := loc.Range.Start.Line + 1
+			if path == filePath && line >= chunk.LineFrom && line <= chunk.LineTo {
+				continue // recursive or self reference, not a usage example
+			}
+
+			chunk.Usages = append(chunk.Usages, model.UsageSite{
+				FilePath: path,
+				Line:     line,
+				Snippet:  lines.line(path, loc.Range.Start.Line),
+			})
+		}
+	}
+
+	return referenced
+}
+
+// lineCache reads each file at most once per enrichment pass
+type lineCache map[string][]string
+
+func newLineCache() lineCache {
+	return make(lineCache)
+}
+
+// line returns the trimmed 0-based line of path, or "" if it doesn't exist
+func (c lineCache) line(path string, line int) string {
+	lines, ok := c[path]
+	if !ok {
+		lines = parser.ReadFileLines(path)
+		c[path] = lines
+	}
+
+	if line < 0 || line >= len(lines) {
+		return ""
+	}
+	return strings.TrimSpace(lines[line])
+}
@@ -0,0 +1,76 @@
+package enrich
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"clangd-parser/internal/fileuri"
+	"clangd-parser/internal/lsp"
+	"clangd-parser/internal/model"
+)
+
+type fakeReferences map[lsp.Position][]lsp.Location
+
+func (f fakeReferences) References(ctx context.Context, filePath string, pos lsp.Position, includeDeclaration bool) ([]lsp.Location, error) {
+	return f[pos], nil
+}
+
+func (f fakeReferences) PositionEncoding() lsp.PositionEncoding {
+	return lsp.PositionEncodingUTF8
+}
+
+func locationAt(path string, line int) lsp.Location {
+	return lsp.Location{
+		URI:   fileuri.FromPath(path),
+		Range: lsp.Range{Start: lsp.Position{Line: line}, End: lsp.Position{Line: line}},
+	}
+}
+
+func TestReferences(t *testing.T) {
+	tmpDir := t.TempDir()
+	lib := filepath.Join(tmpDir, "lib.cpp")
+	user := filepath.Join(tmpDir, "user.cpp")
+
+	libCode := "int fib(int n) {\n  return n < 2 ? n : fib(n - 1) + fib(n - 2);\n}\n"
+	userCode := "void a() {\n    int x = fib(10);\n}\nvoid b() { fib(3); }\nvoid c() { fib(4); }\n"
+	for path, code := range map[string]string{lib: libCode, user: userCode} {
+		if err := os.WriteFile(path, []byte(code), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	chunks := []model.SemanticChunk{
+		{Name: "fib", CodeType: "Function", LineFrom: 1, LineTo: 3, NameLine: 1, NameColumn: 5},
+		{Name: "unused", CodeType: "Function", LineFrom: 5, LineTo: 5, NameLine: 5, NameColumn: 5},
+	}
+
+	src := fakeReferences{
+		{Line: 0, Character: 4}: {
+			locationAt(lib, 1), // recursive call inside fib itself
+			locationAt(user, 1),
+			locationAt(user, 3),
+			locationAt(user, 4),
+		},
+	}
+
+	if n := References(context.Background(), src, lib, chunks, 2); n != 1 {
+		t.Errorf("Expected 1 referenced chunk, got %d", n)
+	}
+
+	fib := chunks[0]
+	if fib.ReferenceCount != 4 {
+		t.Errorf("Expected 4 references, got %d", fib.ReferenceCount)
+	}
+	if len(fib.Usages) != 2 {
+		t.Fatalf("Expected usages capped at 2, got %+v", fib.Usages)
+	}
+	if fib.Usages[0].FilePath != user || fib.Usages[0].Line != 2 || fib.Usages[0].Snippet != "int x = fib(10);" {
+		t.Errorf("Unexpected first usage: %+v", fib.Usages[0])
+	}
+
+	if chunks[1].ReferenceCount != 0 || chunks[1].Usages != nil {
+		t.Errorf("Unreferenced chunk was changed: %+v", chunks[1])
+	}
+}
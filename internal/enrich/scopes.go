@@ -0,0 +1,61 @@
+package enrich
+
+import (
+	"strings"
+
+	"clangd-parser/internal/model"
+)
+
+// ResolveScopes completes qualified names across the chunks of a whole run,
+// after Declarations. A definition linked to a declaration among the chunks
+// takes over its qualified name and scopes, since the declaration's scopes
+// are nested as written while an out-of-line definition such as Foo::bar may
+// rely on a using directive. Scopes of unknown kind then take the code type
+// of the chunk with their qualified name. It returns the number of chunks
+// changed.
+func ResolveScopes(chunks []model.SemanticChunk) int {
+	byID := make(map[string]int, len(chunks))
+	for i, c := range chunks {
+		byID[c.ID] = i
+	}
+
+	changed := make(map[int]bool)
+	for i := range chunks {
+		c := &chunks[i]
+		if c.Declaration == nil {
+			continue
+		}
+		d, ok := byID[c.Declaration.ID]
+		if !ok || d == i || chunks[d].QualifiedName == "" || chunks[d].QualifiedName == c.QualifiedName {
+			continue
+		}
+		c.QualifiedName = chunks[d].QualifiedName
+		c.Scopes = append([]model.Scope(nil), chunks[d].Scopes...)
+		changed[i] = true
+	}
+
+	kinds := make(map[string]string)
+	for _, c := range chunks {
+		if c.QualifiedName != "" && kinds[c.QualifiedName] == "" {
+			kinds[c.QualifiedName] = c.CodeType
+		}
+	}
+	for i := range chunks {
+		var names []string
+		for j, s := range chunks[i].Scopes {
+			names = append(names, s.Name)
+			kind := kinds[strings.Join(names, "::")]
+			if s.Kind != "" || kind == "" {
+				continue
+			}
+			if !changed[i] {
+				// Chunks of a file may share their scopes
+				chunks[i].Scopes = append([]model.Scope(nil), chunks[i].Scopes...)
+				changed[i] = true
+			}
+			chunks[i].Scopes[j].Kind = kind
+		}
+	}
+
+	return len(changed)
+}
@@ -0,0 +1,40 @@
+package enrich
+
+import (
+	"reflect"
+	"testing"
+
+	"clangd-parser/internal/model"
+)
+
+func TestResolveScopes(t *testing.T) {
+	header, source := "/src/foo.h", "/src/foo.cpp"
+	classScopes := []model.Scope{{Name: "ns", Kind: "Namespace"}, {Name: "Foo", Kind: "Class"}}
+
+	chunks := []model.SemanticChunk{
+		{ID: model.ChunkID(header, 1, 11), Name: "ns", CodeType: "Namespace", QualifiedName: "ns"},
+		{ID: model.ChunkID(header, 2, 7), Name: "Foo", CodeType: "Class", QualifiedName: "ns::Foo", Scopes: classScopes[:1]},
+		{ID: model.ChunkID(header, 3, 10), Name: "bar", CodeType: "Method", QualifiedName: "ns::Foo::bar", Scopes: classScopes},
+		// foo.cpp defines bar after "using namespace ns;"
+		{ID: model.ChunkID(source, 3, 11), Name: "Foo::bar", CodeType: "Method", QualifiedName: "Foo::bar",
+			Scopes:      []model.Scope{{Name: "Foo", Kind: "Class"}},
+			Declaration: &model.ChunkRef{ID: model.ChunkID(header, 3, 10), Name: "bar", FilePath: header, Line: 3}},
+		{ID: model.ChunkID(source, 5, 13), Name: "ns::Foo::count", CodeType: "Variable", QualifiedName: "ns::Foo::count",
+			Scopes: []model.Scope{{Name: "ns"}, {Name: "Foo"}}},
+	}
+
+	if n := ResolveScopes(chunks); n != 2 {
+		t.Errorf("Expected 2 resolved chunks, got %d", n)
+	}
+	if def := chunks[3]; def.QualifiedName != "ns::Foo::bar" || !reflect.DeepEqual(def.Scopes, classScopes) {
+		t.Errorf("Expected the definition to take the declaration's name, got %q %+v", def.QualifiedName, def.Scopes)
+	}
+	if got := chunks[4].Scopes; !reflect.DeepEqual(got, classScopes) {
+		t.Errorf("Expected scope kinds from the run, got %+v", got)
+	}
+
+	if n := ResolveScopes(chunks); n != 0 {
+		t.Errorf("Expected nothing left to resolve, got %d", n)
+	}
+	t.Logf("✓ Resolved out-of-line definition %s", chunks[3].QualifiedName)
+}
@@ -0,0 +1,194 @@
+package enrich
+
+import (
+	"context"
+
+	"clangd-parser/internal/lsp"
+	"clangd-parser/internal/model"
+)
+
+// TypeHierarchySource is the part of lsp.Client inheritance extraction needs
+type TypeHierarchySource interface {
+	PrepareTypeHierarchy(ctx context.Context, filePath string, pos lsp.Position) ([]lsp.TypeHierarchyItem, error)
+	Supertypes(ctx context.Context, item lsp.TypeHierarchyItem) ([]lsp.TypeHierarchyItem, error)
+	Subtypes(ctx context.Context, item lsp.TypeHierarchyItem) ([]lsp.TypeHierarchyItem, error)
+	LegacyTypeHierarchy(ctx context.Context, filePath string, pos lsp.Position, resolve, direction int) (*lsp.TypeHierarchyItem, error)
+	// PositionEncoding is the encoding of the positions the server reports
+	PositionEncoding() lsp.PositionEncoding
+}
+
+// typeTypes are the chunk types that take part in the type hierarchy
+var typeTypes = map[string]bool{
+	"Class":     true,
+	"Struct":    true,
+	"Interface": true,
+}
+
+// TypeHierarchy records the direct bases and derived types of every class,
+// struct and interface chunk. The document must be open. Servers without the
+// standard type hierarchy requests fall back to clangd's legacy extension.
+// It returns the number of chunks with at least one edge.
+func TypeHierarchy(ctx context.Context, src TypeHierarchySource, filePath string, chunks []model.SemanticChunk) int {
+	cols := newColumns(src.PositionEncoding())
+	linked := 0
+
+	for i := range chunks {
+		chunk := &chunks[i]
+
+		pos, ok := cols.namePosition(*chunk)
+		if !typeTypes[chunk.CodeType] || !ok {
+			continue
+		}
+
+		bases, derived, err := standardTypeHierarchy(ctx, src, filePath, pos)
+		if err != nil {
+			bases, derived, err = legacyTypeHierarchy(ctx, src, filePath, pos)
+		}
+		if err != nil {
+			continue
+		}
+
+		for _, item := range bases {
+			chunk.Bases = appendRef(chunk.Bases, cols.ref(item.Name, item.URI, item.SelectionRange))
+		}
+		for _, item := range derived {
+			chunk.Derived = appendRef(chunk.Derived, cols.ref(item.Name, item.URI, item.SelectionRange))
+		}
+
+		if len(chunk.Bases) > 0 || len(chunk.Derived) > 0 {
+			linked++
+		}
+	}
+
+	return linked
+}
+
+func standardTypeHierarchy(ctx context.Context, src TypeHierarchySource, filePath string, pos lsp.Position) (bases, derived []lsp.TypeHierarchyItem, err error) {
+	items, err := src.PrepareTypeHierarchy(ctx, filePath, pos)
+	if err != nil || len(items) == 0 {
+		return nil, nil, err
+	}
+
+	if bases, err = src.Supertypes(ctx, items[0]); err != nil {
+		return nil, nil, err
+	}
+	if derived, err = src.Subtypes(ctx, items[0]); err != nil {
+		return nil, nil, err
+	}
+	return bases, derived, nil
+}
+
+func legacyTypeHierarchy(ctx context.Context, src TypeHierarchySource, filePath string, pos lsp.Position) (bases, derived []lsp.TypeHierarchyItem, err error) {
+	item, err := src.LegacyTypeHierarchy(ctx, filePath, pos, 1, lsp.TypeHierarchyBoth)
+	if err != nil || item == nil {
+		return nil, nil, err
+	}
+	return item.Parents, item.Children, nil
+}
+
+// LinkOverrides marks methods that override a method of one of their
+// class's (transitive) bases. It runs over the chunks of a whole run after
+// TypeHierarchy, because base classes usually live in other files. Overloads
+// are told apart by signature when both have one. It returns the number of
+// methods marked.
+func LinkOverrides(chunks []model.SemanticChunk) int {
+	byID := make(map[string]int, len(chunks))
+	for i, c := range chunks {
+		byID[c.ID] = i
+	}
+
+	// Class chunks by file and name, to find the owner of each method
+	classes := make(map[[2]string][]int)
+	for i, c := range chunks {
+		if typeTypes[c.CodeType] {
+			key := [2]string{c.Context.FilePath, c.Name}
+			classes[key] = append(classes[key], i)
+		}
+	}
+
+	// Methods grouped by the index of the class chunk that declares them
+	methods := make(map[int][]int)
+	for i, c := range chunks {
+		if c.CodeType != "Method" || c.Context.StructName == "" {
+			continue
+		}
+		candidates := classes[[2]string{c.Context.FilePath, c.Context.StructName}]
+		if owner := ownerOf(chunks, candidates, c); owner >= 0 {
+			methods[owner] = append(methods[owner], i)
+		}
+	}
+
+	marked := 0
+	for owner, ms := range methods {
+		for _, m := range ms {
+			if base, ok := findOverridden(chunks, byID, methods, owner, chunks[m]); ok {
+				ref := model.ChunkRef{
+					ID:       chunks[base].ID,
+					Name:     chunks[base].Name,
+					FilePath: chunks[base].Context.FilePath,
+					Line:     chunks[base].NameLine,
+				}
+				chunks[m].Overrides = &ref
+				marked++
+			}
+		}
+	}
+
+	return marked
+}
+
+// ownerOf returns the index of the innermost candidate class chunk enclosing
+// method, or -1
+func ownerOf(chunks []model.SemanticChunk, candidates []int, method model.SemanticChunk) int {
+	owner := -1
+	for _, i := range candidates {
+		c := chunks[i]
+		if c.LineFrom > method.LineFrom || c.LineTo < method.LineTo {
+			continue
+		}
+		if owner < 0 || c.LineFrom >= chunks[owner].LineFrom {
+			owner = i
+		}
+	}
+	return owner
+}
+
+// findOverridden walks the bases of owner breadth first looking for a method
+// matching method
+func findOverridden(chunks []model.SemanticChunk, byID map[string]int, methods map[int][]int, owner int, method model.SemanticChunk) (int, bool) {
+	visited := map[int]bool{owner: true}
+	queue := []int{owner}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, ref := range chunks[current].Bases {
+			base, ok := byID[ref.ID]
+			if !ok || visited[base] {
+				continue
+			}
+			visited[base] = true
+			queue = append(queue, base)
+
+			candidate := -1
+			for _, m := range methods[base] {
+				if chunks[m].Name != method.Name {
+					continue
+				}
+				if chunks[m].Signature == method.Signature {
+					return m, true
+				}
+				// Without both signatures overloads can't be told apart
+				if candidate < 0 && (chunks[m].Signature == "" || method.Signature == "") {
+					candidate = m
+				}
+			}
+			if candidate >= 0 {
+				return candidate, true
+			}
+		}
+	}
+
+	return -1, false
+}
@@ -0,0 +1,193 @@
+package enrich
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"clangd-parser/internal/fileuri"
+	"clangd-parser/internal/lsp"
+	"clangd-parser/internal/model"
+)
+
+// fakeTypeHierarchy serves type hierarchy items by name position. With
+// legacy set it behaves like an older clangd without the standard requests.
+type fakeTypeHierarchy struct {
+	legacy  bool
+	items   map[lsp.Position]lsp.TypeHierarchyItem
+	parents map[string][]lsp.TypeHierarchyItem
+	child   map[string][]lsp.TypeHierarchyItem
+}
+
+func (f fakeTypeHierarchy) PrepareTypeHierarchy(ctx context.Context, filePath string, pos lsp.Position) ([]lsp.TypeHierarchyItem, error) {
+	if f.legacy {
+		return nil, errors.New("method not found")
+	}
+	if item, ok := f.items[pos]; ok {
+		return []lsp.TypeHierarchyItem{item}, nil
+	}
+	return nil, nil
+}
+
+func (f fakeTypeHierarchy) PositionEncoding() lsp.PositionEncoding {
+	return lsp.PositionEncodingUTF8
+}
+
+func (f fakeTypeHierarchy) Supertypes(ctx context.Context, item lsp.TypeHierarchyItem) ([]lsp.TypeHierarchyItem, error) {
+	return f.parents[item.Name], nil
+}
+
+func (f fakeTypeHierarchy) Subtypes(ctx context.Context, item lsp.TypeHierarchyItem) ([]lsp.TypeHierarchyItem, error) {
+	return f.child[item.Name], nil
+}
+
+func (f fakeTypeHierarchy) LegacyTypeHierarchy(ctx context.Context, filePath string, pos lsp.Position, resolve, direction int) (*lsp.TypeHierarchyItem, error) {
+	item, ok := f.items[pos]
+	if !ok {
+		return nil, nil
+	}
+	item.Parents = f.parents[item.Name]
+	item.Children = f.child[item.Name]
+	return &item, nil
+}
+
+func typeItem(name, path string, line, character int) lsp.TypeHierarchyItem {
+	pos := lsp.Position{Line: line, Character: character}
+	return lsp.TypeHierarchyItem{
+		Name:           name,
+		Kind:           lsp.SymbolKindClass,
+		URI:            fileuri.FromPath(path),
+		SelectionRange: lsp.Range{Start: pos, End: pos},
+	}
+}
+
+func TestTypeHierarchy(t *testing.T) {
+	for _, legacy := range []bool{false, true} {
+		chunks := []model.SemanticChunk{
+			{Name: "Circle", CodeType: "Class", NameLine: 3, NameColumn: 7},
+			{Name: "area", CodeType: "Method", NameLine: 4, NameColumn: 10},
+		}
+
+		src := fakeTypeHierarchy{
+			legacy: legacy,
+			items: map[lsp.Position]lsp.TypeHierarchyItem{
+				{Line: 2, Character: 6}: typeItem("Circle", "/src/circle.h", 2, 6),
+			},
+			parents: map[string][]lsp.TypeHierarchyItem{
+				"Circle": {typeItem("Shape", "/src/shape.h", 0, 6)},
+			},
+			child: map[string][]lsp.TypeHierarchyItem{
+				"Circle": {typeItem("Ring", "/src/ring.h", 5, 6)},
+			},
+		}
+
+		if n := TypeHierarchy(context.Background(), src, "/src/circle.h", chunks); n != 1 {
+			t.Errorf("legacy=%v: expected 1 linked chunk, got %d", legacy, n)
+		}
+
+		circle := chunks[0]
+		if len(circle.Bases) != 1 || circle.Bases[0].ID != model.ChunkID("/src/shape.h", 1, 7) {
+			t.Errorf("legacy=%v: unexpected bases %+v", legacy, circle.Bases)
+		}
+		if len(circle.Derived) != 1 || circle.Derived[0].Name != "Ring" {
+			t.Errorf("legacy=%v: unexpected derived %+v", legacy, circle.Derived)
+		}
+		if chunks[1].Bases != nil {
+			t.Errorf("legacy=%v: methods should not get bases", legacy)
+		}
+	}
+}
+
+func TestLinkOverrides(t *testing.T) {
+	shape := model.SemanticChunk{
+		ID: model.ChunkID("/src/shape.h", 1, 7), Name: "Shape", CodeType: "Class",
+		LineFrom: 1, LineTo: 6, NameLine: 1,
+		Context: model.ChunkContext{FilePath: "/src/shape.h"},
+	}
+	shapeArea := model.SemanticChunk{
+		ID: model.ChunkID("/src/shape.h", 3, 18), Name: "area", CodeType: "Method", Signature: "double () const",
+		LineFrom: 3, LineTo: 3, NameLine: 3,
+		Context: model.ChunkContext{FilePath: "/src/shape.h", StructName: "Shape"},
+	}
+	circle := model.SemanticChunk{
+		ID: model.ChunkID("/src/circle.h", 3, 7), Name: "Circle", CodeType: "Class",
+		LineFrom: 3, LineTo: 8,
+		Bases:   []model.ChunkRef{{ID: shape.ID, Name: "Shape"}},
+		Context: model.ChunkContext{FilePath: "/src/circle.h"},
+	}
+	circleArea := model.SemanticChunk{
+		ID: model.ChunkID("/src/circle.h", 5, 10), Name: "area", CodeType: "Method", Signature: "double () const",
+		LineFrom: 5, LineTo: 5,
+		Context: model.ChunkContext{FilePath: "/src/circle.h", StructName: "Circle"},
+	}
+	circleRadius := model.SemanticChunk{
+		ID: model.ChunkID("/src/circle.h", 6, 10), Name: "radius", CodeType: "Method",
+		LineFrom: 6, LineTo: 6,
+		Context: model.ChunkContext{FilePath: "/src/circle.h", StructName: "Circle"},
+	}
+	ring := model.SemanticChunk{
+		ID: model.ChunkID("/src/ring.h", 1, 7), Name: "Ring", CodeType: "Class",
+		LineFrom: 1, LineTo: 4,
+		Bases:   []model.ChunkRef{{ID: circle.ID, Name: "Circle"}},
+		Context: model.ChunkContext{FilePath: "/src/ring.h"},
+	}
+	ringArea := model.SemanticChunk{
+		ID: model.ChunkID("/src/ring.h", 2, 10), Name: "area", CodeType: "Method", Signature: "double () const",
+		LineFrom: 2, LineTo: 2,
+		Context: model.ChunkContext{FilePath: "/src/ring.h", StructName: "Ring"},
+	}
+
+	chunks := []model.SemanticChunk{shape, shapeArea, circle, circleArea, circleRadius, ring, ringArea}
+
+	if n := LinkOverrides(chunks); n != 2 {
+		t.Errorf("Expected 2 overriding methods, got %d", n)
+	}
+
+	if o := chunks[3].Overrides; o == nil || o.ID != shapeArea.ID {
+		t.Errorf("Circle::area should override Shape::area, got %+v", o)
+	}
+	// The nearest base wins
+	if o := chunks[6].Overrides; o == nil || o.ID != circleArea.ID {
+		t.Errorf("Ring::area should override Circle::area, got %+v", o)
+	}
+	if chunks[4].Overrides != nil || chunks[1].Overrides != nil {
+		t.Error("Methods without a base method should not be marked")
+	}
+}
+
+func TestLinkOverridesOverloads(t *testing.T) {
+	method := func(file, class, signature string, line int) model.SemanticChunk {
+		return model.SemanticChunk{
+			ID: model.ChunkID(file, line, 10), Name: "draw", CodeType: "Method", Signature: signature,
+			LineFrom: line, LineTo: line,
+			Context: model.ChunkContext{FilePath: file, StructName: class},
+		}
+	}
+	base := model.SemanticChunk{
+		ID: model.ChunkID("/src/base.h", 1, 7), Name: "Base", CodeType: "Class", LineFrom: 1, LineTo: 4,
+		Context: model.ChunkContext{FilePath: "/src/base.h"},
+	}
+	derived := model.SemanticChunk{
+		ID: model.ChunkID("/src/derived.h", 1, 7), Name: "Derived", CodeType: "Class", LineFrom: 1, LineTo: 5,
+		Bases:   []model.ChunkRef{{ID: base.ID, Name: "Base"}},
+		Context: model.ChunkContext{FilePath: "/src/derived.h"},
+	}
+
+	chunks := []model.SemanticChunk{
+		base,
+		method("/src/base.h", "Base", "void (int)", 2),
+		derived,
+		method("/src/derived.h", "Derived", "void (double)", 2), // a new overload
+		method("/src/derived.h", "Derived", "", 3),              // signature unknown
+	}
+
+	if n := LinkOverrides(chunks); n != 1 {
+		t.Errorf("Expected 1 overriding method, got %d", n)
+	}
+	if o := chunks[3].Overrides; o != nil {
+		t.Errorf("draw(double) should not override draw(int), got %+v", o)
+	}
+	if o := chunks[4].Overrides; o == nil || o.ID != chunks[1].ID {
+		t.Errorf("Expected a method without signature to match by name, got %+v", o)
+	}
+}
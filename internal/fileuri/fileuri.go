@@ -0,0 +1,52 @@
+// Package fileuri converts between local file paths and the file URIs used
+// in LSP messages. Paths are normalized the same way everywhere so that a
+// file opened by the client and the same file reported back by the server
+// compare equal.
+package fileuri
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// Normalize returns path as a clean absolute path with symlinks resolved.
+// Paths that don't exist are only made absolute.
+func Normalize(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		return real
+	}
+	return abs
+}
+
+// FromPath returns the file URI of path after normalizing it. The path is
+// percent-encoded per RFC 3986, so spaces, '#', '?' and non-ASCII names
+// survive the round trip.
+func FromPath(path string) string {
+	p := filepath.ToSlash(Normalize(path))
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p // Windows drive letter, file:///C:/...
+	}
+
+	u := url.URL{Scheme: "file", Path: p}
+	return u.String()
+}
+
+// ToPath decodes a file URI returned by the server into a local path.
+// Anything that isn't a file URI is returned unchanged.
+func ToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+
+	p := u.Path
+	if filepath.Separator == '\\' && len(p) >= 3 && p[0] == '/' && p[2] == ':' {
+		p = p[1:]
+	}
+	return filepath.FromSlash(p)
+}
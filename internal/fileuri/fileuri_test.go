@@ -0,0 +1,127 @@
+package fileuri
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFromPath(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"plain", "/src/main.cpp", "file:///src/main.cpp"},
+		{"space", "/my project/a b.cpp", "file:///my%20project/a%20b.cpp"},
+		{"hash and question mark", "/src/#1/what?.cpp", "file:///src/%231/what%3F.cpp"},
+		{"percent", "/src/100%.cpp", "file:///src/100%25.cpp"},
+		{"non-ASCII", "/src/größe/日本.cpp", "file:///src/gr%C3%B6%C3%9Fe/%E6%97%A5%E6%9C%AC.cpp"},
+		{"unclean", "/src/./lib/../main.cpp", "file:///src/main.cpp"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FromPath(tt.path); got != tt.want {
+				t.Errorf("FromPath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToPath(t *testing.T) {
+	tests := []struct {
+		name string
+		uri  string
+		want string
+	}{
+		{"plain", "file:///src/main.cpp", "/src/main.cpp"},
+		{"space", "file:///my%20project/a%20b.cpp", "/my project/a b.cpp"},
+		{"lowercase escapes", "file:///src/%e6%97%a5.cpp", "/src/日.cpp"},
+		{"unescaped characters", "file:///src/a+b.cpp", "/src/a+b.cpp"},
+		{"hash", "file:///src/%231/x.cpp", "/src/#1/x.cpp"},
+		{"localhost", "file://localhost/src/main.cpp", "/src/main.cpp"},
+		{"not a file URI", "untitled:Untitled-1", "untitled:Untitled-1"},
+		{"bare path", "/src/main.cpp", "/src/main.cpp"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ToPath(tt.uri); got != tt.want {
+				t.Errorf("ToPath(%q) = %q, want %q", tt.uri, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, path := range []string{
+		"/src/main.cpp",
+		"/my project/a b.cpp",
+		"/src/#1/what?.cpp",
+		"/src/100%.cpp",
+		"/src/größe/日本.cpp",
+		"/src/a;b=c@d.cpp",
+	} {
+		if got := ToPath(FromPath(path)); got != path {
+			t.Errorf("Round trip of %q gave %q", path, got)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "fileuri-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// Resolve the temp dir itself, which may be behind a symlink (macOS)
+	tmpDir, err = filepath.EvalSymlinks(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to resolve temp dir: %v", err)
+	}
+
+	realFile := filepath.Join(tmpDir, "real.cpp")
+	if err := os.WriteFile(realFile, []byte("int x;\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	link := filepath.Join(tmpDir, "link.cpp")
+	if err := os.Symlink(realFile, link); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working dir: %v", err)
+	}
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatalf("Failed to change dir: %v", err)
+	}
+	defer os.Chdir(wd)
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"absolute", realFile, realFile},
+		{"relative", "real.cpp", realFile},
+		{"dot", ".", tmpDir},
+		{"symlink", link, realFile},
+		{"missing file", "missing.cpp", filepath.Join(tmpDir, "missing.cpp")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Normalize(tt.path); got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	if got, want := FromPath("link.cpp"), FromPath(realFile); got != want {
+		t.Errorf("Expected symlink URI %q to match target URI %q", got, want)
+	}
+	t.Logf("✓ %s", FromPath("real.cpp"))
+}
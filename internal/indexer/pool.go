@@ -0,0 +1,437 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"clangd-parser/internal/fileuri"
+	"clangd-parser/internal/lsp"
+	"clangd-parser/internal/model"
+	"clangd-parser/internal/parser"
+)
+
+// Worker is the part of lsp.Client the pool needs
+type Worker interface {
+	OpenDocument(ctx context.Context, filePath string) error
+	CloseDocument(ctx context.Context, filePath string)
+	DocumentSymbols(ctx context.Context, filePath string) ([]lsp.DocumentSymbol, error)
+	Diagnostics(filePath string) ([]lsp.Diagnostic, bool)
+	Hover(ctx context.Context, filePath string, pos lsp.Position) (*lsp.Hover, error)
+	PrepareCallHierarchy(ctx context.Context, filePath string, pos lsp.Position) ([]lsp.CallHierarchyItem, error)
+	IncomingCalls(ctx context.Context, item lsp.CallHierarchyItem) ([]lsp.CallHierarchyIncomingCall, error)
+	OutgoingCalls(ctx context.Context, item lsp.CallHierarchyItem) ([]lsp.CallHierarchyOutgoingCall, error)
+	PrepareTypeHierarchy(ctx context.Context, filePath string, pos lsp.Position) ([]lsp.TypeHierarchyItem, error)
+	Supertypes(ctx context.Context, item lsp.TypeHierarchyItem) ([]lsp.TypeHierarchyItem, error)
+	Subtypes(ctx context.Context, item lsp.TypeHierarchyItem) ([]lsp.TypeHierarchyItem, error)
+	LegacyTypeHierarchy(ctx context.Context, filePath string, pos lsp.Position, resolve, direction int) (*lsp.TypeHierarchyItem, error)
+	References(ctx context.Context, filePath string, pos lsp.Position, includeDeclaration bool) ([]lsp.Location, error)
+	Definition(ctx context.Context, filePath string, pos lsp.Position) ([]lsp.Location, error)
+	Declaration(ctx context.Context, filePath string, pos lsp.Position) ([]lsp.Location, error)
+	FoldingRanges(ctx context.Context, filePath string) ([]lsp.FoldingRange, error)
+	Close(ctx context.Context) error
+
+	// PositionEncoding is the encoding of the positions the server reports
+	PositionEncoding() lsp.PositionEncoding
+
+	// Alive reports whether the server behind the worker is still running
+	Alive() bool
+	// Kill stops a crashed or hung server without the shutdown handshake
+	Kill() error
+}
+
+const (
+	// DefaultMaxCrashes is how often a file may take down a server before
+	// it is quarantined
+	DefaultMaxCrashes = 2
+
+	// DefaultRestartBackoff is the delay before the first restart of a
+	// worker. It doubles on each consecutive restart up to maxRestartBackoff.
+	DefaultRestartBackoff = 500 * time.Millisecond
+	maxRestartBackoff     = 30 * time.Second
+)
+
+// WorkerFactory starts a new worker (typically a clangd instance)
+type WorkerFactory func(ctx context.Context) (Worker, error)
+
+// EnrichFunc adds information to a file's chunks. It runs while the file's
+// document is still open on the worker, concurrently across workers.
+type EnrichFunc func(ctx context.Context, w Worker, filePath string, chunks []model.SemanticChunk)
+
+// Options configures a pool run
+type Options struct {
+	Jobs    int
+	Factory WorkerFactory
+
+	// Enrich runs in order on every successfully parsed file
+	Enrich []EnrichFunc
+
+	// Parse selects the symbol kinds to extract, groups tiny chunks
+	// before enrichment and splits oversized ones after it
+	Parse parser.Options
+
+	// Progress, if non-nil, is called once per file from a single goroutine
+	Progress func(FileResult)
+
+	// MaxCrashes is how often a file may crash or hang a worker before it
+	// is quarantined. Zero means DefaultMaxCrashes.
+	MaxCrashes int
+
+	// RestartBackoff is the delay before restarting a crashed worker. Zero
+	// means DefaultRestartBackoff.
+	RestartBackoff time.Duration
+
+	// FileTimeout bounds the processing of each file including enrichment.
+	// Zero leaves only the client's per-request timeouts.
+	FileTimeout time.Duration
+}
+
+// Quarantine records a file that was skipped because it repeatedly crashed
+// or hung the language server
+type Quarantine struct {
+	File    string `json:"file"`
+	Reason  string `json:"reason"`
+	Crashes int    `json:"crashes"`
+}
+
+// FileResult is the outcome of processing a single file
+type FileResult struct {
+	Index  int // position of the file in the input list
+	File   string
+	Worker int
+	Chunks []model.SemanticChunk
+	Err    error
+
+	// Restarts is how often the worker was restarted while processing
+	// the file
+	Restarts int
+
+	// Quarantined is set if the file was given up on after crashing the
+	// worker too often
+	Quarantined *Quarantine
+}
+
+// WorkerStats holds per-worker progress and error accounting
+type WorkerStats struct {
+	ID       int `json:"id"`
+	Files    int `json:"files"`
+	Chunks   int `json:"chunks"`
+	Errors   int `json:"errors"`
+	Restarts int `json:"restarts"`
+}
+
+// Result is the merged outcome of a pool run
+type Result struct {
+	Chunks       []model.SemanticChunk
+	SuccessCount int
+	ErrorCount   int
+	Workers      []WorkerStats
+
+	// Quarantined lists skipped files in input order
+	Quarantined []Quarantine
+}
+
+// Run processes files with a pool of workers. Chunks are merged in the order
+// of the input file list regardless of which worker finished first.
+//
+// A worker whose server exits or stops answering is killed and restarted,
+// and the file it was working on is retried. Files that keep crashing the
+// server are quarantined and reported in the result.
+//
+// Canceling ctx stops handing out files and cancels the requests in flight.
+// Run then returns the partial result together with ctx's error.
+//
+// No workers are started for an empty file list.
+func Run(ctx context.Context, files []string, opts Options) (*Result, error) {
+	if len(files) == 0 {
+		return &Result{}, nil
+	}
+
+	jobs := opts.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+	if jobs > len(files) {
+		jobs = len(files)
+	}
+
+	workers, err := startWorkers(ctx, jobs, opts.Factory)
+	if err != nil {
+		return nil, err
+	}
+
+	sups := make([]*supervisor, len(workers))
+	for id, w := range workers {
+		sups[id] = newSupervisor(id, w, opts)
+	}
+	defer func() {
+		for _, s := range sups {
+			s.close()
+		}
+	}()
+
+	tasks := make(chan int)
+	results := make(chan FileResult)
+
+	var wg sync.WaitGroup
+	for _, s := range sups {
+		wg.Add(1)
+		go func(s *supervisor) {
+			defer wg.Done()
+			for i := range tasks {
+				if ctx.Err() != nil {
+					return
+				}
+				results <- s.process(ctx, i, files[i])
+			}
+		}(s)
+	}
+
+	go func() {
+		defer close(tasks)
+		for i := range files {
+			select {
+			case tasks <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	perFile := make([][]model.SemanticChunk, len(files))
+	quarantined := make([]*Quarantine, len(files))
+	res := &Result{Workers: make([]WorkerStats, len(workers))}
+	for id := range res.Workers {
+		res.Workers[id].ID = id
+	}
+
+	for r := range results {
+		stats := &res.Workers[r.Worker]
+		stats.Files++
+		stats.Restarts += r.Restarts
+		quarantined[r.Index] = r.Quarantined
+		if r.Err != nil {
+			stats.Errors++
+			res.ErrorCount++
+		} else {
+			stats.Chunks += len(r.Chunks)
+			res.SuccessCount++
+			perFile[r.Index] = r.Chunks
+		}
+
+		if opts.Progress != nil {
+			opts.Progress(r)
+		}
+	}
+
+	for _, chunks := range perFile {
+		res.Chunks = append(res.Chunks, chunks...)
+	}
+	for _, q := range quarantined {
+		if q != nil {
+			res.Quarantined = append(res.Quarantined, *q)
+		}
+	}
+
+	return res, ctx.Err()
+}
+
+// startWorkers starts n workers concurrently, closing any that did start if
+// one of them fails
+func startWorkers(ctx context.Context, n int, factory WorkerFactory) ([]Worker, error) {
+	workers := make([]Worker, n)
+	errs := make([]error, n)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			workers[i], errs[i] = factory(ctx)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			for _, w := range workers {
+				if w != nil {
+					w.Close(context.Background())
+				}
+			}
+			return nil, fmt.Errorf("start worker %d: %w", i, err)
+		}
+	}
+
+	return workers, nil
+}
+
+// supervisor owns one worker slot, restarting its server when it crashes
+type supervisor struct {
+	id      int
+	worker  Worker // nil while the server is down
+	factory WorkerFactory
+	enrich  []EnrichFunc
+	timeout time.Duration // per file
+
+	parse parser.Options
+
+	maxCrashes int
+	minBackoff time.Duration
+	backoff    time.Duration // delay before the next restart
+}
+
+func newSupervisor(id int, w Worker, opts Options) *supervisor {
+	s := &supervisor{
+		id:         id,
+		worker:     w,
+		factory:    opts.Factory,
+		enrich:     opts.Enrich,
+		timeout:    opts.FileTimeout,
+		parse:      opts.Parse,
+		maxCrashes: opts.MaxCrashes,
+		minBackoff: opts.RestartBackoff,
+	}
+	if s.maxCrashes < 1 {
+		s.maxCrashes = DefaultMaxCrashes
+	}
+	if s.minBackoff <= 0 {
+		s.minBackoff = DefaultRestartBackoff
+	}
+	s.backoff = s.minBackoff
+	return s
+}
+
+// process runs a file on the worker, restarting the server and retrying the
+// file after a crash until it succeeds or has crashed too often
+func (s *supervisor) process(ctx context.Context, i int, file string) FileResult {
+	r := FileResult{Index: i, File: file, Worker: s.id}
+
+	for crashes := 0; ; {
+		if s.worker == nil {
+			if err := s.restart(ctx); err != nil {
+				r.Err = fmt.Errorf("restart worker %d: %w", s.id, err)
+				return r
+			}
+			r.Restarts++
+		}
+
+		chunks, err := s.processOnce(ctx, file)
+		if ctx.Err() != nil {
+			// Interrupted rather than crashed
+			r.Err = ctx.Err()
+			return r
+		}
+
+		reason, crashed := s.crashed(err)
+		if !crashed {
+			s.backoff = s.minBackoff
+			r.Chunks, r.Err = chunks, err
+			return r
+		}
+
+		s.worker.Kill()
+		s.worker = nil
+		crashes++
+
+		if crashes >= s.maxCrashes {
+			r.Err = fmt.Errorf("quarantined after %d crashes: %s", crashes, reason)
+			r.Quarantined = &Quarantine{File: file, Reason: reason, Crashes: crashes}
+			return r
+		}
+	}
+}
+
+// crashed reports whether the server died or hung while processing a file,
+// as opposed to returning an ordinary error
+func (s *supervisor) crashed(err error) (string, bool) {
+	if !s.worker.Alive() {
+		if err != nil {
+			return "server exited: " + err.Error(), true
+		}
+		return "server exited", true
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "server stopped responding: " + err.Error(), true
+	}
+	return "", false
+}
+
+// processOnce runs a single attempt at file bounded by the file timeout
+func (s *supervisor) processOnce(ctx context.Context, file string) ([]model.SemanticChunk, error) {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	return ProcessFile(ctx, s.worker, file, s.parse, s.enrich...)
+}
+
+// restart starts a new server after waiting out the backoff
+func (s *supervisor) restart(ctx context.Context) error {
+	select {
+	case <-time.After(s.backoff):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	s.backoff = min(s.backoff*2, maxRestartBackoff)
+
+	w, err := s.factory(ctx)
+	if err != nil {
+		return err
+	}
+	s.worker = w
+	return nil
+}
+
+func (s *supervisor) close() {
+	if s.worker != nil {
+		s.worker.Close(context.Background())
+	}
+}
+
+// ProcessFile parses a single file on w into chunks, runs the enrichment
+// passes while the document is open and attaches diagnostics. After
+// enrichment, tiny chunks are grouped and oversized ones split, as opts asks
+// for.
+// The path is normalized first so chunks and server results agree on it.
+func ProcessFile(ctx context.Context, w Worker, filePath string, opts parser.Options, enrich ...EnrichFunc) ([]model.SemanticChunk, error) {
+	filePath = fileuri.Normalize(filePath)
+
+	if err := w.OpenDocument(ctx, filePath); err != nil {
+		return nil, err
+	}
+	defer w.CloseDocument(ctx, filePath)
+
+	symbols, err := w.DocumentSymbols(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := parser.ConvertSymbolsToChunks(symbols, filePath, w.PositionEncoding(), opts.Kinds)
+	for _, fn := range enrich {
+		fn(ctx, w, filePath, chunks)
+	}
+
+	// Groups are formed once their members have been enriched, which needs
+	// the members' name positions
+	chunks = parser.GroupChunks(chunks, filePath, opts.Size.MinTokens)
+	diags, published := w.Diagnostics(filePath)
+	parser.AttachDiagnostics(chunks, diags, published)
+
+	if opts.Size.MaxTokens > 0 && parser.HasOversized(chunks, opts.Size.MaxTokens) {
+		// Without folding ranges cuts fall between nested chunks and
+		// statements only
+		folds, _ := w.FoldingRanges(ctx, filePath)
+		chunks = parser.SplitChunks(chunks, filePath, folds, opts.Size.MaxTokens)
+	}
+
+	return chunks, nil
+}
@@ -0,0 +1,367 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"clangd-parser/internal/lsp"
+	"clangd-parser/internal/model"
+)
+
+// fakeWorker returns a single function symbol named after the file
+type fakeWorker struct {
+	closed *int32
+	open   map[string]bool
+	dead   bool
+
+	// crashes, if set, makes the worker die on files listed with a
+	// positive count, decrementing it each time
+	crashes map[string]int
+	mu      *sync.Mutex
+}
+
+func newFakeWorker(closed *int32) *fakeWorker {
+	return &fakeWorker{closed: closed, open: make(map[string]bool)}
+}
+
+func (w *fakeWorker) OpenDocument(ctx context.Context, filePath string) error {
+	w.open[filePath] = true
+	return nil
+}
+
+func (w *fakeWorker) CloseDocument(ctx context.Context, filePath string) {
+	delete(w.open, filePath)
+}
+
+func (w *fakeWorker) Hover(ctx context.Context, filePath string, pos lsp.Position) (*lsp.Hover, error) {
+	return nil, nil
+}
+
+func (w *fakeWorker) PrepareCallHierarchy(ctx context.Context, filePath string, pos lsp.Position) ([]lsp.CallHierarchyItem, error) {
+	return nil, nil
+}
+
+func (w *fakeWorker) IncomingCalls(ctx context.Context, item lsp.CallHierarchyItem) ([]lsp.CallHierarchyIncomingCall, error) {
+	return nil, nil
+}
+
+func (w *fakeWorker) OutgoingCalls(ctx context.Context, item lsp.CallHierarchyItem) ([]lsp.CallHierarchyOutgoingCall, error) {
+	return nil, nil
+}
+
+func (w *fakeWorker) PrepareTypeHierarchy(ctx context.Context, filePath string, pos lsp.Position) ([]lsp.TypeHierarchyItem, error) {
+	return nil, nil
+}
+
+func (w *fakeWorker) Supertypes(ctx context.Context, item lsp.TypeHierarchyItem) ([]lsp.TypeHierarchyItem, error) {
+	return nil, nil
+}
+
+func (w *fakeWorker) Subtypes(ctx context.Context, item lsp.TypeHierarchyItem) ([]lsp.TypeHierarchyItem, error) {
+	return nil, nil
+}
+
+func (w *fakeWorker) LegacyTypeHierarchy(ctx context.Context, filePath string, pos lsp.Position, resolve, direction int) (*lsp.TypeHierarchyItem, error) {
+	return nil, nil
+}
+
+func (w *fakeWorker) References(ctx context.Context, filePath string, pos lsp.Position, includeDeclaration bool) ([]lsp.Location, error) {
+	return nil, nil
+}
+
+func (w *fakeWorker) Definition(ctx context.Context, filePath string, pos lsp.Position) ([]lsp.Location, error) {
+	return nil, nil
+}
+
+func (w *fakeWorker) Declaration(ctx context.Context, filePath string, pos lsp.Position) ([]lsp.Location, error) {
+	return nil, nil
+}
+
+func (w *fakeWorker) FoldingRanges(ctx context.Context, filePath string) ([]lsp.FoldingRange, error) {
+	return nil, nil
+}
+
+func (w *fakeWorker) DocumentSymbols(ctx context.Context, filePath string) ([]lsp.DocumentSymbol, error) {
+	if w.crashes != nil {
+		w.mu.Lock()
+		n := w.crashes[filepath.Base(filePath)]
+		if n > 0 {
+			w.crashes[filepath.Base(filePath)] = n - 1
+		}
+		w.mu.Unlock()
+		if n > 0 {
+			w.dead = true
+			return nil, errors.New("connection closed")
+		}
+	}
+	if filepath.Base(filePath) == "block.cpp" {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if filepath.Base(filePath) == "broken.cpp" {
+		return nil, errors.New("boom")
+	}
+	// Finish out of order to exercise deterministic merging
+	if filepath.Base(filePath) == "a.cpp" {
+		time.Sleep(20 * time.Millisecond)
+	}
+	return []lsp.DocumentSymbol{
+		{
+			Name: filepath.Base(filePath),
+			Kind: lsp.SymbolKindFunction,
+			Range: lsp.Range{
+				Start: lsp.Position{Line: 0},
+				End:   lsp.Position{Line: 0},
+			},
+		},
+	}, nil
+}
+
+func (w *fakeWorker) Diagnostics(filePath string) ([]lsp.Diagnostic, bool) {
+	return nil, true
+}
+
+func (w *fakeWorker) Close(ctx context.Context) error {
+	atomic.AddInt32(w.closed, 1)
+	return nil
+}
+
+func (w *fakeWorker) PositionEncoding() lsp.PositionEncoding {
+	return lsp.PositionEncodingUTF16
+}
+
+func (w *fakeWorker) Alive() bool {
+	return !w.dead
+}
+
+func (w *fakeWorker) Kill() error {
+	atomic.AddInt32(w.closed, 1)
+	return nil
+}
+
+func TestRunMergesInInputOrder(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "indexer-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	names := []string{"a.cpp", "b.cpp", "broken.cpp", "c.cpp", "d.cpp"}
+	var files []string
+	for _, name := range names {
+		path := filepath.Join(tmpDir, name)
+		if err := os.WriteFile(path, []byte("void f() {}\n"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+		files = append(files, path)
+	}
+
+	var closed int32
+	factory := func(ctx context.Context) (Worker, error) {
+		return newFakeWorker(&closed), nil
+	}
+
+	progressCalls := 0
+	var enrichedWhileOpen int32
+	result, err := Run(context.Background(), files, Options{
+		Jobs:    3,
+		Factory: factory,
+		Enrich: []EnrichFunc{func(ctx context.Context, w Worker, filePath string, chunks []model.SemanticChunk) {
+			if w.(*fakeWorker).open[filePath] {
+				atomic.AddInt32(&enrichedWhileOpen, 1)
+			}
+		}},
+		Progress: func(FileResult) { progressCalls++ },
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if enrichedWhileOpen != 4 {
+		t.Errorf("Expected enrichment on 4 open documents, got %d", enrichedWhileOpen)
+	}
+	if progressCalls != len(files) {
+		t.Errorf("Expected %d progress calls, got %d", len(files), progressCalls)
+	}
+	if result.SuccessCount != 4 || result.ErrorCount != 1 {
+		t.Errorf("Expected 4 successes and 1 error, got %d and %d", result.SuccessCount, result.ErrorCount)
+	}
+
+	want := []string{"a.cpp", "b.cpp", "c.cpp", "d.cpp"}
+	if len(result.Chunks) != len(want) {
+		t.Fatalf("Expected %d chunks, got %d", len(want), len(result.Chunks))
+	}
+	for i, name := range want {
+		if result.Chunks[i].Name != name {
+			t.Errorf("Chunk %d: expected %s, got %s", i, name, result.Chunks[i].Name)
+		}
+	}
+
+	nFiles, chunks, errs := 0, 0, 0
+	for _, w := range result.Workers {
+		nFiles += w.Files
+		chunks += w.Chunks
+		errs += w.Errors
+	}
+	if nFiles != 5 || chunks != 4 || errs != 1 {
+		t.Errorf("Worker stats don't add up: files=%d chunks=%d errors=%d", nFiles, chunks, errs)
+	}
+
+	if closed != 3 {
+		t.Errorf("Expected 3 workers to be closed, got %d", closed)
+	}
+}
+
+func TestRunWorkerStartFailure(t *testing.T) {
+	var closed int32
+	calls := int32(0)
+	factory := func(ctx context.Context) (Worker, error) {
+		if atomic.AddInt32(&calls, 1) == 2 {
+			return nil, errors.New("no clangd")
+		}
+		return newFakeWorker(&closed), nil
+	}
+
+	_, err := Run(context.Background(), []string{"a.cpp", "b.cpp", "c.cpp"}, Options{Jobs: 3, Factory: factory})
+	if err == nil {
+		t.Fatal("Expected error when a worker fails to start")
+	}
+	if closed != 2 {
+		t.Errorf("Expected the 2 started workers to be closed, got %d", closed)
+	}
+}
+
+func TestRunNoFiles(t *testing.T) {
+	factory := func(ctx context.Context) (Worker, error) {
+		t.Error("Expected no worker to be started without files")
+		return nil, errors.New("unexpected start")
+	}
+
+	result, err := Run(context.Background(), nil, Options{Jobs: 4, Factory: factory})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(result.Chunks) != 0 || result.SuccessCount != 0 {
+		t.Errorf("Expected an empty result, got %+v", result)
+	}
+}
+
+func TestRunRestartsCrashedWorkers(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "indexer-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	var files []string
+	for _, name := range []string{"a.cpp", "flaky.cpp", "crash.cpp", "b.cpp"} {
+		path := filepath.Join(tmpDir, name)
+		if err := os.WriteFile(path, []byte("void f() {}\n"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+		files = append(files, path)
+	}
+
+	// flaky.cpp crashes once and then succeeds, crash.cpp always crashes
+	var mu sync.Mutex
+	crashes := map[string]int{"flaky.cpp": 1, "crash.cpp": 100}
+	var closed, started int32
+	factory := func(ctx context.Context) (Worker, error) {
+		atomic.AddInt32(&started, 1)
+		w := newFakeWorker(&closed)
+		w.crashes, w.mu = crashes, &mu
+		return w, nil
+	}
+
+	result, err := Run(context.Background(), files, Options{
+		Jobs:           1,
+		Factory:        factory,
+		MaxCrashes:     3,
+		RestartBackoff: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if result.SuccessCount != 3 || result.ErrorCount != 1 {
+		t.Errorf("Expected 3 successes and 1 error, got %d and %d", result.SuccessCount, result.ErrorCount)
+	}
+	want := []string{"a.cpp", "flaky.cpp", "b.cpp"}
+	if len(result.Chunks) != len(want) {
+		t.Fatalf("Expected %d chunks, got %d", len(want), len(result.Chunks))
+	}
+	for i, name := range want {
+		if result.Chunks[i].Name != name {
+			t.Errorf("Chunk %d: expected %s, got %s", i, name, result.Chunks[i].Name)
+		}
+	}
+
+	if len(result.Quarantined) != 1 {
+		t.Fatalf("Expected 1 quarantined file, got %+v", result.Quarantined)
+	}
+	q := result.Quarantined[0]
+	if filepath.Base(q.File) != "crash.cpp" || q.Crashes != 3 || q.Reason == "" {
+		t.Errorf("Unexpected quarantine entry: %+v", q)
+	}
+
+	// 1 crash on flaky.cpp and 3 on crash.cpp, each followed by a restart
+	if started != 5 || result.Workers[0].Restarts != 4 {
+		t.Errorf("Expected 4 restarts, got %d starts and %d restarts", started, result.Workers[0].Restarts)
+	}
+	if closed != started {
+		t.Errorf("Expected all %d workers to be stopped, got %d", started, closed)
+	}
+	t.Logf("✓ Quarantined %s: %s", filepath.Base(q.File), q.Reason)
+}
+
+func TestRunCanceled(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "indexer-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	var files []string
+	for _, name := range []string{"a.cpp", "block.cpp", "c.cpp"} {
+		path := filepath.Join(tmpDir, name)
+		if err := os.WriteFile(path, []byte("void f() {}\n"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+		files = append(files, path)
+	}
+
+	var closed int32
+	factory := func(ctx context.Context) (Worker, error) {
+		return newFakeWorker(&closed), nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result, err := Run(ctx, files, Options{
+		Jobs:    1,
+		Factory: factory,
+		Progress: func(r FileResult) {
+			if filepath.Base(r.File) == "a.cpp" {
+				time.AfterFunc(20*time.Millisecond, cancel)
+			}
+		},
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	if result.SuccessCount != 1 || result.ErrorCount != 1 {
+		t.Errorf("Expected c.cpp to be skipped, got %d successes and %d errors", result.SuccessCount, result.ErrorCount)
+	}
+	if len(result.Quarantined) != 0 || result.Workers[0].Restarts != 0 {
+		t.Errorf("Cancellation must not count as a crash: %+v", result)
+	}
+	if closed != 1 {
+		t.Errorf("Expected the worker to be closed, got %d", closed)
+	}
+}
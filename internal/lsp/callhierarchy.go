@@ -0,0 +1,59 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+
+	"clangd-parser/internal/fileuri"
+)
+
+// PrepareCallHierarchy resolves the function at pos in an open document into
+// call hierarchy items
+func (c *Client) PrepareCallHierarchy(ctx context.Context, filePath string, pos Position) ([]CallHierarchyItem, error) {
+	if !c.init.Capabilities.CallHierarchyProvider {
+		return nil, fmt.Errorf("prepareCallHierarchy: %w", ErrNotSupported)
+	}
+
+	ctx, cancel := withTimeout(ctx, c.Timeouts.Request)
+	defer cancel()
+
+	params := map[string]any{
+		"textDocument": map[string]any{
+			"uri": fileuri.FromPath(filePath),
+		},
+		"position": pos,
+	}
+
+	var items []CallHierarchyItem
+	if err := c.call(ctx, "textDocument/prepareCallHierarchy", params, &items); err != nil {
+		return nil, fmt.Errorf("prepareCallHierarchy: %w", err)
+	}
+
+	return items, nil
+}
+
+// IncomingCalls returns the callers of item
+func (c *Client) IncomingCalls(ctx context.Context, item CallHierarchyItem) ([]CallHierarchyIncomingCall, error) {
+	ctx, cancel := withTimeout(ctx, c.Timeouts.Request)
+	defer cancel()
+
+	var calls []CallHierarchyIncomingCall
+	if err := c.call(ctx, "callHierarchy/incomingCalls", map[string]any{"item": item}, &calls); err != nil {
+		return nil, fmt.Errorf("incomingCalls: %w", err)
+	}
+
+	return calls, nil
+}
+
+// OutgoingCalls returns the functions item calls
+func (c *Client) OutgoingCalls(ctx context.Context, item CallHierarchyItem) ([]CallHierarchyOutgoingCall, error) {
+	ctx, cancel := withTimeout(ctx, c.Timeouts.Request)
+	defer cancel()
+
+	var calls []CallHierarchyOutgoingCall
+	if err := c.call(ctx, "callHierarchy/outgoingCalls", map[string]any{"item": item}, &calls); err != nil {
+		return nil, fmt.Errorf("outgoingCalls: %w", err)
+	}
+
+	return calls, nil
+}
@@ -0,0 +1,74 @@
+package lsp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// ErrNotSupported is returned for requests the server didn't advertise a
+// capability for
+var ErrNotSupported = errors.New("not supported by server")
+
+// ServerInfo identifies the server as reported in the initialize result
+type ServerInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+func (i ServerInfo) String() string {
+	switch {
+	case i.Version == "":
+		return i.Name
+	case strings.HasPrefix(i.Version, i.Name):
+		return i.Version // clangd reports "clangd version 17.0.6 ..."
+	}
+	return i.Name + " " + i.Version
+}
+
+// InitializeResult is the server's answer to initialize
+type InitializeResult struct {
+	Capabilities ServerCapabilities `json:"capabilities"`
+	ServerInfo   *ServerInfo        `json:"serverInfo,omitempty"`
+
+	// OffsetEncoding is clangd's extension announcing the position encoding
+	// before LSP 3.17 standardized positionEncoding
+	OffsetEncoding PositionEncoding `json:"offsetEncoding,omitempty"`
+}
+
+// ServerCapabilities lists the features the server advertised that the
+// client makes use of. Raw keeps the complete object.
+type ServerCapabilities struct {
+	PositionEncoding PositionEncoding `json:"positionEncoding,omitempty"`
+
+	DocumentSymbolProvider Capability `json:"documentSymbolProvider"`
+	HoverProvider          Capability `json:"hoverProvider"`
+	DefinitionProvider     Capability `json:"definitionProvider"`
+	DeclarationProvider    Capability `json:"declarationProvider"`
+	ReferencesProvider     Capability `json:"referencesProvider"`
+	CallHierarchyProvider  Capability `json:"callHierarchyProvider"`
+	TypeHierarchyProvider  Capability `json:"typeHierarchyProvider"`
+	FoldingRangeProvider   Capability `json:"foldingRangeProvider"`
+
+	Raw json.RawMessage `json:"-"`
+}
+
+func (c *ServerCapabilities) UnmarshalJSON(data []byte) error {
+	type plain ServerCapabilities
+	if err := json.Unmarshal(data, (*plain)(c)); err != nil {
+		return err
+	}
+	c.Raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// Capability is a provider flag. Servers announce providers either as a
+// boolean or as an options object, which also means supported.
+type Capability bool
+
+func (c *Capability) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	*c = Capability(!bytes.Equal(data, []byte("false")) && !bytes.Equal(data, []byte("null")))
+	return nil
+}
@@ -2,70 +2,150 @@ package lsp
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
+	"sync/atomic"
 	"time"
 
 	"github.com/sourcegraph/jsonrpc2"
+
+	"clangd-parser/internal/fileuri"
+	"clangd-parser/internal/lsp/transcript"
 )
 
+// Timeouts is the client's timeout policy. Each bound applies on top of the
+// context passed to a call; zero disables it.
+type Timeouts struct {
+	// Initialize bounds the initialize handshake
+	Initialize time.Duration
+
+	// Request bounds each per-file request such as documentSymbol
+	Request time.Duration
+
+	// Diagnostics bounds the wait for diagnostics of each file. Unlike the
+	// others, zero doesn't wait at all.
+	Diagnostics time.Duration
+
+	// Shutdown bounds the shutdown handshake in Close
+	Shutdown time.Duration
+}
+
+// DefaultTimeouts returns the policy new clients start with
+func DefaultTimeouts() Timeouts {
+	return Timeouts{
+		Initialize:  10 * time.Second,
+		Request:     30 * time.Second,
+		Diagnostics: 2 * time.Second,
+		Shutdown:    5 * time.Second,
+	}
+}
+
 type Client struct {
-	conn    *jsonrpc2.Conn
-	cmd     *exec.Cmd
-	rootURI string
+	conn      *jsonrpc2.Conn
+	stream    *closableStream
+	transport Transport
+	spec      ServerSpec
+	rootURI   string
+	nextID    atomic.Uint64
+	init      InitializeResult
+
+	progress    *progressTracker
+	diagnostics *diagnosticStore
+
+	// Timeouts can be changed after the client is created; the initialize
+	// handshake always uses DefaultTimeouts
+	Timeouts Timeouts
 }
 
-// NewClient starts clangd and initializes the LSP connection
-func NewClient(compileDBPath, rootPath string) (*Client, error) {
-	// Start clangd process
-	cmd := exec.Command("clangd",
-		"--compile-commands-dir="+compileDBPath,
-		"--background-index",
-		"--log=error",
-	)
+// NewClient starts clangd and initializes the LSP connection. ctx bounds
+// the startup only, not the lifetime of the server.
+func NewClient(ctx context.Context, compileDBPath, rootPath string) (*Client, error) {
+	return NewClientWithSpec(ctx, ClangdSpec(compileDBPath), rootPath)
+}
 
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		return nil, fmt.Errorf("stdin pipe: %w", err)
-	}
+// NewClientWithSpec starts the language server described by spec and
+// initializes the LSP connection
+func NewClientWithSpec(ctx context.Context, spec ServerSpec, rootPath string) (*Client, error) {
+	return NewClientWithTransport(ctx, NewStdio(spec), spec, rootPath)
+}
 
-	stdout, err := cmd.StdoutPipe()
+// NewClientFromStream initializes an LSP connection over an already
+// established stream, such as the pipe of an in-process test server
+func NewClientFromStream(ctx context.Context, rwc io.ReadWriteCloser, spec ServerSpec, rootPath string) (*Client, error) {
+	return NewClientWithTransport(ctx, streamTransport{rwc}, spec, rootPath)
+}
+
+// NewClientWithTransport opens transport and initializes the LSP connection
+// over it. spec describes the server at the other end; its command is only
+// used by transports that launch the server.
+func NewClientWithTransport(ctx context.Context, transport Transport, spec ServerSpec, rootPath string) (*Client, error) {
+	rwc, err := transport.Open(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("stdout pipe: %w", err)
+		return nil, fmt.Errorf("start %s: %w", spec.Name, err)
 	}
 
-	if err := cmd.Start(); err != nil {
-		return nil, fmt.Errorf("start clangd: %w", err)
+	client := &Client{
+		stream:      &closableStream{ReadWriteCloser: rwc},
+		transport:   transport,
+		spec:        spec,
+		rootURI:     fileuri.FromPath(rootPath),
+		progress:    newProgressTracker(spec.Quirks.IndexProgressToken),
+		diagnostics: newDiagnosticStore(),
+		Timeouts:    DefaultTimeouts(),
 	}
 
 	// Create JSON-RPC connection
-	stream := jsonrpc2.NewBufferedStream(&stdrwc{stdout, stdin}, jsonrpc2.VSCodeObjectCodec{})
-	conn := jsonrpc2.NewConn(context.Background(), stream, jsonrpc2.HandlerWithError(
-		func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
-			// Handle server notifications/requests
-			return nil, nil
-		},
-	))
-
-	client := &Client{
-		conn:    conn,
-		cmd:     cmd,
-		rootURI: "file://" + rootPath,
+	stream := jsonrpc2.NewBufferedStream(client.stream, jsonrpc2.VSCodeObjectCodec{})
+	if spec.Transcript != nil {
+		stream = transcript.NewRecorder(spec.Transcript).Wrap(stream)
 	}
+	client.conn = jsonrpc2.NewConn(context.Background(), stream, jsonrpc2.HandlerWithError(client.handle))
 
 	// Initialize the LSP connection
-	if err := client.initialize(); err != nil {
-		client.Close()
+	if err := client.initialize(ctx); err != nil {
+		client.Close(context.Background())
 		return nil, fmt.Errorf("initialize: %w", err)
 	}
 
 	return client, nil
 }
 
-func (c *Client) initialize() error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+// handle processes server notifications and requests
+func (c *Client) handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+	if req.Params == nil {
+		return nil, nil
+	}
+
+	switch req.Method {
+	case "window/workDoneProgress/create":
+		var params createProgressParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		c.progress.create(params)
+
+	case "$/progress":
+		var params progressParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		c.progress.update(params)
+
+	case "textDocument/publishDiagnostics":
+		var params publishDiagnosticsParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		c.diagnostics.publish(params)
+	}
+
+	return nil, nil
+}
+
+func (c *Client) initialize(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, c.Timeouts.Initialize)
 	defer cancel()
 
 	initParams := map[string]any{
@@ -74,103 +154,301 @@ func (c *Client) initialize() error {
 		"capabilities": map[string]any{
 			"textDocument": map[string]any{
 				"documentSymbol": map[string]any{
-					"hierarchicalDocumentSymbolSupport": true,
+					"hierarchicalDocumentSymbolSupport": c.spec.Quirks.HierarchicalSymbols,
+				},
+				"publishDiagnostics": map[string]any{},
+				"hover": map[string]any{
+					"contentFormat": []string{"markdown", "plaintext"},
 				},
+				"callHierarchy": map[string]any{},
+				"typeHierarchy": map[string]any{},
+				"references":    map[string]any{},
+				"foldingRange":  map[string]any{},
 			},
+			"window": map[string]any{
+				"workDoneProgress": true,
+			},
+			"general": map[string]any{
+				"positionEncodings": supportedEncodings,
+			},
+			// clangd's name for positionEncodings before LSP 3.17
+			"offsetEncoding": supportedEncodings,
 		},
 	}
+	if c.spec.InitializationOptions != nil {
+		initParams["initializationOptions"] = c.spec.InitializationOptions
+	}
 
-	var result map[string]any
-	if err := c.conn.Call(ctx, "initialize", initParams, &result); err != nil {
+	if err := c.call(ctx, "initialize", initParams, &c.init); err != nil {
 		return err
 	}
 
 	return c.conn.Notify(ctx, "initialized", map[string]any{})
 }
 
-// GetServerInfo returns server information from initialization
-func (c *Client) GetServerInfo() (string, error) {
-	// Simple test method to verify connection works
-	return "clangd connection established", nil
+// Spec returns the server spec the client was started with
+func (c *Client) Spec() ServerSpec {
+	return c.spec
+}
+
+// ServerInfo returns the server name and version reported during
+// initialization. The spec name stands in for servers that don't say.
+func (c *Client) ServerInfo() ServerInfo {
+	if c.init.ServerInfo == nil || c.init.ServerInfo.Name == "" {
+		return ServerInfo{Name: c.spec.Name}
+	}
+	return *c.init.ServerInfo
+}
+
+// Capabilities returns the capabilities the server advertised
+func (c *Client) Capabilities() ServerCapabilities {
+	return c.init.Capabilities
+}
+
+// PositionEncoding returns the encoding the server chose for
+// Position.Character. Servers that don't say use UTF-16.
+func (c *Client) PositionEncoding() PositionEncoding {
+	switch {
+	case c.init.Capabilities.PositionEncoding != "":
+		return c.init.Capabilities.PositionEncoding
+	case c.init.OffsetEncoding != "":
+		return c.init.OffsetEncoding
+	}
+	return PositionEncodingUTF16
+}
+
+// InitializeResult returns the server's full answer to initialize
+func (c *Client) InitializeResult() InitializeResult {
+	return c.init
 }
 
-// Close shuts down the LSP connection
-func (c *Client) Close() error {
+// Close shuts down the LSP connection. The shutdown handshake is bounded by
+// ctx and the shutdown timeout; the connection is closed either way.
+func (c *Client) Close(ctx context.Context) error {
 	if c.conn == nil {
 		return nil
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if !c.transport.Owned() {
+		// Leave shared servers running for their other clients
+		return c.disconnect()
+	}
+
+	ctx, cancel := withTimeout(ctx, c.Timeouts.Shutdown)
 	defer cancel()
 
-	c.conn.Call(ctx, "shutdown", nil, nil)
+	c.call(ctx, "shutdown", nil, nil)
 	c.conn.Notify(ctx, "exit", nil)
-	c.conn.Close()
+	c.disconnect()
+
+	return c.transport.Wait()
+}
+
+// Alive reports whether the connection to the server is still up. It turns
+// false when the server process exits or the pipe breaks.
+func (c *Client) Alive() bool {
+	if c.conn == nil {
+		return false
+	}
 
-	if c.cmd != nil && c.cmd.Process != nil {
-		return c.cmd.Wait()
+	select {
+	case <-c.conn.DisconnectNotify():
+		return false
+	default:
+		return true
 	}
+}
+
+// Kill terminates the server without the shutdown handshake, for servers
+// that crashed or hang. Shared servers are only disconnected from. The
+// client can't be used afterwards.
+func (c *Client) Kill() error {
+	if c.conn == nil {
+		return nil
+	}
+
+	c.transport.Kill()
+	c.disconnect()
+	c.conn = nil
+
+	c.transport.Wait()
 	return nil
 }
 
-// stdrwc wraps stdin/stdout for jsonrpc2
-type stdrwc struct {
-	io.ReadCloser
-	io.WriteCloser
+// disconnect closes the stream under the connection and waits for the
+// connection to notice. Closing the jsonrpc2 connection itself races with a
+// late response, such as the answer to a canceled request, being delivered
+// at the same time.
+func (c *Client) disconnect() error {
+	err := c.stream.Close()
+	<-c.conn.DisconnectNotify()
+	return err
+}
+
+// closableStream ends reads with io.EOF once it was closed on our side, so
+// the connection shuts down without logging a protocol error
+type closableStream struct {
+	io.ReadWriteCloser
+	closed atomic.Bool
 }
 
-func (s *stdrwc) Close() error {
-	s.ReadCloser.Close()
-	return s.WriteCloser.Close()
+func (s *closableStream) Read(p []byte) (int, error) {
+	n, err := s.ReadWriteCloser.Read(p)
+	if err != nil && s.closed.Load() {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (s *closableStream) Close() error {
+	s.closed.Store(true)
+	return s.ReadWriteCloser.Close()
+}
+
+// call sends a request and waits for the result. If ctx is canceled or
+// times out first, the server is told to stop working on the request with
+// $/cancelRequest.
+func (c *Client) call(ctx context.Context, method string, params, result any) error {
+	id := jsonrpc2.ID{Num: c.nextID.Add(1)}
+
+	err := c.conn.Call(ctx, method, params, result, jsonrpc2.PickID(id))
+	if err != nil && ctx.Err() != nil {
+		c.conn.Notify(context.Background(), "$/cancelRequest", map[string]any{"id": id})
+	}
+	return err
+}
+
+// withTimeout bounds ctx by d unless d is zero
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
 }
 
-// Add this method to the Client struct
+// GetDocumentSymbols retrieves symbols from a C++ file, opening and closing
+// the document around the request
+func (c *Client) GetDocumentSymbols(ctx context.Context, filePath string) ([]DocumentSymbol, error) {
+	if err := c.OpenDocument(ctx, filePath); err != nil {
+		return nil, err
+	}
+	defer c.CloseDocument(ctx, filePath)
+
+	return c.DocumentSymbols(ctx, filePath)
+}
 
-// GetDocumentSymbols retrieves symbols from a C++ file
-func (c *Client) GetDocumentSymbols(filePath string) ([]DocumentSymbol, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+// OpenDocument sends the file's content to the server. Requests that need
+// an AST, such as hover, only work on open documents.
+func (c *Client) OpenDocument(ctx context.Context, filePath string) error {
+	ctx, cancel := withTimeout(ctx, c.Timeouts.Request)
 	defer cancel()
 
 	content, err := os.ReadFile(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("read file: %w", err)
+		return fmt.Errorf("read file: %w", err)
 	}
 
-	uri := "file://" + filePath
+	path := fileuri.Normalize(filePath)
+	uri := fileuri.FromPath(path)
 
-	// Open document
 	openParams := map[string]any{
 		"textDocument": map[string]any{
 			"uri":        uri,
-			"languageId": "cpp",
+			"languageId": c.spec.LanguageID(filePath),
 			"version":    1,
 			"text":       string(content),
 		},
 	}
 
+	c.diagnostics.opened(path)
 	if err := c.conn.Notify(ctx, "textDocument/didOpen", openParams); err != nil {
-		return nil, fmt.Errorf("didOpen: %w", err)
+		c.diagnostics.closed(path)
+		return fmt.Errorf("didOpen: %w", err)
 	}
 
-	// Request symbols
-	symbolParams := map[string]any{
+	return nil
+}
+
+// CloseDocument closes a document opened with OpenDocument to free memory.
+// It is sent even if ctx is already canceled, as it usually is after a
+// request timed out.
+func (c *Client) CloseDocument(ctx context.Context, filePath string) {
+	path := fileuri.Normalize(filePath)
+	uri := fileuri.FromPath(path)
+
+	// Stop accepting diagnostics first so the server's clearing publish
+	// doesn't overwrite them
+	c.diagnostics.closed(path)
+
+	closeParams := map[string]any{
 		"textDocument": map[string]any{
 			"uri": uri,
 		},
 	}
+	c.conn.Notify(context.WithoutCancel(ctx), "textDocument/didClose", closeParams)
+}
 
-	var symbols []DocumentSymbol
-	if err := c.conn.Call(ctx, "textDocument/documentSymbol", symbolParams, &symbols); err != nil {
-		return nil, fmt.Errorf("documentSymbol: %w", err)
+// DocumentSymbols retrieves symbols from an open document and waits for its
+// diagnostics
+func (c *Client) DocumentSymbols(ctx context.Context, filePath string) ([]DocumentSymbol, error) {
+	if !c.init.Capabilities.DocumentSymbolProvider {
+		return nil, fmt.Errorf("documentSymbol: %w", ErrNotSupported)
 	}
 
-	// Close document to free memory
-	closeParams := map[string]any{
+	ctx, cancel := withTimeout(ctx, c.Timeouts.Request)
+	defer cancel()
+
+	path := fileuri.Normalize(filePath)
+
+	symbolParams := map[string]any{
 		"textDocument": map[string]any{
-			"uri": uri,
+			"uri": fileuri.FromPath(path),
 		},
 	}
-	c.conn.Notify(context.Background(), "textDocument/didClose", closeParams)
+
+	var raw json.RawMessage
+	if err := c.call(ctx, "textDocument/documentSymbol", symbolParams, &raw); err != nil {
+		return nil, fmt.Errorf("documentSymbol: %w", err)
+	}
+
+	symbols, err := decodeDocumentSymbols(raw, func() *Text {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		return NewText(content, c.PositionEncoding())
+	})
+	if err != nil {
+		return nil, fmt.Errorf("documentSymbol: %w", err)
+	}
+
+	// Diagnostics are published asynchronously once the file is parsed
+	c.diagnostics.wait(ctx, path, c.Timeouts.Diagnostics)
 
 	return symbols, nil
 }
+
+// Hover returns the hover information at pos in an open document, or nil if
+// the server has none
+func (c *Client) Hover(ctx context.Context, filePath string, pos Position) (*Hover, error) {
+	if !c.init.Capabilities.HoverProvider {
+		return nil, fmt.Errorf("hover: %w", ErrNotSupported)
+	}
+
+	ctx, cancel := withTimeout(ctx, c.Timeouts.Request)
+	defer cancel()
+
+	params := map[string]any{
+		"textDocument": map[string]any{
+			"uri": fileuri.FromPath(filePath),
+		},
+		"position": pos,
+	}
+
+	var hover *Hover
+	if err := c.call(ctx, "textDocument/hover", params, &hover); err != nil {
+		return nil, fmt.Errorf("hover: %w", err)
+	}
+
+	return hover, nil
+}
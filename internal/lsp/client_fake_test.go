@@ -0,0 +1,715 @@
+package lsp_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+
+	"clangd-parser/internal/fileuri"
+	"clangd-parser/internal/lsp"
+	"clangd-parser/internal/lsp/lsptest"
+)
+
+func startFake(t *testing.T, script lsptest.Script) (*lsp.Client, *lsptest.Server) {
+	t.Helper()
+	return startFakeWithSpec(t, lsp.ClangdSpec(""), script)
+}
+
+func startFakeWithSpec(t *testing.T, spec lsp.ServerSpec, script lsptest.Script) (*lsp.Client, *lsptest.Server) {
+	t.Helper()
+
+	server, rwc := lsptest.Start(script)
+	t.Cleanup(func() { server.Close() })
+
+	client, err := lsp.NewClientFromStream(context.Background(), rwc, spec, t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	t.Cleanup(func() { client.Close(context.Background()) })
+
+	return client, server
+}
+
+func writeSource(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+	return path
+}
+
+func TestFakeServerDocumentSymbols(t *testing.T) {
+	ctx := context.Background()
+	file := writeSource(t, "test.cpp", "int add(int a, int b) { return a + b; }\n")
+
+	client, server := startFake(t, lsptest.Script{
+		Symbols: map[string][]lsp.DocumentSymbol{
+			file: {
+				{
+					Name:   "add",
+					Detail: "int (int, int)",
+					Kind:   lsp.SymbolKindFunction,
+					Range: lsp.Range{
+						Start: lsp.Position{Line: 0, Character: 0},
+						End:   lsp.Position{Line: 0, Character: 39},
+					},
+				},
+			},
+		},
+	})
+
+	symbols, err := client.GetDocumentSymbols(ctx, file)
+	if err != nil {
+		t.Fatalf("GetDocumentSymbols failed: %v", err)
+	}
+
+	if len(symbols) != 1 || symbols[0].Name != "add" {
+		t.Fatalf("Expected the scripted 'add' symbol, got %+v", symbols)
+	}
+
+	received := strings.Join(server.Received(), ",")
+	for _, method := range []string{"initialize", "initialized", "textDocument/didOpen", "textDocument/documentSymbol"} {
+		if !strings.Contains(received, method) {
+			t.Errorf("Expected server to receive %s, got %s", method, received)
+		}
+	}
+}
+
+func TestFakeServerError(t *testing.T) {
+	ctx := context.Background()
+	file := writeSource(t, "broken.cpp", "int x;\n")
+
+	client, _ := startFake(t, lsptest.Script{
+		Errors: map[string]string{
+			"textDocument/documentSymbol " + file: "invalid AST",
+		},
+	})
+
+	_, err := client.GetDocumentSymbols(ctx, file)
+	if err == nil {
+		t.Fatal("Expected documentSymbol error")
+	}
+	if !strings.Contains(err.Error(), "invalid AST") {
+		t.Errorf("Expected scripted error message, got %v", err)
+	}
+}
+
+func TestFakeServerTimeout(t *testing.T) {
+	ctx := context.Background()
+	file := writeSource(t, "slow.cpp", "int x;\n")
+
+	client, _ := startFake(t, lsptest.Script{
+		Delays: map[string]time.Duration{
+			"textDocument/documentSymbol": time.Minute,
+		},
+	})
+	client.Timeouts.Request = 50 * time.Millisecond
+
+	start := time.Now()
+	_, err := client.GetDocumentSymbols(ctx, file)
+	if err == nil {
+		t.Fatal("Expected timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Timeout took too long: %v", elapsed)
+	}
+}
+
+func TestFakeServerCancel(t *testing.T) {
+	file := writeSource(t, "slow.cpp", "int x;\n")
+
+	client, server := startFake(t, lsptest.Script{
+		Delays: map[string]time.Duration{
+			"textDocument/documentSymbol": time.Minute,
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	_, err := client.GetDocumentSymbols(ctx, file)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	// The cancel notification is sent after the call returns
+	var canceled []jsonrpc2.ID
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if canceled = server.Canceled(); len(canceled) > 0 {
+			break
+		}
+	}
+
+	var symbolID jsonrpc2.ID
+	for _, req := range server.Requests() {
+		if req.Method == "textDocument/documentSymbol" {
+			symbolID = req.ID
+		}
+	}
+	if len(canceled) != 1 || canceled[0] != symbolID {
+		t.Fatalf("Expected $/cancelRequest for request %v, got %v", symbolID, canceled)
+	}
+
+	// didClose still goes out on the canceled context
+	methods := server.Received()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if methods = server.Received(); methods[len(methods)-1] == "textDocument/didClose" {
+			break
+		}
+	}
+	if methods[len(methods)-1] != "textDocument/didClose" {
+		t.Errorf("Expected didClose after cancellation, got %v", methods)
+	}
+	t.Logf("✓ Canceled request %v", symbolID)
+}
+
+func TestFakeServerInitializeFailure(t *testing.T) {
+	server, rwc := lsptest.Start(lsptest.Script{
+		Errors: map[string]string{"initialize": "unsupported client"},
+	})
+	defer server.Close()
+
+	if _, err := lsp.NewClientFromStream(context.Background(), rwc, lsp.ClangdSpec(""), t.TempDir()); err == nil {
+		t.Fatal("Expected initialize error")
+	}
+}
+
+func TestFakeServerInitializeResult(t *testing.T) {
+	ctx := context.Background()
+	file := writeSource(t, "a.cpp", "int x;\n")
+
+	client, server := startFake(t, lsptest.Script{
+		InitializeResult: map[string]any{
+			"capabilities": map[string]any{
+				"documentSymbolProvider": true,
+				"hoverProvider":          map[string]any{},
+				"callHierarchyProvider":  map[string]any{"workDoneProgress": false},
+				"referencesProvider":     false,
+				"semanticTokensProvider": map[string]any{"full": true},
+			},
+			"serverInfo": map[string]any{
+				"name":    "clangd",
+				"version": "clangd version 17.0.6",
+			},
+			"offsetEncoding": "utf-8",
+		},
+	})
+
+	info := client.ServerInfo()
+	if info.Name != "clangd" || info.String() != "clangd version 17.0.6" {
+		t.Errorf("Unexpected server info: %+v (%s)", info, info)
+	}
+	if enc := client.InitializeResult().OffsetEncoding; enc != "utf-8" {
+		t.Errorf("Expected offsetEncoding utf-8, got %q", enc)
+	}
+	if enc := client.PositionEncoding(); enc != lsp.PositionEncodingUTF8 {
+		t.Errorf("Expected clangd's offsetEncoding to be used, got %q", enc)
+	}
+
+	caps := client.Capabilities()
+	if !caps.DocumentSymbolProvider || !caps.HoverProvider || !caps.CallHierarchyProvider {
+		t.Errorf("Expected boolean and object providers to count as supported: %+v", caps)
+	}
+	if caps.ReferencesProvider || caps.TypeHierarchyProvider {
+		t.Errorf("Expected false and missing providers to be unsupported: %+v", caps)
+	}
+	if !strings.Contains(string(caps.Raw), "semanticTokensProvider") {
+		t.Errorf("Expected the raw capabilities to be kept, got %s", caps.Raw)
+	}
+
+	// Unsupported requests fail without a round trip
+	_, err := client.References(ctx, file, lsp.Position{}, false)
+	if !errors.Is(err, lsp.ErrNotSupported) {
+		t.Errorf("Expected ErrNotSupported, got %v", err)
+	}
+	for _, method := range server.Received() {
+		if method == "textDocument/references" {
+			t.Error("Expected no references request to be sent")
+		}
+	}
+	t.Logf("✓ %s", info)
+}
+
+func TestFakeServerPositionEncoding(t *testing.T) {
+	client, server := startFake(t, lsptest.Script{})
+	if enc := client.PositionEncoding(); enc != lsp.PositionEncodingUTF16 {
+		t.Errorf("Expected UTF-16 when the server doesn't choose, got %q", enc)
+	}
+
+	var params struct {
+		Capabilities struct {
+			General struct {
+				PositionEncodings []lsp.PositionEncoding `json:"positionEncodings"`
+			} `json:"general"`
+			OffsetEncoding []lsp.PositionEncoding `json:"offsetEncoding"`
+		} `json:"capabilities"`
+	}
+	if err := json.Unmarshal(*server.Requests()[0].Params, &params); err != nil {
+		t.Fatalf("Failed to decode initialize params: %v", err)
+	}
+	offered := params.Capabilities.General.PositionEncodings
+	if len(offered) == 0 || offered[0] != lsp.PositionEncodingUTF8 {
+		t.Errorf("Expected UTF-8 to be offered first, got %v", offered)
+	}
+	if len(params.Capabilities.OffsetEncoding) != len(offered) {
+		t.Errorf("Expected clangd's offsetEncoding to offer %v, got %v", offered, params.Capabilities.OffsetEncoding)
+	}
+
+	client, _ = startFake(t, lsptest.Script{
+		InitializeResult: map[string]any{
+			"capabilities":   map[string]any{"positionEncoding": "utf-32"},
+			"offsetEncoding": "utf-8",
+		},
+	})
+	if enc := client.PositionEncoding(); enc != lsp.PositionEncodingUTF32 {
+		t.Errorf("Expected the standard positionEncoding to win, got %q", enc)
+	}
+	t.Logf("✓ Offered %v", offered)
+}
+
+func TestFakeServerFlatSymbols(t *testing.T) {
+	ctx := context.Background()
+	file := writeSource(t, "flat.cpp", "class A {\n  void f();\n};\n")
+
+	client, server := startFakeWithSpec(t, lsp.CclsSpec("/tmp/db"), lsptest.Script{
+		FlatSymbols: map[string][]lsp.SymbolInformation{
+			file: {
+				{
+					Name: "f",
+					Kind: lsp.SymbolKindMethod,
+					Location: lsp.Location{Range: lsp.Range{
+						Start: lsp.Position{Line: 1, Character: 2},
+						End:   lsp.Position{Line: 1, Character: 11},
+					}},
+					ContainerName: "A",
+				},
+				{
+					Name: "A",
+					Kind: lsp.SymbolKindClass,
+					Location: lsp.Location{Range: lsp.Range{
+						Start: lsp.Position{Line: 0, Character: 0},
+						End:   lsp.Position{Line: 2, Character: 1},
+					}},
+				},
+			},
+		},
+	})
+
+	symbols, err := client.GetDocumentSymbols(ctx, file)
+	if err != nil {
+		t.Fatalf("GetDocumentSymbols failed: %v", err)
+	}
+
+	if len(symbols) != 1 || symbols[0].Name != "A" {
+		t.Fatalf("Expected a single root 'A', got %+v", symbols)
+	}
+	if len(symbols[0].Children) != 1 || symbols[0].Children[0].Name != "f" {
+		t.Fatalf("Expected 'f' nested under 'A', got %+v", symbols[0].Children)
+	}
+
+	// Names are located in the source, as servers do for the selection range
+	nameAt := func(line, character int) lsp.Range {
+		return lsp.Range{Start: lsp.Position{Line: line, Character: character}, End: lsp.Position{Line: line, Character: character + 1}}
+	}
+	if got := symbols[0].SelectionRange; got != nameAt(0, 6) {
+		t.Errorf("Expected A selected at 0:6, got %+v", got)
+	}
+	if got := symbols[0].Children[0].SelectionRange; got != nameAt(1, 7) {
+		t.Errorf("Expected f selected at 1:7, got %+v", got)
+	}
+
+	var init struct {
+		Capabilities struct {
+			TextDocument struct {
+				DocumentSymbol struct {
+					Hierarchical bool `json:"hierarchicalDocumentSymbolSupport"`
+				} `json:"documentSymbol"`
+			} `json:"textDocument"`
+		} `json:"capabilities"`
+		InitializationOptions map[string]string `json:"initializationOptions"`
+	}
+	if err := json.Unmarshal(*server.Requests()[0].Params, &init); err != nil {
+		t.Fatalf("Failed to decode initialize params: %v", err)
+	}
+	if init.Capabilities.TextDocument.DocumentSymbol.Hierarchical {
+		t.Error("ccls spec should not advertise hierarchical symbol support")
+	}
+	if init.InitializationOptions["compilationDatabaseDirectory"] != "/tmp/db" {
+		t.Errorf("Expected initializationOptions to be sent, got %v", init.InitializationOptions)
+	}
+}
+
+func TestWaitForIndex(t *testing.T) {
+	client, _ := startFake(t, lsptest.Script{
+		Notifications: lsptest.IndexProgress(25, 75),
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var events []lsp.ProgressEvent
+	if err := client.WaitForIndex(ctx, func(ev lsp.ProgressEvent) {
+		events = append(events, ev)
+	}); err != nil {
+		t.Fatalf("WaitForIndex failed: %v", err)
+	}
+
+	if !client.IndexReady() {
+		t.Error("Expected index to be ready")
+	}
+	if len(events) == 0 {
+		t.Fatal("Expected at least one progress callback")
+	}
+	last := events[len(events)-1]
+	if last.Kind != "end" || last.Percentage != 100 || last.Title != "indexing" {
+		t.Errorf("Unexpected final progress event: %+v", last)
+	}
+}
+
+func TestWaitForIndexTimeout(t *testing.T) {
+	// Index begins but never ends
+	client, _ := startFake(t, lsptest.Script{
+		Notifications: lsptest.IndexProgress()[:2],
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := client.WaitForIndex(ctx, nil); err != context.DeadlineExceeded {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+	if client.IndexReady() {
+		t.Error("Index should not be ready")
+	}
+}
+
+func TestIndexStarted(t *testing.T) {
+	ctx := context.Background()
+
+	client, _ := startFake(t, lsptest.Script{Notifications: lsptest.IndexProgress(50)})
+	if !client.IndexStarted(ctx, 5*time.Second) {
+		t.Error("Expected index progress to be seen")
+	}
+
+	// A server that indexed before the client connected stays silent
+	client, _ = startFake(t, lsptest.Script{})
+	if client.IndexStarted(ctx, 50*time.Millisecond) {
+		t.Error("Expected no index progress")
+	}
+}
+
+func TestWaitForIndexWithoutProgressSupport(t *testing.T) {
+	client, _ := startFakeWithSpec(t, lsp.CclsSpec(""), lsptest.Script{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := client.WaitForIndex(ctx, nil); err != nil {
+		t.Errorf("Expected servers without index progress to be ready, got %v", err)
+	}
+}
+
+func TestFakeServerDiagnostics(t *testing.T) {
+	ctx := context.Background()
+	file := writeSource(t, "broken.cpp", "Foo x;\n")
+
+	client, _ := startFake(t, lsptest.Script{
+		Diagnostics: map[string][]lsp.Diagnostic{
+			file: {
+				{
+					Range:    lsp.Range{Start: lsp.Position{Line: 0}, End: lsp.Position{Line: 0, Character: 3}},
+					Severity: lsp.DiagnosticSeverityError,
+					Message:  "unknown type name 'Foo'",
+				},
+			},
+		},
+	})
+
+	if _, err := client.GetDocumentSymbols(ctx, file); err != nil {
+		t.Fatalf("GetDocumentSymbols failed: %v", err)
+	}
+
+	// Give the clearing publish after didClose a chance to arrive
+	time.Sleep(20 * time.Millisecond)
+
+	diags, ok := client.Diagnostics(file)
+	if !ok || len(diags) != 1 || diags[0].Message != "unknown type name 'Foo'" {
+		t.Fatalf("Expected the scripted diagnostic to survive didClose, got %+v", diags)
+	}
+}
+
+func TestFakeServerNoDiagnostics(t *testing.T) {
+	ctx := context.Background()
+	file := writeSource(t, "unanalysed.cpp", "void f() {}\n")
+	clean := writeSource(t, "clean.cpp", "void g() {}\n")
+
+	client, _ := startFake(t, lsptest.Script{
+		Undiagnosed: map[string]bool{file: true},
+	})
+	client.Timeouts.Diagnostics = 50 * time.Millisecond
+
+	if _, err := client.GetDocumentSymbols(ctx, file); err != nil {
+		t.Fatalf("GetDocumentSymbols failed: %v", err)
+	}
+	if diags, ok := client.Diagnostics(file); ok {
+		t.Errorf("Expected no diagnostics to be known, got %+v", diags)
+	}
+
+	if _, err := client.GetDocumentSymbols(ctx, clean); err != nil {
+		t.Fatalf("GetDocumentSymbols failed: %v", err)
+	}
+	if diags, ok := client.Diagnostics(clean); !ok || len(diags) != 0 {
+		t.Errorf("Expected an empty publish to be known, got %+v (%v)", diags, ok)
+	}
+	t.Logf("✓ Told an unanalysed file from a clean one")
+}
+
+func TestFakeServerEscapedPaths(t *testing.T) {
+	ctx := context.Background()
+	file := writeSource(t, "my file #1 größe.cpp", "void f() {}\n")
+
+	client, server := startFake(t, lsptest.Script{
+		Symbols: map[string][]lsp.DocumentSymbol{
+			file: {{Name: "f", Kind: lsp.SymbolKindFunction}},
+		},
+		Diagnostics: map[string][]lsp.Diagnostic{
+			file: {{Severity: lsp.DiagnosticSeverityWarning, Message: "unused"}},
+		},
+	})
+
+	symbols, err := client.GetDocumentSymbols(ctx, file)
+	if err != nil {
+		t.Fatalf("GetDocumentSymbols failed: %v", err)
+	}
+	if len(symbols) != 1 {
+		t.Fatalf("Expected the server to find the file by its decoded path, got %+v", symbols)
+	}
+	if diags, _ := client.Diagnostics(file); len(diags) != 1 {
+		t.Errorf("Expected diagnostics to match the escaped URI, got %+v", diags)
+	}
+
+	for _, req := range server.Requests() {
+		if req.Method != "textDocument/didOpen" {
+			continue
+		}
+		params := string(*req.Params)
+		if !strings.Contains(params, "my%20file%20%231%20gr%C3%B6%C3%9Fe.cpp") {
+			t.Errorf("Expected a percent-encoded URI, got %s", params)
+		}
+	}
+}
+
+func TestFakeServerHover(t *testing.T) {
+	ctx := context.Background()
+	file := writeSource(t, "hover.cpp", "int add(int a, int b);\n")
+
+	client, _ := startFake(t, lsptest.Script{
+		Hovers: map[string]map[string]any{
+			file: {
+				"0:4": map[string]any{
+					"contents": map[string]any{"kind": "markdown", "value": "### function `add`"},
+				},
+			},
+		},
+	})
+
+	if err := client.OpenDocument(ctx, file); err != nil {
+		t.Fatalf("OpenDocument failed: %v", err)
+	}
+	defer client.CloseDocument(ctx, file)
+
+	hover, err := client.Hover(ctx, file, lsp.Position{Line: 0, Character: 4})
+	if err != nil {
+		t.Fatalf("Hover failed: %v", err)
+	}
+	if hover == nil || hover.Contents.Value != "### function `add`" {
+		t.Fatalf("Unexpected hover: %+v", hover)
+	}
+
+	hover, err = client.Hover(ctx, file, lsp.Position{Line: 0, Character: 0})
+	if err != nil || hover != nil {
+		t.Errorf("Expected no hover at an empty position, got %+v, %v", hover, err)
+	}
+}
+
+func TestFakeServerCallHierarchy(t *testing.T) {
+	ctx := context.Background()
+	file := writeSource(t, "calls.cpp", "void helper() {}\nvoid run() { helper(); }\n")
+
+	run := lsp.CallHierarchyItem{Name: "run", Kind: lsp.SymbolKindFunction, URI: fileuri.FromPath(file)}
+	helper := lsp.CallHierarchyItem{Name: "helper", Kind: lsp.SymbolKindFunction, URI: fileuri.FromPath(file)}
+
+	client, _ := startFake(t, lsptest.Script{
+		CallHierarchy: map[string]map[string][]lsp.CallHierarchyItem{
+			file: {"1:5": {run}},
+		},
+		OutgoingCalls: map[string][]lsp.CallHierarchyOutgoingCall{
+			"run": {{To: helper}},
+		},
+	})
+
+	if err := client.OpenDocument(ctx, file); err != nil {
+		t.Fatalf("OpenDocument failed: %v", err)
+	}
+	defer client.CloseDocument(ctx, file)
+
+	items, err := client.PrepareCallHierarchy(ctx, file, lsp.Position{Line: 1, Character: 5})
+	if err != nil || len(items) != 1 {
+		t.Fatalf("PrepareCallHierarchy = %+v, %v", items, err)
+	}
+
+	outgoing, err := client.OutgoingCalls(ctx, items[0])
+	if err != nil || len(outgoing) != 1 || outgoing[0].To.Name != "helper" {
+		t.Fatalf("OutgoingCalls = %+v, %v", outgoing, err)
+	}
+
+	incoming, err := client.IncomingCalls(ctx, items[0])
+	if err != nil || len(incoming) != 0 {
+		t.Fatalf("IncomingCalls = %+v, %v", incoming, err)
+	}
+}
+
+func TestFakeServerTypeHierarchy(t *testing.T) {
+	ctx := context.Background()
+	file := writeSource(t, "types.h", "struct Base {};\nstruct Derived : Base {};\n")
+
+	derived := lsp.TypeHierarchyItem{Name: "Derived", Kind: lsp.SymbolKindStruct, URI: fileuri.FromPath(file)}
+	base := lsp.TypeHierarchyItem{Name: "Base", Kind: lsp.SymbolKindStruct, URI: fileuri.FromPath(file)}
+
+	t.Run("standard", func(t *testing.T) {
+		client, _ := startFake(t, lsptest.Script{
+			TypeHierarchy: map[string]map[string][]lsp.TypeHierarchyItem{
+				file: {"1:7": {derived}},
+			},
+			Supertypes: map[string][]lsp.TypeHierarchyItem{"Derived": {base}},
+		})
+
+		items, err := client.PrepareTypeHierarchy(ctx, file, lsp.Position{Line: 1, Character: 7})
+		if err != nil || len(items) != 1 {
+			t.Fatalf("PrepareTypeHierarchy = %+v, %v", items, err)
+		}
+		supers, err := client.Supertypes(ctx, items[0])
+		if err != nil || len(supers) != 1 || supers[0].Name != "Base" {
+			t.Fatalf("Supertypes = %+v, %v", supers, err)
+		}
+	})
+
+	t.Run("legacy", func(t *testing.T) {
+		legacy := derived
+		legacy.Parents = []lsp.TypeHierarchyItem{base}
+
+		client, _ := startFake(t, lsptest.Script{
+			LegacyTypeHierarchy: map[string]map[string]lsp.TypeHierarchyItem{
+				file: {"1:7": legacy},
+			},
+		})
+
+		if _, err := client.PrepareTypeHierarchy(ctx, file, lsp.Position{Line: 1, Character: 7}); err == nil {
+			t.Error("Expected standard request to be unsupported")
+		}
+
+		item, err := client.LegacyTypeHierarchy(ctx, file, lsp.Position{Line: 1, Character: 7}, 1, lsp.TypeHierarchyBoth)
+		if err != nil || item == nil || len(item.Parents) != 1 {
+			t.Fatalf("LegacyTypeHierarchy = %+v, %v", item, err)
+		}
+	})
+}
+
+func TestFakeServerReferences(t *testing.T) {
+	ctx := context.Background()
+	file := writeSource(t, "refs.cpp", "int x;\nint y = x;\n")
+
+	client, server := startFake(t, lsptest.Script{
+		References: map[string]map[string][]lsp.Location{
+			file: {"0:4": {{URI: fileuri.FromPath(file), Range: lsp.Range{Start: lsp.Position{Line: 1, Character: 8}}}}},
+		},
+	})
+
+	if err := client.OpenDocument(ctx, file); err != nil {
+		t.Fatalf("OpenDocument failed: %v", err)
+	}
+	defer client.CloseDocument(ctx, file)
+
+	locations, err := client.References(ctx, file, lsp.Position{Line: 0, Character: 4}, false)
+	if err != nil || len(locations) != 1 || locations[0].Range.Start.Line != 1 {
+		t.Fatalf("References = %+v, %v", locations, err)
+	}
+
+	var params struct {
+		Context struct {
+			IncludeDeclaration bool `json:"includeDeclaration"`
+		} `json:"context"`
+	}
+	requests := server.Requests()
+	if err := json.Unmarshal(*requests[len(requests)-1].Params, &params); err != nil {
+		t.Fatalf("Failed to decode references params: %v", err)
+	}
+	if params.Context.IncludeDeclaration {
+		t.Error("Expected includeDeclaration to be false")
+	}
+}
+
+func TestFakeServerDefinitionAndDeclaration(t *testing.T) {
+	ctx := context.Background()
+	header := writeSource(t, "foo.h", "int foo();\n")
+	source := writeSource(t, "foo.cpp", "#include \"foo.h\"\nint foo() { return 1; }\n")
+
+	client, _ := startFake(t, lsptest.Script{
+		Definitions: map[string]map[string][]lsp.Location{
+			header: {"0:4": {{URI: fileuri.FromPath(source), Range: lsp.Range{Start: lsp.Position{Line: 1, Character: 4}}}}},
+		},
+		Declarations: map[string]map[string][]lsp.Location{
+			source: {"1:4": {{URI: fileuri.FromPath(header), Range: lsp.Range{Start: lsp.Position{Line: 0, Character: 4}}}}},
+		},
+	})
+
+	definitions, err := client.Definition(ctx, header, lsp.Position{Line: 0, Character: 4})
+	if err != nil || len(definitions) != 1 || fileuri.ToPath(definitions[0].URI) != source {
+		t.Fatalf("Definition = %+v, %v", definitions, err)
+	}
+
+	declarations, err := client.Declaration(ctx, source, lsp.Position{Line: 1, Character: 4})
+	if err != nil || len(declarations) != 1 || fileuri.ToPath(declarations[0].URI) != header {
+		t.Fatalf("Declaration = %+v, %v", declarations, err)
+	}
+
+	bare, _ := startFake(t, lsptest.Script{
+		InitializeResult: map[string]any{"capabilities": map[string]any{}},
+	})
+	if _, err := bare.Definition(ctx, header, lsp.Position{}); !errors.Is(err, lsp.ErrNotSupported) {
+		t.Errorf("Expected ErrNotSupported without definitionProvider, got %v", err)
+	}
+	t.Logf("✓ %s ↔ %s", filepath.Base(header), filepath.Base(source))
+}
+
+func TestFakeServerFoldingRanges(t *testing.T) {
+	ctx := context.Background()
+	source := writeSource(t, "fold.cpp", "void f() {\n  if (x) {\n    g();\n  }\n}\n")
+
+	client, _ := startFake(t, lsptest.Script{
+		FoldingRanges: map[string][]lsp.FoldingRange{
+			source: {{StartLine: 0, EndLine: 4}, {StartLine: 1, EndLine: 3, Kind: "region"}},
+		},
+	})
+
+	ranges, err := client.FoldingRanges(ctx, source)
+	if err != nil || len(ranges) != 2 || ranges[1].StartLine != 1 || ranges[1].Kind != "region" {
+		t.Fatalf("FoldingRanges = %+v, %v", ranges, err)
+	}
+
+	bare, _ := startFake(t, lsptest.Script{
+		InitializeResult: map[string]any{"capabilities": map[string]any{}},
+	})
+	if _, err := bare.FoldingRanges(ctx, source); !errors.Is(err, lsp.ErrNotSupported) {
+		t.Errorf("Expected ErrNotSupported without foldingRangeProvider, got %v", err)
+	}
+	t.Logf("✓ %d folding ranges", len(ranges))
+}
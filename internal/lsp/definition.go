@@ -0,0 +1,87 @@
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"clangd-parser/internal/fileuri"
+)
+
+// Definition returns where the symbol at pos in an open document is
+// defined. For a function declared in a header, that is its body in the
+// source file.
+func (c *Client) Definition(ctx context.Context, filePath string, pos Position) ([]Location, error) {
+	if !c.init.Capabilities.DefinitionProvider {
+		return nil, fmt.Errorf("definition: %w", ErrNotSupported)
+	}
+	return c.locations(ctx, "textDocument/definition", filePath, pos)
+}
+
+// Declaration returns where the symbol at pos in an open document is
+// declared, such as the header declaration of a function defined out of line
+func (c *Client) Declaration(ctx context.Context, filePath string, pos Position) ([]Location, error) {
+	if !c.init.Capabilities.DeclarationProvider {
+		return nil, fmt.Errorf("declaration: %w", ErrNotSupported)
+	}
+	return c.locations(ctx, "textDocument/declaration", filePath, pos)
+}
+
+// locations sends a position request answered with locations or location
+// links
+func (c *Client) locations(ctx context.Context, method, filePath string, pos Position) ([]Location, error) {
+	ctx, cancel := withTimeout(ctx, c.Timeouts.Request)
+	defer cancel()
+
+	params := map[string]any{
+		"textDocument": map[string]any{
+			"uri": fileuri.FromPath(filePath),
+		},
+		"position": pos,
+	}
+
+	var result locationResult
+	if err := c.call(ctx, method, params, &result); err != nil {
+		return nil, fmt.Errorf("%s: %w", method, err)
+	}
+
+	return result, nil
+}
+
+// locationResult decodes the answers servers give to definition-like
+// requests: a single Location, a list of them, or a list of LocationLinks,
+// which are reduced to the target's name range
+type locationResult []Location
+
+func (r *locationResult) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*r = nil
+		return nil
+	}
+	if len(data) > 0 && data[0] == '{' {
+		data = append(append([]byte("["), data...), ']')
+	}
+
+	var items []struct {
+		Location
+		TargetURI            string `json:"targetUri"`
+		TargetSelectionRange *Range `json:"targetSelectionRange"`
+	}
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+
+	*r = make(locationResult, len(items))
+	for i, item := range items {
+		if item.TargetURI != "" {
+			item.URI = item.TargetURI
+			if item.TargetSelectionRange != nil {
+				item.Range = *item.TargetSelectionRange
+			}
+		}
+		(*r)[i] = item.Location
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package lsp
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"clangd-parser/internal/fileuri"
+)
+
+// publishDiagnosticsParams is the payload of textDocument/publishDiagnostics
+type publishDiagnosticsParams struct {
+	URI         string       `json:"uri"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// diagnosticStore collects published diagnostics per normalized file path,
+// so URIs the server encodes differently from the client still match.
+// Diagnostics are only accepted while the document is open, because servers
+// clear them with an empty publish after didClose. A path has an entry in
+// byPath once a publish arrived for it, even an empty one.
+type diagnosticStore struct {
+	mu       sync.Mutex
+	open     map[string]bool
+	byPath   map[string][]Diagnostic
+	received map[string]chan struct{}
+}
+
+func newDiagnosticStore() *diagnosticStore {
+	return &diagnosticStore{
+		open:     make(map[string]bool),
+		byPath:   make(map[string][]Diagnostic),
+		received: make(map[string]chan struct{}),
+	}
+}
+
+// opened resets the diagnostics of path before it is (re)opened
+func (d *diagnosticStore) opened(path string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.open[path] = true
+	delete(d.byPath, path)
+	d.received[path] = make(chan struct{})
+}
+
+func (d *diagnosticStore) closed(path string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.open, path)
+	delete(d.received, path)
+}
+
+func (d *diagnosticStore) publish(params publishDiagnosticsParams) {
+	path := fileuri.ToPath(params.URI)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.open[path] {
+		return
+	}
+	d.byPath[path] = params.Diagnostics
+
+	if ch, ok := d.received[path]; ok {
+		close(ch)
+		delete(d.received, path)
+	}
+}
+
+// wait blocks until diagnostics for path have been published, timeout
+// passes or ctx is done
+func (d *diagnosticStore) wait(ctx context.Context, path string, timeout time.Duration) {
+	d.mu.Lock()
+	ch, ok := d.received[path]
+	d.mu.Unlock()
+
+	if !ok || timeout <= 0 {
+		return
+	}
+
+	select {
+	case <-ch:
+	case <-time.After(timeout):
+	case <-ctx.Done():
+	}
+}
+
+func (d *diagnosticStore) get(path string) ([]Diagnostic, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	diags, ok := d.byPath[path]
+	return append([]Diagnostic(nil), diags...), ok
+}
+
+// Diagnostics returns the diagnostics the server published for filePath the
+// last time it was opened. ok is false if the server published none before
+// the diagnostics timeout, so nothing is known about the file.
+func (c *Client) Diagnostics(filePath string) (diags []Diagnostic, ok bool) {
+	return c.diagnostics.get(fileuri.Normalize(filePath))
+}
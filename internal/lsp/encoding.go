@@ -0,0 +1,160 @@
+package lsp
+
+import (
+	"bytes"
+	"sort"
+	"unicode/utf8"
+)
+
+// PositionEncoding is the unit Position.Character counts in
+type PositionEncoding string
+
+const (
+	PositionEncodingUTF8  PositionEncoding = "utf-8"  // bytes
+	PositionEncodingUTF16 PositionEncoding = "utf-16" // UTF-16 code units, the LSP default
+	PositionEncodingUTF32 PositionEncoding = "utf-32" // Unicode code points
+)
+
+// supportedEncodings are offered to the server in order of preference. UTF-8
+// maps to byte offsets without any conversion.
+var supportedEncodings = []PositionEncoding{PositionEncodingUTF8, PositionEncodingUTF16, PositionEncodingUTF32}
+
+// units returns how many units of the encoding r takes up. Invalid UTF-8
+// bytes count as one unit, like the replacement character they decode to.
+func (e PositionEncoding) units(r rune, size int) int {
+	switch e {
+	case PositionEncodingUTF8:
+		return size
+	case PositionEncodingUTF32:
+		return 1
+	}
+	if r >= 0x10000 {
+		return 2 // surrogate pair
+	}
+	return 1
+}
+
+// ByteColumn converts a character offset within line to a byte offset.
+// Offsets past the end of the line clamp to its length, and offsets inside a
+// character resolve to its start.
+func (e PositionEncoding) ByteColumn(line string, character int) int {
+	units := 0
+	for i := 0; i < len(line); {
+		r, size := utf8.DecodeRuneInString(line[i:])
+		units += e.units(r, size)
+		if units > character {
+			return i
+		}
+		i += size
+	}
+	return len(line)
+}
+
+// Character converts a byte offset within line to a character offset
+func (e PositionEncoding) Character(line string, byteColumn int) int {
+	units := 0
+	for i := 0; i < len(line) && i < byteColumn; {
+		r, size := utf8.DecodeRuneInString(line[i:])
+		units += e.units(r, size)
+		i += size
+	}
+	return units
+}
+
+// Text is a document's content indexed by line, for converting positions
+// the server reports into byte offsets and rune columns
+type Text struct {
+	content  []byte
+	starts   []int // byte offset where each line starts
+	encoding PositionEncoding
+}
+
+// NewText indexes content whose positions are given in encoding. An empty
+// encoding means UTF-16.
+func NewText(content []byte, encoding PositionEncoding) *Text {
+	if encoding == "" {
+		encoding = PositionEncodingUTF16
+	}
+	t := &Text{content: content, starts: []int{0}, encoding: encoding}
+	for i, b := range content {
+		if b == '\n' {
+			t.starts = append(t.starts, i+1)
+		}
+	}
+	return t
+}
+
+// Encoding returns the encoding positions are interpreted in
+func (t *Text) Encoding() PositionEncoding {
+	return t.encoding
+}
+
+// LineCount returns the number of lines. A trailing newline doesn't start
+// another line.
+func (t *Text) LineCount() int {
+	n := len(t.starts)
+	if t.starts[n-1] == len(t.content) {
+		n--
+	}
+	return n
+}
+
+// Lines returns all lines without their terminators
+func (t *Text) Lines() []string {
+	lines := make([]string, t.LineCount())
+	for i := range lines {
+		lines[i] = t.Line(i)
+	}
+	return lines
+}
+
+// Line returns a line without its terminator, or "" past the end
+func (t *Text) Line(n int) string {
+	if n < 0 || n >= len(t.starts) {
+		return ""
+	}
+	end := len(t.content)
+	if n+1 < len(t.starts) {
+		end = t.starts[n+1]
+	}
+	line := bytes.TrimSuffix(t.content[t.starts[n]:end], []byte("\n"))
+	return string(bytes.TrimSuffix(line, []byte("\r")))
+}
+
+// Slice returns the content between two byte offsets, clamped to the
+// content
+func (t *Text) Slice(start, end int) string {
+	start = max(0, min(start, len(t.content)))
+	end = max(start, min(end, len(t.content)))
+	return string(t.content[start:end])
+}
+
+// Column returns the 0-based byte column of pos within its line
+func (t *Text) Column(pos Position) int {
+	return t.encoding.ByteColumn(t.Line(pos.Line), pos.Character)
+}
+
+// RuneColumn returns the 0-based column of pos in Unicode code points
+func (t *Text) RuneColumn(pos Position) int {
+	return utf8.RuneCountInString(t.Line(pos.Line)[:t.Column(pos)])
+}
+
+// Offset returns the byte offset of pos in the content. Positions past the
+// last line clamp to the end of the content.
+func (t *Text) Offset(pos Position) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(t.starts) {
+		return len(t.content)
+	}
+	return t.starts[pos.Line] + t.Column(pos)
+}
+
+// Position converts a byte offset in the content back to a position
+func (t *Text) Position(offset int) Position {
+	offset = max(0, min(offset, len(t.content)))
+	line := sort.SearchInts(t.starts, offset+1) - 1
+	text := t.Line(line)
+	return Position{Line: line, Character: t.encoding.Character(text, offset-t.starts[line])}
+}
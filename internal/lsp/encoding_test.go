@@ -0,0 +1,73 @@
+package lsp
+
+import "testing"
+
+func TestPositionEncodingByteColumn(t *testing.T) {
+	// "é" is 2 bytes and 1 UTF-16 unit, "😀" 4 bytes and 2 UTF-16 units
+	line := `s = "é😀x";`
+
+	tests := []struct {
+		name      string
+		encoding  PositionEncoding
+		character int
+		expected  int
+	}{
+		{"utf-8 before literal", PositionEncodingUTF8, 4, 4},
+		{"utf-8 after emoji", PositionEncodingUTF8, 11, 11},
+		{"utf-16 after accent", PositionEncodingUTF16, 6, 7},
+		{"utf-16 after emoji", PositionEncodingUTF16, 8, 11},
+		{"utf-16 inside surrogate pair", PositionEncodingUTF16, 7, 7},
+		{"utf-32 after emoji", PositionEncodingUTF32, 7, 11},
+		{"past end of line", PositionEncodingUTF16, 100, len(line)},
+	}
+
+	for _, tt := range tests {
+		if got := tt.encoding.ByteColumn(line, tt.character); got != tt.expected {
+			t.Errorf("%s: got byte column %d, expected %d", tt.name, got, tt.expected)
+		}
+	}
+
+	for _, enc := range supportedEncodings {
+		for col := range []byte(line) {
+			if line[col]&0xC0 == 0x80 {
+				continue // not at the start of a character
+			}
+			if got := enc.ByteColumn(line, enc.Character(line, col)); got != col {
+				t.Errorf("%s: byte column %d round-tripped to %d", enc, col, got)
+			}
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	text := NewText([]byte("// naïve\r\nint 😀x = 1;\n"), "")
+
+	if text.Encoding() != PositionEncodingUTF16 {
+		t.Errorf("Expected UTF-16 by default, got %s", text.Encoding())
+	}
+	if n := text.LineCount(); n != 2 {
+		t.Errorf("Expected 2 lines, got %d", n)
+	}
+	if line := text.Line(0); line != "// naïve" {
+		t.Errorf("Expected the line without CRLF, got %q", line)
+	}
+
+	// x follows the emoji: UTF-16 character 6, byte column 8, rune column 5
+	pos := Position{Line: 1, Character: 6}
+	if col := text.Column(pos); col != 8 {
+		t.Errorf("Expected byte column 8, got %d", col)
+	}
+	if col := text.RuneColumn(pos); col != 5 {
+		t.Errorf("Expected rune column 5, got %d", col)
+	}
+	offset := text.Offset(pos)
+	if offset != 19 {
+		t.Errorf("Expected offset 19, got %d", offset)
+	}
+	if back := text.Position(offset); back != pos {
+		t.Errorf("Expected offset %d to map back to %+v, got %+v", offset, pos, back)
+	}
+	if end := text.Offset(Position{Line: 5}); end != 26 {
+		t.Errorf("Expected positions past the end to clamp to 26, got %d", end)
+	}
+}
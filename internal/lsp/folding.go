@@ -0,0 +1,41 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+
+	"clangd-parser/internal/fileuri"
+)
+
+// FoldingRange is a foldable region such as a block, comment or include
+// list. Lines are 0-based; the characters are optional.
+type FoldingRange struct {
+	StartLine      int    `json:"startLine"`
+	StartCharacter *int   `json:"startCharacter,omitempty"`
+	EndLine        int    `json:"endLine"`
+	EndCharacter   *int   `json:"endCharacter,omitempty"`
+	Kind           string `json:"kind,omitempty"`
+}
+
+// FoldingRanges returns the foldable regions of an open document
+func (c *Client) FoldingRanges(ctx context.Context, filePath string) ([]FoldingRange, error) {
+	if !c.init.Capabilities.FoldingRangeProvider {
+		return nil, fmt.Errorf("foldingRange: %w", ErrNotSupported)
+	}
+
+	ctx, cancel := withTimeout(ctx, c.Timeouts.Request)
+	defer cancel()
+
+	params := map[string]any{
+		"textDocument": map[string]any{
+			"uri": fileuri.FromPath(filePath),
+		},
+	}
+
+	var ranges []FoldingRange
+	if err := c.call(ctx, "textDocument/foldingRange", params, &ranges); err != nil {
+		return nil, fmt.Errorf("foldingRange: %w", err)
+	}
+
+	return ranges, nil
+}
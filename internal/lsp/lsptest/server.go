@@ -0,0 +1,519 @@
+// Package lsptest provides a scripted stand-in for clangd so the LSP client
+// and the rest of the pipeline can be tested without a real language server.
+package lsptest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+
+	"clangd-parser/internal/fileuri"
+	"clangd-parser/internal/lsp"
+)
+
+// Notification is a server-to-client message sent by the fake server
+type Notification struct {
+	Method string
+	Params any
+
+	// Call sends the message as a request and waits for the client's reply,
+	// as needed for window/workDoneProgress/create
+	Call bool
+}
+
+// Script describes how the fake server answers requests. Per-file entries are
+// keyed by the absolute file path of the document.
+type Script struct {
+	// InitializeResult is returned from "initialize". When nil, a clangd-like
+	// result advertises every capability the script answers.
+	InitializeResult any
+
+	// Symbols are returned from textDocument/documentSymbol
+	Symbols map[string][]lsp.DocumentSymbol
+
+	// FlatSymbols take precedence over Symbols and are returned the way
+	// servers without hierarchical symbol support answer documentSymbol
+	FlatSymbols map[string][]lsp.SymbolInformation
+
+	// Hovers are returned from textDocument/hover, keyed by file path and
+	// then by "line:character" of the requested position
+	Hovers map[string]map[string]any
+
+	// CallHierarchy is returned from textDocument/prepareCallHierarchy, keyed
+	// like Hovers
+	CallHierarchy map[string]map[string][]lsp.CallHierarchyItem
+
+	// IncomingCalls and OutgoingCalls are keyed by the item's name
+	IncomingCalls map[string][]lsp.CallHierarchyIncomingCall
+	OutgoingCalls map[string][]lsp.CallHierarchyOutgoingCall
+
+	// TypeHierarchy is returned from textDocument/prepareTypeHierarchy, keyed
+	// like Hovers. The standard requests are neither advertised nor answered
+	// when nil.
+	TypeHierarchy map[string]map[string][]lsp.TypeHierarchyItem
+
+	// Supertypes and Subtypes are keyed by the item's name
+	Supertypes map[string][]lsp.TypeHierarchyItem
+	Subtypes   map[string][]lsp.TypeHierarchyItem
+
+	// LegacyTypeHierarchy is returned from clangd's textDocument/typeHierarchy
+	// extension, keyed like Hovers
+	LegacyTypeHierarchy map[string]map[string]lsp.TypeHierarchyItem
+
+	// References are returned from textDocument/references, keyed like Hovers
+	References map[string]map[string][]lsp.Location
+
+	// Definitions and Declarations are returned from textDocument/definition
+	// and textDocument/declaration, keyed like Hovers
+	Definitions  map[string]map[string][]lsp.Location
+	Declarations map[string]map[string][]lsp.Location
+
+	// FoldingRanges are returned from textDocument/foldingRange
+	FoldingRanges map[string][]lsp.FoldingRange
+
+	// Errors makes any request fail, keyed by method or by
+	// "method path" for per-file failures
+	Errors map[string]string
+
+	// Crashes drops the connection instead of answering, the way a server
+	// that dies mid-request looks to the client. Keyed like Errors.
+	Crashes map[string]bool
+
+	// Delays holds back responses, keyed like Errors. Delayed requests still
+	// return early when the server is closed or the client sends
+	// $/cancelRequest for them.
+	Delays map[string]time.Duration
+
+	// Notifications are sent after "initialized"
+	Notifications []Notification
+
+	// FileNotifications are sent after textDocument/didOpen of a file
+	FileNotifications map[string][]Notification
+
+	// Diagnostics are published after textDocument/didOpen of a file. Like
+	// clangd, an empty list is published for files without an entry.
+	Diagnostics map[string][]lsp.Diagnostic
+
+	// Undiagnosed files get no diagnostics at all, like files the server
+	// never gets around to analysing
+	Undiagnosed map[string]bool
+}
+
+// IndexProgress returns the notifications clangd sends while building its
+// background index, reporting each of the given percentages
+func IndexProgress(percentages ...int) []Notification {
+	const token = "backgroundIndexProgress"
+
+	notes := []Notification{
+		{Method: "window/workDoneProgress/create", Params: map[string]any{"token": token}, Call: true},
+		{Method: "$/progress", Params: map[string]any{
+			"token": token,
+			"value": map[string]any{"kind": "begin", "title": "indexing", "percentage": 0},
+		}},
+	}
+	for _, pct := range percentages {
+		notes = append(notes, Notification{Method: "$/progress", Params: map[string]any{
+			"token": token,
+			"value": map[string]any{"kind": "report", "message": fmt.Sprintf("%d/100", pct), "percentage": pct},
+		}})
+	}
+	notes = append(notes, Notification{Method: "$/progress", Params: map[string]any{
+		"token": token,
+		"value": map[string]any{"kind": "end"},
+	}})
+	return notes
+}
+
+// Server is an in-process fake language server. Like a real server it
+// accepts a single session: serving further connections shares its state,
+// and an initialize after the first is rejected.
+type Server struct {
+	script Script
+	done   chan struct{}
+	once   sync.Once
+
+	mu          sync.Mutex
+	conns       []*jsonrpc2.Conn
+	initialized bool
+	requests    []*jsonrpc2.Request
+	pending     map[pendingKey]chan struct{} // closed on $/cancelRequest
+}
+
+// pendingKey identifies a request by the connection it arrived on, numbered
+// in the order of Serve calls, since every client numbers its requests from
+// the start
+type pendingKey struct {
+	session int
+	id      jsonrpc2.ID
+}
+
+// NewServer creates a fake server for script. Call Serve to start it.
+func NewServer(script Script) *Server {
+	return &Server{
+		script:  script,
+		done:    make(chan struct{}),
+		pending: make(map[pendingKey]chan struct{}),
+	}
+}
+
+// Start serves script over an in-memory pipe and returns the server and the
+// client end of the pipe, ready to be passed to lsp.NewClientFromStream
+func Start(script Script) (*Server, io.ReadWriteCloser) {
+	serverSide, clientSide := net.Pipe()
+	s := NewServer(script)
+	s.Serve(serverSide)
+	return s, clientSide
+}
+
+// Serve starts answering requests on rwc in the background
+func (s *Server) Serve(rwc io.ReadWriteCloser) {
+	s.mu.Lock()
+	session := len(s.conns)
+	s.conns = append(s.conns, nil)
+	s.mu.Unlock()
+
+	// Requests are recorded as they are read, before the asynchronous
+	// handlers run in any order
+	stream := jsonrpc2.NewBufferedStream(rwc, jsonrpc2.VSCodeObjectCodec{})
+	handler := func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+		return s.handle(ctx, session, conn, req)
+	}
+	conn := jsonrpc2.NewConn(context.Background(), stream,
+		jsonrpc2.AsyncHandler(jsonrpc2.HandlerWithError(handler).SuppressErrClosed()),
+		jsonrpc2.OnRecv(func(req *jsonrpc2.Request, resp *jsonrpc2.Response) {
+			if resp == nil {
+				s.receive(session, req)
+			}
+		}),
+	)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conns[session] = conn
+}
+
+// receive records a request or notification as it arrives on session
+func (s *Server) receive(session int, req *jsonrpc2.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+	if !req.Notif {
+		s.pending[pendingKey{session: session, id: req.ID}] = make(chan struct{})
+	}
+}
+
+// Close stops the server and releases any delayed requests
+func (s *Server) Close() error {
+	s.once.Do(func() { close(s.done) })
+
+	s.mu.Lock()
+	conns := s.conns
+	s.mu.Unlock()
+
+	var err error
+	for _, conn := range conns {
+		if conn == nil {
+			continue
+		}
+		if cerr := conn.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Requests returns every request and notification received so far
+func (s *Server) Requests() []*jsonrpc2.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*jsonrpc2.Request(nil), s.requests...)
+}
+
+// Received returns the methods received so far, in the order they arrived
+func (s *Server) Received() []string {
+	var methods []string
+	for _, req := range s.Requests() {
+		methods = append(methods, req.Method)
+	}
+	return methods
+}
+
+// Canceled returns the IDs of the requests the client canceled
+func (s *Server) Canceled() []jsonrpc2.ID {
+	var ids []jsonrpc2.ID
+	for _, req := range s.Requests() {
+		if req.Method != "$/cancelRequest" || req.Params == nil {
+			continue
+		}
+		var params cancelParams
+		if json.Unmarshal(*req.Params, &params) == nil {
+			ids = append(ids, params.ID)
+		}
+	}
+	return ids
+}
+
+func (s *Server) handle(ctx context.Context, session int, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+	key := pendingKey{session: session, id: req.ID}
+
+	s.mu.Lock()
+	canceled, ok := s.pending[key]
+	if !ok {
+		canceled = make(chan struct{}) // notifications can't be canceled
+	}
+	s.mu.Unlock()
+	if !req.Notif {
+		defer func() {
+			s.mu.Lock()
+			delete(s.pending, key)
+			s.mu.Unlock()
+		}()
+	}
+
+	if req.Method == "$/cancelRequest" {
+		s.cancel(session, req)
+		return nil, nil
+	}
+
+	var params documentParams
+	if req.Params != nil {
+		json.Unmarshal(*req.Params, &params)
+	}
+	path := uriToPath(params.TextDocument.URI)
+
+	if err := s.wait(req.Method, path, canceled); err != nil {
+		return nil, err
+	}
+	if s.script.Crashes[req.Method+" "+path] || s.script.Crashes[req.Method] {
+		s.Close()
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInternalError, Message: "server crashed"}
+	}
+	if msg, ok := s.lookupError(req.Method, path); ok {
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInternalError, Message: msg}
+	}
+
+	switch req.Method {
+	case "initialize":
+		s.mu.Lock()
+		again := s.initialized
+		s.initialized = true
+		s.mu.Unlock()
+		if again {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidRequest, Message: "server already initialized"}
+		}
+		if s.script.InitializeResult != nil {
+			return s.script.InitializeResult, nil
+		}
+		return s.defaultInitializeResult(), nil
+
+	case "initialized":
+		s.notify(ctx, conn, s.script.Notifications)
+		return nil, nil
+
+	case "textDocument/didOpen":
+		s.notify(ctx, conn, s.script.FileNotifications[path])
+		if s.script.Undiagnosed[path] {
+			return nil, nil
+		}
+		diags := s.script.Diagnostics[path]
+		if diags == nil {
+			diags = []lsp.Diagnostic{}
+		}
+		conn.Notify(ctx, "textDocument/publishDiagnostics", map[string]any{
+			"uri":         params.TextDocument.URI,
+			"diagnostics": diags,
+		})
+		return nil, nil
+
+	case "textDocument/didClose":
+		conn.Notify(ctx, "textDocument/publishDiagnostics", map[string]any{
+			"uri":         params.TextDocument.URI,
+			"diagnostics": []lsp.Diagnostic{},
+		})
+		return nil, nil
+
+	case "textDocument/documentSymbol":
+		if flat, ok := s.script.FlatSymbols[path]; ok {
+			return flat, nil
+		}
+		symbols := s.script.Symbols[path]
+		if symbols == nil {
+			symbols = []lsp.DocumentSymbol{}
+		}
+		return symbols, nil
+
+	case "textDocument/hover":
+		if hover, ok := s.script.Hovers[path][params.key()]; ok {
+			return hover, nil
+		}
+		return nil, nil
+
+	case "textDocument/prepareCallHierarchy":
+		return nonNil(s.script.CallHierarchy[path][params.key()]), nil
+
+	case "callHierarchy/incomingCalls":
+		return nonNil(s.script.IncomingCalls[params.Item.Name]), nil
+
+	case "callHierarchy/outgoingCalls":
+		return nonNil(s.script.OutgoingCalls[params.Item.Name]), nil
+
+	case "textDocument/prepareTypeHierarchy":
+		if s.script.TypeHierarchy == nil {
+			break
+		}
+		return nonNil(s.script.TypeHierarchy[path][params.key()]), nil
+
+	case "typeHierarchy/supertypes":
+		return nonNil(s.script.Supertypes[params.Item.Name]), nil
+
+	case "typeHierarchy/subtypes":
+		return nonNil(s.script.Subtypes[params.Item.Name]), nil
+
+	case "textDocument/typeHierarchy":
+		if item, ok := s.script.LegacyTypeHierarchy[path][params.key()]; ok {
+			return item, nil
+		}
+		return nil, nil
+
+	case "textDocument/references":
+		return nonNil(s.script.References[path][params.key()]), nil
+
+	case "textDocument/definition":
+		return nonNil(s.script.Definitions[path][params.key()]), nil
+
+	case "textDocument/declaration":
+		return nonNil(s.script.Declarations[path][params.key()]), nil
+
+	case "textDocument/foldingRange":
+		return nonNil(s.script.FoldingRanges[path]), nil
+
+	case "shutdown":
+		return nil, nil
+	}
+
+	if req.Notif {
+		return nil, nil
+	}
+	return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeMethodNotFound, Message: "method not found: " + req.Method}
+}
+
+// wait applies any scripted delay, failing if the server is closed or the
+// request canceled while waiting
+func (s *Server) wait(method, path string, canceled <-chan struct{}) error {
+	d, ok := s.script.Delays[method+" "+path]
+	if !ok {
+		d = s.script.Delays[method]
+	}
+	if d <= 0 {
+		return nil
+	}
+
+	select {
+	case <-time.After(d):
+		return nil
+	case <-s.done:
+		return &jsonrpc2.Error{Code: jsonrpc2.CodeInternalError, Message: "server closed"}
+	case <-canceled:
+		return &jsonrpc2.Error{Code: codeRequestCancelled, Message: "request cancelled"}
+	}
+}
+
+// codeRequestCancelled is the LSP error code for canceled requests
+const codeRequestCancelled = -32800
+
+type cancelParams struct {
+	ID jsonrpc2.ID `json:"id"`
+}
+
+// cancel releases the delayed request named by a $/cancelRequest
+func (s *Server) cancel(session int, req *jsonrpc2.Request) {
+	if req.Params == nil {
+		return
+	}
+	var params cancelParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// The entry stays until the request's handler returns, which may not
+	// have started yet
+	if ch, ok := s.pending[pendingKey{session: session, id: params.ID}]; ok {
+		select {
+		case <-ch:
+		default:
+			close(ch)
+		}
+	}
+}
+
+func (s *Server) lookupError(method, path string) (string, bool) {
+	if msg, ok := s.script.Errors[method+" "+path]; ok {
+		return msg, true
+	}
+	msg, ok := s.script.Errors[method]
+	return msg, ok
+}
+
+func (s *Server) notify(ctx context.Context, conn *jsonrpc2.Conn, notes []Notification) {
+	for _, n := range notes {
+		if n.Call {
+			conn.Call(ctx, n.Method, n.Params, nil)
+			continue
+		}
+		conn.Notify(ctx, n.Method, n.Params)
+	}
+}
+
+type documentParams struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+	Position lsp.Position `json:"position"`
+	Item     struct {
+		Name string `json:"name"`
+	} `json:"item"`
+}
+
+// key returns the "line:character" key of the requested position
+func (p documentParams) key() string {
+	return fmt.Sprintf("%d:%d", p.Position.Line, p.Position.Character)
+}
+
+// nonNil replaces a nil slice with an empty one so results encode as []
+func nonNil[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+	return items
+}
+
+func uriToPath(uri string) string {
+	return fileuri.ToPath(uri)
+}
+
+func (s *Server) defaultInitializeResult() map[string]any {
+	return map[string]any{
+		"capabilities": map[string]any{
+			"documentSymbolProvider": true,
+			"hoverProvider":          true,
+			"referencesProvider":     true,
+			"definitionProvider":     true,
+			"declarationProvider":    true,
+			"foldingRangeProvider":   true,
+			"callHierarchyProvider":  true,
+			"typeHierarchyProvider":  s.script.TypeHierarchy != nil,
+		},
+		"serverInfo": map[string]any{
+			"name":    "fake-clangd",
+			"version": "0.0.0",
+		},
+	}
+}
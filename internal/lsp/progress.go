@@ -0,0 +1,180 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProgressEvent is a work done progress notification sent by the server
+type ProgressEvent struct {
+	Token      string
+	Kind       string // "begin", "report" or "end"
+	Title      string
+	Message    string
+	Percentage int
+}
+
+// progressParams is the payload of $/progress
+type progressParams struct {
+	Token json.RawMessage `json:"token"`
+	Value struct {
+		Kind       string `json:"kind"`
+		Title      string `json:"title"`
+		Message    string `json:"message"`
+		Percentage int    `json:"percentage"`
+	} `json:"value"`
+}
+
+// createProgressParams is the payload of window/workDoneProgress/create
+type createProgressParams struct {
+	Token json.RawMessage `json:"token"`
+}
+
+// progressTracker follows work done progress reported by the server and
+// keeps track of the background index in particular
+type progressTracker struct {
+	indexToken string
+
+	mu          sync.Mutex
+	tokens      map[string]bool
+	last        ProgressEvent
+	version     int
+	indexActive bool
+	indexDone   bool
+	changed     chan struct{}
+}
+
+func newProgressTracker(indexToken string) *progressTracker {
+	return &progressTracker{
+		indexToken: indexToken,
+		tokens:     make(map[string]bool),
+		changed:    make(chan struct{}),
+	}
+}
+
+// create records a token announced through window/workDoneProgress/create
+func (p *progressTracker) create(params createProgressParams) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tokens[progressToken(params.Token)] = true
+}
+
+// update applies a $/progress notification
+func (p *progressTracker) update(params progressParams) {
+	ev := ProgressEvent{
+		Token:      progressToken(params.Token),
+		Kind:       params.Value.Kind,
+		Title:      params.Value.Title,
+		Message:    params.Value.Message,
+		Percentage: params.Value.Percentage,
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if ev.Token == p.indexToken {
+		switch ev.Kind {
+		case "begin", "report":
+			p.indexActive = true
+		case "end":
+			p.indexActive = false
+			p.indexDone = true
+			if ev.Percentage == 0 {
+				ev.Percentage = 100
+			}
+		}
+		// Report and end messages don't repeat the title
+		if ev.Title == "" {
+			ev.Title = p.last.Title
+		}
+		p.last = ev
+		p.version++
+	}
+
+	if ev.Kind == "end" {
+		delete(p.tokens, ev.Token)
+	}
+
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// indexState returns whether the background index is ready, the latest index
+// progress event with its version, and a channel closed on the next update
+func (p *progressTracker) indexState() (bool, ProgressEvent, int, <-chan struct{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.indexDone && !p.indexActive, p.last, p.version, p.changed
+}
+
+// progressToken normalizes a progress token, which may be a string or a number
+func progressToken(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return strings.TrimSpace(string(raw))
+}
+
+// IndexReady reports whether the server's background index has finished
+func (c *Client) IndexReady() bool {
+	ready, _, _, _ := c.progress.indexState()
+	return ready
+}
+
+// WaitForIndex blocks until the server's background index has finished or
+// ctx is done. onProgress, if non-nil, is called with each new index progress
+// event while waiting. Servers that don't report index progress are treated
+// as ready immediately.
+func (c *Client) WaitForIndex(ctx context.Context, onProgress func(ProgressEvent)) error {
+	if c.spec.Quirks.IndexProgressToken == "" {
+		return nil
+	}
+
+	seen := 0
+	for {
+		ready, last, version, changed := c.progress.indexState()
+		if version != seen {
+			seen = version
+			if onProgress != nil {
+				onProgress(last)
+			}
+		}
+		if ready {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// IndexStarted waits up to grace for the server to report background index
+// progress and reports whether it did. A server whose index is already up to
+// date, such as a running server that finished indexing before the client
+// connected, reports none, so WaitForIndex would never return.
+func (c *Client) IndexStarted(ctx context.Context, grace time.Duration) bool {
+	if c.spec.Quirks.IndexProgressToken == "" {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, grace)
+	defer cancel()
+	for {
+		_, _, version, changed := c.progress.indexState()
+		if version > 0 {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+
+	"clangd-parser/internal/fileuri"
+)
+
+// References returns every location referencing the symbol at pos in an open
+// document. Results from other files are only complete once the server's
+// index is ready.
+func (c *Client) References(ctx context.Context, filePath string, pos Position, includeDeclaration bool) ([]Location, error) {
+	if !c.init.Capabilities.ReferencesProvider {
+		return nil, fmt.Errorf("references: %w", ErrNotSupported)
+	}
+
+	ctx, cancel := withTimeout(ctx, c.Timeouts.Request)
+	defer cancel()
+
+	params := map[string]any{
+		"textDocument": map[string]any{
+			"uri": fileuri.FromPath(filePath),
+		},
+		"position": pos,
+		"context": map[string]any{
+			"includeDeclaration": includeDeclaration,
+		},
+	}
+
+	var locations []Location
+	if err := c.call(ctx, "textDocument/references", params, &locations); err != nil {
+		return nil, fmt.Errorf("references: %w", err)
+	}
+
+	return locations, nil
+}
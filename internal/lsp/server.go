@@ -0,0 +1,110 @@
+package lsp
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Quirks describes behaviour that differs between language server backends
+type Quirks struct {
+	// HierarchicalSymbols is true if the server answers documentSymbol with
+	// nested DocumentSymbol results. Servers without it return a flat list of
+	// SymbolInformation, which the client nests by range.
+	HierarchicalSymbols bool
+
+	// IndexProgressToken is the work done progress token the server uses
+	// for its background index. Empty if the server doesn't report it.
+	IndexProgressToken string
+}
+
+// ServerSpec describes how to launch and talk to a language server
+type ServerSpec struct {
+	Name    string
+	Command string   // binary name or path
+	Args    []string // arguments passed to Command
+	Env     []string // extra KEY=VALUE entries added to the environment
+
+	// InitializationOptions is sent as-is in the initialize request
+	InitializationOptions any
+
+	// LanguageIDs maps lowercase file extensions to LSP languageIds.
+	// DefaultLanguageID is used for anything else.
+	LanguageIDs       map[string]string
+	DefaultLanguageID string
+
+	Quirks Quirks
+
+	// Transcript, if set, receives every message exchanged with the server
+	// as a transcript that the transcript package can replay
+	Transcript io.Writer
+}
+
+// defaultLanguageIDs covers the C family extensions both clangd and ccls accept
+var defaultLanguageIDs = map[string]string{
+	".c":  "c",
+	".m":  "objective-c",
+	".mm": "objective-cpp",
+}
+
+// ClangdSpec returns the spec for clangd using the compilation database in
+// compileDBPath
+func ClangdSpec(compileDBPath string) ServerSpec {
+	return ServerSpec{
+		Name:    "clangd",
+		Command: "clangd",
+		Args: []string{
+			"--compile-commands-dir=" + compileDBPath,
+			"--background-index",
+			"--log=error",
+		},
+		LanguageIDs:       defaultLanguageIDs,
+		DefaultLanguageID: "cpp",
+		Quirks: Quirks{
+			HierarchicalSymbols: true,
+			IndexProgressToken:  "backgroundIndexProgress",
+		},
+	}
+}
+
+// CclsSpec returns the spec for ccls using the compilation database in
+// compileDBPath
+func CclsSpec(compileDBPath string) ServerSpec {
+	return ServerSpec{
+		Name:    "ccls",
+		Command: "ccls",
+		Args:    []string{"--log-file=/dev/null"},
+		InitializationOptions: map[string]any{
+			"compilationDatabaseDirectory": compileDBPath,
+		},
+		LanguageIDs:       defaultLanguageIDs,
+		DefaultLanguageID: "cpp",
+		Quirks: Quirks{
+			HierarchicalSymbols: false,
+		},
+	}
+}
+
+// SpecByName returns the built-in spec for a backend name
+func SpecByName(name, compileDBPath string) (ServerSpec, error) {
+	switch strings.ToLower(name) {
+	case "clangd":
+		return ClangdSpec(compileDBPath), nil
+	case "ccls":
+		return CclsSpec(compileDBPath), nil
+	}
+	return ServerSpec{}, fmt.Errorf("unknown language server %q", name)
+}
+
+// LanguageID returns the languageId to use when opening filePath
+func (s ServerSpec) LanguageID(filePath string) string {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if id, ok := s.LanguageIDs[ext]; ok {
+		return id
+	}
+	if s.DefaultLanguageID != "" {
+		return s.DefaultLanguageID
+	}
+	return "cpp"
+}
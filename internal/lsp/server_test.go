@@ -0,0 +1,107 @@
+package lsp
+
+import (
+	"testing"
+)
+
+func TestSpecByName(t *testing.T) {
+	for _, name := range []string{"clangd", "ccls", "CLANGD"} {
+		spec, err := SpecByName(name, "/tmp")
+		if err != nil {
+			t.Errorf("SpecByName(%q) failed: %v", name, err)
+			continue
+		}
+		if spec.Command == "" {
+			t.Errorf("SpecByName(%q) has no command", name)
+		}
+	}
+
+	if _, err := SpecByName("pylsp", "/tmp"); err == nil {
+		t.Error("Expected error for unknown backend")
+	}
+}
+
+func TestLanguageID(t *testing.T) {
+	spec := ClangdSpec("/tmp")
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"main.cpp", "cpp"},
+		{"header.h", "cpp"},
+		{"legacy.c", "c"},
+		{"bridge.MM", "objective-cpp"},
+	}
+
+	for _, tt := range tests {
+		if got := spec.LanguageID(tt.path); got != tt.expected {
+			t.Errorf("LanguageID(%q) = %s, expected %s", tt.path, got, tt.expected)
+		}
+	}
+}
+
+func TestNestSymbolInformation(t *testing.T) {
+	rng := func(startLine, endLine int) Location {
+		return Location{Range: Range{
+			Start: Position{Line: startLine},
+			End:   Position{Line: endLine, Character: 1},
+		}}
+	}
+
+	infos := []SymbolInformation{
+		{Name: "method", Kind: SymbolKindMethod, Location: rng(3, 5)},
+		{Name: "free", Kind: SymbolKindFunction, Location: rng(10, 12)},
+		{Name: "ns", Kind: SymbolKindNamespace, Location: rng(0, 8)},
+		{Name: "Cls", Kind: SymbolKindClass, Location: rng(2, 7)},
+	}
+
+	symbols := NestSymbolInformation(infos, nil)
+
+	if len(symbols) != 2 || symbols[0].Name != "ns" || symbols[1].Name != "free" {
+		t.Fatalf("Expected roots ns and free, got %+v", symbols)
+	}
+	cls := symbols[0].Children
+	if len(cls) != 1 || cls[0].Name != "Cls" {
+		t.Fatalf("Expected Cls under ns, got %+v", cls)
+	}
+	if len(cls[0].Children) != 1 || cls[0].Children[0].Name != "method" {
+		t.Fatalf("Expected method under Cls, got %+v", cls[0].Children)
+	}
+	if cls[0].SelectionRange != cls[0].Range || !cls[0].Flat {
+		t.Error("Expected SelectionRange to default to the full range of a flat symbol")
+	}
+}
+
+func TestNameRange(t *testing.T) {
+	at := func(line, from, to int) Range {
+		return Range{Start: Position{Line: line, Character: from}, End: Position{Line: line, Character: to}}
+	}
+	tests := []struct {
+		source   string
+		name     string
+		encoding PositionEncoding
+		want     Range
+	}{
+		{"char a = 0;", "a", PositionEncodingUTF8, at(0, 5, 6)},
+		{"int count, counter;", "counter", PositionEncodingUTF8, at(0, 11, 18)},
+		{"void Foo::bar() {}", "Foo::bar", PositionEncodingUTF8, at(0, 10, 13)},
+		{"template <class T>\nT Shape<T>::area() const {}", "Shape<T>::area", PositionEncodingUTF8, at(1, 12, 16)},
+		{"bool Foo::operator<(Foo) const;", "Foo::operator<", PositionEncodingUTF8, at(0, 10, 19)},
+		{"/* 😀 */ int x;", "x", PositionEncodingUTF16, at(0, 13, 14)},
+		{"#define WRAP(x) x", "wrapped", PositionEncodingUTF8, at(0, 0, 17)},
+	}
+
+	for _, tt := range tests {
+		text := NewText([]byte(tt.source), tt.encoding)
+		lines := text.Lines()
+		full := Range{End: Position{Line: len(lines) - 1, Character: text.encoding.Character(lines[len(lines)-1], len(lines[len(lines)-1]))}}
+		if got := nameRange(text, full, tt.name); got != tt.want {
+			t.Errorf("nameRange(%q, %q) = %+v, expected %+v", tt.source, tt.name, got, tt.want)
+		}
+	}
+
+	if got := nameRange(nil, at(0, 0, 11), "a"); got != at(0, 0, 11) {
+		t.Errorf("Expected the full range without text, got %+v", got)
+	}
+}
@@ -0,0 +1,173 @@
+package lsp
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// decodeDocumentSymbols decodes a documentSymbol result, which may be either
+// DocumentSymbol[] or SymbolInformation[]. Flat results are nested by range,
+// with names located in the document loaded by text.
+func decodeDocumentSymbols(raw json.RawMessage, text func() *Text) ([]DocumentSymbol, error) {
+	var probe []struct {
+		Location *Location `json:"location"`
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		return nil, err
+	}
+
+	if len(probe) == 0 || probe[0].Location == nil {
+		var symbols []DocumentSymbol
+		if err := json.Unmarshal(raw, &symbols); err != nil {
+			return nil, err
+		}
+		return symbols, nil
+	}
+
+	var infos []SymbolInformation
+	if err := json.Unmarshal(raw, &infos); err != nil {
+		return nil, err
+	}
+	return NestSymbolInformation(infos, text()), nil
+}
+
+// NestSymbolInformation turns a flat symbol list into a DocumentSymbol tree.
+// A symbol becomes the child of the innermost symbol whose range encloses it.
+// Flat results carry no selection range or detail, so SelectionRange is the
+// first occurrence of the symbol's name within its range in text, and Detail
+// is left empty. Without text, or if the name isn't found, SelectionRange is
+// the full range.
+func NestSymbolInformation(infos []SymbolInformation, text *Text) []DocumentSymbol {
+	sorted := make([]SymbolInformation, len(infos))
+	copy(sorted, infos)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i].Location.Range, sorted[j].Location.Range
+		if a.Start != b.Start {
+			return positionBefore(a.Start, b.Start)
+		}
+		// Outer symbols first when two ranges start at the same place
+		return positionBefore(b.End, a.End)
+	})
+
+	type node struct {
+		symbol   DocumentSymbol
+		children []*node
+	}
+
+	var roots []*node
+	var stack []*node
+	for _, info := range sorted {
+		n := &node{symbol: DocumentSymbol{
+			Name:           info.Name,
+			Kind:           info.Kind,
+			Range:          info.Location.Range,
+			SelectionRange: nameRange(text, info.Location.Range, info.Name),
+			Flat:           true,
+		}}
+
+		for len(stack) > 0 && !rangeContains(stack[len(stack)-1].symbol.Range, n.symbol.Range) {
+			stack = stack[:len(stack)-1]
+		}
+
+		if len(stack) == 0 {
+			roots = append(roots, n)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, n)
+		}
+		stack = append(stack, n)
+	}
+
+	var build func(nodes []*node) []DocumentSymbol
+	build = func(nodes []*node) []DocumentSymbol {
+		var out []DocumentSymbol
+		for _, n := range nodes {
+			n.symbol.Children = build(n.children)
+			out = append(out, n.symbol)
+		}
+		return out
+	}
+
+	return build(roots)
+}
+
+// nameRange returns the range of the first occurrence of name as a whole
+// word within r. Of a qualified name such as Foo::bar only the last part is
+// selected, as servers do for out-of-line definitions.
+func nameRange(text *Text, r Range, name string) Range {
+	if text == nil || name == "" {
+		return r
+	}
+
+	last, qualifier := name, name
+	if i := strings.Index(name, "operator"); i >= 0 {
+		// Conversion operators may name a qualified type
+		qualifier = name[:i]
+	}
+	if i := strings.LastIndex(qualifier, "::"); i >= 0 {
+		last = name[i+2:]
+	}
+	for _, candidate := range []string{name, last} {
+		for n := r.Start.Line; n <= r.End.Line; n++ {
+			line := text.Line(n)
+			from, to := 0, len(line)
+			if n == r.Start.Line {
+				from = text.Column(r.Start)
+			}
+			if n == r.End.Line {
+				to = text.Column(r.End)
+			}
+			if from > to {
+				continue
+			}
+			if col := findWord(line[from:to], candidate); col >= 0 {
+				start := from + col + len(candidate) - len(last)
+				return Range{
+					Start: Position{Line: n, Character: text.encoding.Character(line, start)},
+					End:   Position{Line: n, Character: text.encoding.Character(line, start+len(last))},
+				}
+			}
+		}
+		if last == name {
+			break
+		}
+	}
+	return r
+}
+
+// findWord returns the byte offset of the first occurrence of word in s that
+// isn't part of a longer identifier, or -1
+func findWord(s, word string) int {
+	for offset := 0; ; {
+		i := strings.Index(s[offset:], word)
+		if i < 0 {
+			return -1
+		}
+		i += offset
+		end := i + len(word)
+		if (i == 0 || !isIdentByte(s[i-1]) || !isIdentByte(word[0])) &&
+			(end == len(s) || !isIdentByte(s[end]) || !isIdentByte(word[len(word)-1])) {
+			return i
+		}
+		offset = i + 1
+	}
+}
+
+func isIdentByte(b byte) bool {
+	return b == '_' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9'
+}
+
+func positionBefore(a, b Position) bool {
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	return a.Character < b.Character
+}
+
+func rangeContains(outer, inner Range) bool {
+	return !positionBefore(inner.Start, outer.Start) && !positionBefore(outer.End, inner.End)
+}
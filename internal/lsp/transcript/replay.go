@@ -0,0 +1,239 @@
+package transcript
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// Replayer serves a recorded session back to a client in place of the
+// server. Client messages are matched against the transcript by kind and
+// method only, so paths, process IDs and file contents may differ from the
+// recording. Document and root URIs in recorded server messages are
+// rewritten to the ones the client uses now.
+type Replayer struct {
+	entries []Entry
+	stream  jsonrpc2.ObjectStream
+	done    chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+// Replay serves entries over an in-memory pipe and returns the replayer and
+// the client end of the pipe, ready to be passed to lsp.NewClientFromStream
+func Replay(entries []Entry) (*Replayer, io.ReadWriteCloser) {
+	serverSide, clientSide := net.Pipe()
+	r := &Replayer{
+		entries: entries,
+		stream:  jsonrpc2.NewBufferedStream(serverSide, jsonrpc2.VSCodeObjectCodec{}),
+		done:    make(chan struct{}),
+	}
+	go r.serve()
+	return r, clientSide
+}
+
+// Err returns the first point where the client diverged from the transcript
+func (r *Replayer) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Close stops serving and waits for the replay to finish
+func (r *Replayer) Close() error {
+	err := r.stream.Close()
+	<-r.done
+	return err
+}
+
+func (r *Replayer) fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// envelope holds the fields that identify a JSON-RPC message
+type envelope struct {
+	ID     *json.RawMessage `json:"id,omitempty"`
+	Method string           `json:"method,omitempty"`
+	Params struct {
+		RootURI      string `json:"rootUri"`
+		TextDocument struct {
+			URI string `json:"uri"`
+		} `json:"textDocument"`
+	} `json:"params"`
+}
+
+func parseEnvelope(msg json.RawMessage) envelope {
+	var e envelope
+	json.Unmarshal(msg, &e)
+	return e
+}
+
+func (e envelope) kind() string {
+	switch {
+	case e.Method != "" && e.ID != nil:
+		return "request " + e.Method
+	case e.Method != "":
+		return "notification " + e.Method
+	}
+	return "response"
+}
+
+func (r *Replayer) serve() {
+	defer close(r.done)
+	defer r.stream.Close()
+
+	// Recorded request IDs and URIs mapped to the ones the client uses now
+	ids := make(map[string]json.RawMessage)
+	uris := newURIMap()
+
+	var held json.RawMessage
+	for i := 0; i < len(r.entries); i++ {
+		e := r.entries[i]
+
+		if e.Dir == Recv {
+			if err := r.stream.WriteObject(uris.rewrite(remapID(e.Message, ids))); err != nil {
+				r.fail(fmt.Errorf("message %d: client went away: %w", i+1, err))
+				return
+			}
+			continue
+		}
+
+		live := held
+		held = nil
+		if live == nil {
+			if err := r.stream.ReadObject(&live); err != nil {
+				r.fail(fmt.Errorf("message %d: client went away: %w", i+1, err))
+				return
+			}
+		}
+
+		want, got := parseEnvelope(e.Message), parseEnvelope(live)
+		switch {
+		case want.kind() == got.kind():
+			if want.Method != "" && want.ID != nil {
+				ids[string(*want.ID)] = *got.ID
+			}
+			uris.add(want.Params.TextDocument.URI, got.Params.TextDocument.URI, false)
+			uris.add(want.Params.RootURI, got.Params.RootURI, true)
+		case want.Method == "$/cancelRequest":
+			// Cancellation depends on timing; the recorded one didn't happen
+			held = live
+		case got.Method == "$/cancelRequest":
+			// Nor did this one happen during the recording
+			i--
+		default:
+			r.fail(fmt.Errorf("message %d: client sent %s, transcript has %s", i+1, got.kind(), want.kind()))
+			return
+		}
+	}
+
+	r.drain(held)
+}
+
+// drain answers whatever the client sends after the transcript ended, so
+// that closing the client doesn't wait for a shutdown reply
+func (r *Replayer) drain(live json.RawMessage) {
+	for {
+		if live == nil {
+			if err := r.stream.ReadObject(&live); err != nil {
+				return
+			}
+		}
+
+		got := parseEnvelope(live)
+		live = nil
+
+		switch {
+		case got.Method == "exit":
+			return
+		case got.Method == "shutdown" && got.ID != nil:
+			r.stream.WriteObject(map[string]any{"jsonrpc": "2.0", "id": got.ID, "result": nil})
+		case got.Method != "" && got.ID != nil:
+			r.stream.WriteObject(map[string]any{
+				"jsonrpc": "2.0",
+				"id":      got.ID,
+				"error":   map[string]any{"code": jsonrpc2.CodeInternalError, "message": "not in transcript: " + got.Method},
+			})
+		}
+	}
+}
+
+// remapID rewrites the ID of a recorded response to the one the client used
+// for the matching request
+func remapID(msg json.RawMessage, ids map[string]json.RawMessage) json.RawMessage {
+	e := parseEnvelope(msg)
+	if e.Method != "" || e.ID == nil {
+		return msg
+	}
+	live, ok := ids[string(*e.ID)]
+	if !ok || string(live) == string(*e.ID) {
+		return msg
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(msg, &fields); err != nil {
+		return msg
+	}
+	fields["id"] = live
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return msg
+	}
+	return out
+}
+
+// uriMap rewrites recorded URIs to live ones inside raw messages
+type uriMap struct {
+	replacer *strings.Replacer
+	pairs    map[string]string
+}
+
+func newURIMap() *uriMap {
+	return &uriMap{pairs: make(map[string]string)}
+}
+
+// add maps a recorded URI to a live one. Roots also map every URI below
+// them.
+func (m *uriMap) add(recorded, live string, root bool) {
+	if recorded == "" || live == "" || recorded == live {
+		return
+	}
+	// URIs appear as JSON strings, so anchor on the opening quote
+	m.pairs[`"`+recorded+`"`] = `"` + live + `"`
+	if root {
+		m.pairs[`"`+strings.TrimSuffix(recorded, "/")+`/`] = `"` + strings.TrimSuffix(live, "/") + `/`
+	}
+	m.replacer = nil
+}
+
+func (m *uriMap) rewrite(msg json.RawMessage) json.RawMessage {
+	if len(m.pairs) == 0 {
+		return msg
+	}
+	if m.replacer == nil {
+		// Longest first, so documents win over their root
+		olds := make([]string, 0, len(m.pairs))
+		for old := range m.pairs {
+			olds = append(olds, old)
+		}
+		sort.Slice(olds, func(i, j int) bool { return len(olds[i]) > len(olds[j]) })
+
+		var args []string
+		for _, old := range olds {
+			args = append(args, old, m.pairs[old])
+		}
+		m.replacer = strings.NewReplacer(args...)
+	}
+	return json.RawMessage(m.replacer.Replace(string(msg)))
+}
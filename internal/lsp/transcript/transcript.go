@@ -0,0 +1,106 @@
+// Package transcript records the JSON-RPC traffic of a language server
+// session and serves it back without the server. Transcripts are JSON lines,
+// one message per line, so they can be attached to bug reports and checked
+// in as test data.
+package transcript
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// Direction of a message, seen from the client
+const (
+	Send = "send" // client to server
+	Recv = "recv" // server to client
+)
+
+// Entry is a single message of a session
+type Entry struct {
+	Dir     string          `json:"dir"`
+	Millis  int64           `json:"ms"` // since the session started
+	Message json.RawMessage `json:"message"`
+}
+
+// Recorder writes the messages of a session to a transcript
+type Recorder struct {
+	mu    sync.Mutex
+	enc   *json.Encoder
+	start time.Time
+}
+
+// NewRecorder returns a recorder writing to w. Write errors are ignored so
+// a failing transcript never breaks the session.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{enc: json.NewEncoder(w), start: time.Now()}
+}
+
+func (r *Recorder) record(dir string, msg json.RawMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.enc.Encode(Entry{Dir: dir, Millis: time.Since(r.start).Milliseconds(), Message: msg})
+}
+
+// Wrap returns a stream that records every object passing through stream
+func (r *Recorder) Wrap(stream jsonrpc2.ObjectStream) jsonrpc2.ObjectStream {
+	return &recordingStream{ObjectStream: stream, rec: r}
+}
+
+type recordingStream struct {
+	jsonrpc2.ObjectStream
+	rec     *Recorder
+	writeMu sync.Mutex // keeps transcript and wire order of sends the same
+}
+
+func (s *recordingStream) WriteObject(obj any) error {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.rec.record(Send, data)
+	return s.ObjectStream.WriteObject(json.RawMessage(data))
+}
+
+func (s *recordingStream) ReadObject(v any) error {
+	var raw json.RawMessage
+	if err := s.ObjectStream.ReadObject(&raw); err != nil {
+		return err
+	}
+	s.rec.record(Recv, raw)
+	return json.Unmarshal(raw, v)
+}
+
+// Load reads a transcript written by a Recorder
+func Load(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("transcript line %d: %w", line, err)
+		}
+		if e.Dir != Send && e.Dir != Recv {
+			return nil, fmt.Errorf("transcript line %d: unknown direction %q", line, e.Dir)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read transcript: %w", err)
+	}
+
+	return entries, nil
+}
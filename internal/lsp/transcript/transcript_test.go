@@ -0,0 +1,166 @@
+package transcript_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"clangd-parser/internal/lsp"
+	"clangd-parser/internal/lsp/lsptest"
+	"clangd-parser/internal/lsp/transcript"
+)
+
+func writeSource(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "widget.cpp")
+	if err := os.WriteFile(path, []byte("void draw() {}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+	return path
+}
+
+// record runs a session against the fake server and returns its transcript
+func record(t *testing.T, file string) ([]lsp.DocumentSymbol, *lsp.Hover, []transcript.Entry) {
+	t.Helper()
+	ctx := context.Background()
+
+	server, rwc := lsptest.Start(lsptest.Script{
+		Symbols: map[string][]lsp.DocumentSymbol{
+			file: {{Name: "draw", Detail: "void ()", Kind: lsp.SymbolKindFunction}},
+		},
+		Hovers: map[string]map[string]any{
+			file: {"0:5": map[string]any{"contents": map[string]any{"kind": "markdown", "value": "### function `draw`"}}},
+		},
+		Diagnostics: map[string][]lsp.Diagnostic{
+			file: {{Severity: lsp.DiagnosticSeverityWarning, Message: "unused"}},
+		},
+		Notifications: lsptest.IndexProgress(100),
+	})
+	defer server.Close()
+
+	var buf bytes.Buffer
+	spec := lsp.ClangdSpec("")
+	spec.Transcript = &buf
+
+	client, err := lsp.NewClientFromStream(ctx, rwc, spec, t.TempDir())
+	if err != nil {
+		t.Fatalf("NewClientFromStream failed: %v", err)
+	}
+	symbols, hover := session(t, client, file)
+	client.Close(ctx)
+
+	entries, err := transcript.Load(&buf)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	return symbols, hover, entries
+}
+
+func session(t *testing.T, client *lsp.Client, file string) ([]lsp.DocumentSymbol, *lsp.Hover) {
+	t.Helper()
+	ctx := context.Background()
+
+	if err := client.WaitForIndex(ctx, nil); err != nil {
+		t.Fatalf("WaitForIndex failed: %v", err)
+	}
+	if err := client.OpenDocument(ctx, file); err != nil {
+		t.Fatalf("OpenDocument failed: %v", err)
+	}
+	defer client.CloseDocument(ctx, file)
+
+	symbols, err := client.DocumentSymbols(ctx, file)
+	if err != nil {
+		t.Fatalf("DocumentSymbols failed: %v", err)
+	}
+	if diags, _ := client.Diagnostics(file); len(diags) != 1 {
+		t.Errorf("Expected the diagnostic to arrive, got %+v", diags)
+	}
+	hover, err := client.Hover(ctx, file, lsp.Position{Line: 0, Character: 5})
+	if err != nil {
+		t.Fatalf("Hover failed: %v", err)
+	}
+	return symbols, hover
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	ctx := context.Background()
+	file := writeSource(t)
+
+	symbols, hover, entries := record(t, file)
+
+	methods := make(map[string]bool)
+	for _, e := range entries {
+		if m := string(e.Message); strings.Contains(m, `"method"`) {
+			for _, want := range []string{"initialize", "textDocument/didOpen", "textDocument/publishDiagnostics", "$/progress", "shutdown"} {
+				if strings.Contains(m, `"method":"`+want+`"`) {
+					methods[want] = true
+				}
+			}
+		}
+	}
+	if len(methods) != 5 {
+		t.Errorf("Expected the transcript to contain both directions, found %v", methods)
+	}
+
+	// Replay against a copy of the file at another path
+	replayed := writeSource(t)
+	replayer, rwc := transcript.Replay(entries)
+	defer replayer.Close()
+
+	client, err := lsp.NewClientFromStream(ctx, rwc, lsp.ClangdSpec(""), t.TempDir())
+	if err != nil {
+		t.Fatalf("NewClientFromStream on replay failed: %v", err)
+	}
+	gotSymbols, gotHover := session(t, client, replayed)
+	client.Close(ctx)
+
+	if !reflect.DeepEqual(gotSymbols, symbols) {
+		t.Errorf("Replayed symbols differ:\n got %+v\nwant %+v", gotSymbols, symbols)
+	}
+	if !reflect.DeepEqual(gotHover, hover) {
+		t.Errorf("Replayed hover differs:\n got %+v\nwant %+v", gotHover, hover)
+	}
+	if err := replayer.Err(); err != nil {
+		t.Errorf("Unexpected divergence: %v", err)
+	}
+	t.Logf("✓ Replayed %d messages", len(entries))
+}
+
+func TestReplayDivergence(t *testing.T) {
+	ctx := context.Background()
+	file := writeSource(t)
+	_, _, entries := record(t, file)
+
+	replayer, rwc := transcript.Replay(entries)
+	defer replayer.Close()
+
+	client, err := lsp.NewClientFromStream(ctx, rwc, lsp.ClangdSpec(""), t.TempDir())
+	if err != nil {
+		t.Fatalf("NewClientFromStream on replay failed: %v", err)
+	}
+	defer client.Close(ctx)
+
+	// The recording opened the document before asking for references
+	if _, err := client.References(ctx, file, lsp.Position{}, false); err == nil {
+		t.Error("Expected a request missing from the transcript to fail")
+	}
+	replayer.Close()
+
+	err = replayer.Err()
+	if err == nil || !strings.Contains(err.Error(), "textDocument/references") {
+		t.Errorf("Expected the divergence to name the request, got %v", err)
+	}
+}
+
+func TestLoadRejectsGarbage(t *testing.T) {
+	if _, err := transcript.Load(strings.NewReader(`{"dir":"sideways","message":{}}`)); err == nil {
+		t.Error("Expected an unknown direction to be rejected")
+	}
+	if _, err := transcript.Load(strings.NewReader("not json\n")); err == nil {
+		t.Error("Expected invalid JSON to be rejected")
+	}
+}
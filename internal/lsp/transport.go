@@ -0,0 +1,147 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Transport establishes the stream a client talks to its server over
+type Transport interface {
+	// Open starts or connects to the server and returns the stream to it
+	Open(ctx context.Context) (io.ReadWriteCloser, error)
+
+	// Owned reports whether the server lives only as long as the client.
+	// Closing the client shuts owned servers down; servers that are shared
+	// with other clients are left running.
+	Owned() bool
+
+	// Kill stops an owned server without the shutdown handshake
+	Kill() error
+
+	// Wait waits for an owned server to exit after the stream was closed
+	Wait() error
+}
+
+// Stdio starts the server as a child process and talks to it over its
+// standard input and output
+type Stdio struct {
+	Command string
+	Args    []string
+	Env     []string // extra KEY=VALUE entries added to the environment
+
+	cmd *exec.Cmd
+}
+
+// NewStdio returns the transport that launches the server described by spec
+func NewStdio(spec ServerSpec) *Stdio {
+	return &Stdio{Command: spec.Command, Args: spec.Args, Env: spec.Env}
+}
+
+func (t *Stdio) Open(ctx context.Context) (io.ReadWriteCloser, error) {
+	cmd := exec.Command(t.Command, t.Args...)
+	if len(t.Env) > 0 {
+		cmd.Env = append(os.Environ(), t.Env...)
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("stdin pipe: %w", err)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("stdout pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	t.cmd = cmd
+	return &stdrwc{stdout, stdin}, nil
+}
+
+func (t *Stdio) Owned() bool { return true }
+
+func (t *Stdio) Kill() error {
+	if t.cmd == nil || t.cmd.Process == nil {
+		return nil
+	}
+	return t.cmd.Process.Kill()
+}
+
+func (t *Stdio) Wait() error {
+	if t.cmd == nil || t.cmd.Process == nil {
+		return nil
+	}
+	return t.cmd.Wait()
+}
+
+// stdrwc wraps stdin/stdout for jsonrpc2
+type stdrwc struct {
+	io.ReadCloser
+	io.WriteCloser
+}
+
+func (s *stdrwc) Close() error {
+	s.ReadCloser.Close()
+	return s.WriteCloser.Close()
+}
+
+// Socket connects to a server that is already running and listening on a
+// TCP address or a Unix domain socket, such as a warm clangd behind a
+// socket proxy. The server is shared: closing the client only closes the
+// connection.
+type Socket struct {
+	Network string // "tcp" or "unix"
+	Address string
+}
+
+// ParseSocket parses "tcp://host:port", "unix:///path/to/socket", a bare
+// "host:port" or a bare socket path
+func ParseSocket(addr string) (Socket, error) {
+	switch {
+	case strings.HasPrefix(addr, "tcp://"):
+		return Socket{Network: "tcp", Address: strings.TrimPrefix(addr, "tcp://")}, nil
+	case strings.HasPrefix(addr, "unix://"):
+		return Socket{Network: "unix", Address: strings.TrimPrefix(addr, "unix://")}, nil
+	case strings.Contains(addr, "://"):
+		return Socket{}, fmt.Errorf("unsupported address %q (want tcp:// or unix://)", addr)
+	case strings.ContainsRune(addr, '/'):
+		return Socket{Network: "unix", Address: addr}, nil
+	case addr == "":
+		return Socket{}, fmt.Errorf("empty address")
+	}
+	return Socket{Network: "tcp", Address: addr}, nil
+}
+
+func (t Socket) String() string {
+	return t.Network + "://" + t.Address
+}
+
+func (t Socket) Open(ctx context.Context) (io.ReadWriteCloser, error) {
+	var d net.Dialer
+	return d.DialContext(ctx, t.Network, t.Address)
+}
+
+func (t Socket) Owned() bool { return false }
+func (t Socket) Kill() error { return nil }
+func (t Socket) Wait() error { return nil }
+
+// streamTransport hands out a stream that was established elsewhere
+type streamTransport struct {
+	rwc io.ReadWriteCloser
+}
+
+func (t streamTransport) Open(ctx context.Context) (io.ReadWriteCloser, error) {
+	return t.rwc, nil
+}
+
+func (t streamTransport) Owned() bool { return true }
+func (t streamTransport) Kill() error { return nil }
+func (t streamTransport) Wait() error { return nil }
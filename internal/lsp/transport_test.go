@@ -0,0 +1,115 @@
+package lsp_test
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"clangd-parser/internal/lsp"
+	"clangd-parser/internal/lsp/lsptest"
+)
+
+func TestParseSocket(t *testing.T) {
+	tests := []struct {
+		addr     string
+		expected lsp.Socket
+		wantErr  bool
+	}{
+		{"tcp://localhost:9257", lsp.Socket{Network: "tcp", Address: "localhost:9257"}, false},
+		{"localhost:9257", lsp.Socket{Network: "tcp", Address: "localhost:9257"}, false},
+		{"unix:///run/clangd.sock", lsp.Socket{Network: "unix", Address: "/run/clangd.sock"}, false},
+		{"/run/clangd.sock", lsp.Socket{Network: "unix", Address: "/run/clangd.sock"}, false},
+		{"http://localhost:9257", lsp.Socket{}, true},
+		{"", lsp.Socket{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := lsp.ParseSocket(tt.addr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSocket(%q): unexpected error %v", tt.addr, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("ParseSocket(%q) = %+v, expected %+v", tt.addr, got, tt.expected)
+		}
+	}
+}
+
+// listen serves every connection on a listener with the same server, the
+// way a socket proxy in front of one warm server does
+func listen(t *testing.T, network, address string, server *lsptest.Server) net.Listener {
+	t.Helper()
+
+	ln, err := net.Listen(network, address)
+	if err != nil {
+		t.Fatalf("Failed to listen on %s: %v", address, err)
+	}
+	t.Cleanup(func() {
+		ln.Close()
+		server.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			server.Serve(conn)
+		}
+	}()
+	return ln
+}
+
+func TestSocketTransport(t *testing.T) {
+	ctx := context.Background()
+	file := writeSource(t, "test.cpp", "int add(int a, int b) { return a + b; }\n")
+	script := lsptest.Script{
+		Symbols: map[string][]lsp.DocumentSymbol{
+			file: {{Name: "add", Kind: lsp.SymbolKindFunction}},
+		},
+	}
+
+	for _, network := range []string{"tcp", "unix"} {
+		t.Run(network, func(t *testing.T) {
+			address := "127.0.0.1:0"
+			if network == "unix" {
+				address = filepath.Join(t.TempDir(), "clangd.sock")
+			}
+			server := lsptest.NewServer(script)
+			ln := listen(t, network, address, server)
+			socket, err := lsp.ParseSocket(network + "://" + ln.Addr().String())
+			if err != nil {
+				t.Fatalf("ParseSocket failed: %v", err)
+			}
+
+			client, err := lsp.NewClientWithTransport(ctx, socket, lsp.ClangdSpec(""), t.TempDir())
+			if err != nil {
+				t.Fatalf("Failed to connect to %s: %v", socket, err)
+			}
+			symbols, err := client.GetDocumentSymbols(ctx, file)
+			if err != nil || len(symbols) != 1 {
+				t.Fatalf("Expected one symbol over %s, got %v (%v)", socket, symbols, err)
+			}
+			if err := client.Close(ctx); err != nil {
+				t.Errorf("Close failed: %v", err)
+			}
+
+			for _, method := range server.Received() {
+				if method == "shutdown" || method == "exit" {
+					t.Errorf("Expected the shared server to be left running, got %s", method)
+				}
+			}
+
+			// A server accepts one session, so a second connection needs a
+			// multiplexer in front of it
+			_, err = lsp.NewClientWithTransport(ctx, socket, lsp.ClangdSpec(""), t.TempDir())
+			if err == nil || !strings.Contains(err.Error(), "already initialized") {
+				t.Errorf("Expected the shared server to reject a second session, got %v", err)
+			}
+			t.Logf("✓ Session over %s", socket)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+
+	"clangd-parser/internal/fileuri"
+)
+
+// PrepareTypeHierarchy resolves the type at pos in an open document into
+// type hierarchy items
+func (c *Client) PrepareTypeHierarchy(ctx context.Context, filePath string, pos Position) ([]TypeHierarchyItem, error) {
+	if !c.init.Capabilities.TypeHierarchyProvider {
+		return nil, fmt.Errorf("prepareTypeHierarchy: %w", ErrNotSupported)
+	}
+
+	ctx, cancel := withTimeout(ctx, c.Timeouts.Request)
+	defer cancel()
+
+	params := map[string]any{
+		"textDocument": map[string]any{
+			"uri": fileuri.FromPath(filePath),
+		},
+		"position": pos,
+	}
+
+	var items []TypeHierarchyItem
+	if err := c.call(ctx, "textDocument/prepareTypeHierarchy", params, &items); err != nil {
+		return nil, fmt.Errorf("prepareTypeHierarchy: %w", err)
+	}
+
+	return items, nil
+}
+
+// Supertypes returns the direct base types of item
+func (c *Client) Supertypes(ctx context.Context, item TypeHierarchyItem) ([]TypeHierarchyItem, error) {
+	ctx, cancel := withTimeout(ctx, c.Timeouts.Request)
+	defer cancel()
+
+	var items []TypeHierarchyItem
+	if err := c.call(ctx, "typeHierarchy/supertypes", map[string]any{"item": item}, &items); err != nil {
+		return nil, fmt.Errorf("supertypes: %w", err)
+	}
+
+	return items, nil
+}
+
+// Subtypes returns the types directly derived from item
+func (c *Client) Subtypes(ctx context.Context, item TypeHierarchyItem) ([]TypeHierarchyItem, error) {
+	ctx, cancel := withTimeout(ctx, c.Timeouts.Request)
+	defer cancel()
+
+	var items []TypeHierarchyItem
+	if err := c.call(ctx, "typeHierarchy/subtypes", map[string]any{"item": item}, &items); err != nil {
+		return nil, fmt.Errorf("subtypes: %w", err)
+	}
+
+	return items, nil
+}
+
+// LegacyTypeHierarchy uses clangd's textDocument/typeHierarchy extension,
+// which predates the standard requests. Parents and children are resolved
+// resolve levels deep in the given direction.
+func (c *Client) LegacyTypeHierarchy(ctx context.Context, filePath string, pos Position, resolve, direction int) (*TypeHierarchyItem, error) {
+	ctx, cancel := withTimeout(ctx, c.Timeouts.Request)
+	defer cancel()
+
+	params := map[string]any{
+		"textDocument": map[string]any{
+			"uri": fileuri.FromPath(filePath),
+		},
+		"position":  pos,
+		"resolve":   resolve,
+		"direction": direction,
+	}
+
+	var item *TypeHierarchyItem
+	if err := c.call(ctx, "textDocument/typeHierarchy", params, &item); err != nil {
+		return nil, fmt.Errorf("typeHierarchy: %w", err)
+	}
+
+	return item, nil
+}
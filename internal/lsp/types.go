@@ -1,5 +1,10 @@
 package lsp
 
+import (
+	"encoding/json"
+	"strings"
+)
+
 // DocumentSymbol represents a programming construct like a function, class, or variable
 type DocumentSymbol struct {
 	Name           string           `json:"name"`
@@ -8,8 +13,120 @@ type DocumentSymbol struct {
 	Range          Range            `json:"range"`
 	SelectionRange Range            `json:"selectionRange"`
 	Children       []DocumentSymbol `json:"children,omitempty"`
+
+	// Flat is set on symbols nested from a flat SymbolInformation result
+	Flat bool `json:"-"`
+}
+
+// SymbolInformation is the flat documentSymbol result returned by servers
+// without hierarchical symbol support
+type SymbolInformation struct {
+	Name          string   `json:"name"`
+	Kind          int      `json:"kind"`
+	Location      Location `json:"location"`
+	ContainerName string   `json:"containerName,omitempty"`
+}
+
+// Location represents a range inside a resource
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+// Hover is the result of textDocument/hover
+type Hover struct {
+	Contents HoverContents `json:"contents"`
+	Range    *Range        `json:"range,omitempty"`
 }
 
+// HoverContents is the text of a hover. Servers may send MarkupContent, a
+// MarkedString or a list of MarkedStrings; all are flattened into Value.
+type HoverContents struct {
+	Kind  string `json:"kind"` // "markdown" or "plaintext"
+	Value string `json:"value"`
+}
+
+// UnmarshalJSON accepts every hover contents form allowed by the spec
+func (h *HoverContents) UnmarshalJSON(data []byte) error {
+	var markup struct {
+		Kind     string `json:"kind"`
+		Language string `json:"language"`
+		Value    string `json:"value"`
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		h.Kind, h.Value = "markdown", s
+		return nil
+	}
+
+	if err := json.Unmarshal(data, &markup); err == nil && markup.Value != "" {
+		h.Kind, h.Value = markup.Kind, markup.Value
+		if markup.Language != "" {
+			h.Kind = "markdown"
+			h.Value = "```" + markup.Language + "\n" + markup.Value + "\n```"
+		}
+		return nil
+	}
+
+	var list []HoverContents
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	var parts []string
+	for _, item := range list {
+		parts = append(parts, item.Value)
+	}
+	h.Kind, h.Value = "markdown", strings.Join(parts, "\n\n")
+	return nil
+}
+
+// CallHierarchyItem identifies a function in call hierarchy requests
+type CallHierarchyItem struct {
+	Name           string          `json:"name"`
+	Kind           int             `json:"kind"`
+	Detail         string          `json:"detail,omitempty"`
+	URI            string          `json:"uri"`
+	Range          Range           `json:"range"`
+	SelectionRange Range           `json:"selectionRange"`
+	Data           json.RawMessage `json:"data,omitempty"`
+}
+
+// CallHierarchyIncomingCall is a caller of a call hierarchy item
+type CallHierarchyIncomingCall struct {
+	From       CallHierarchyItem `json:"from"`
+	FromRanges []Range           `json:"fromRanges"`
+}
+
+// CallHierarchyOutgoingCall is a callee of a call hierarchy item
+type CallHierarchyOutgoingCall struct {
+	To         CallHierarchyItem `json:"to"`
+	FromRanges []Range           `json:"fromRanges"`
+}
+
+// TypeHierarchyItem identifies a type in type hierarchy requests
+type TypeHierarchyItem struct {
+	Name           string          `json:"name"`
+	Kind           int             `json:"kind"`
+	Detail         string          `json:"detail,omitempty"`
+	URI            string          `json:"uri"`
+	Range          Range           `json:"range"`
+	SelectionRange Range           `json:"selectionRange"`
+	Data           json.RawMessage `json:"data,omitempty"`
+
+	// Parents and Children are only set by clangd's legacy
+	// textDocument/typeHierarchy extension
+	Parents  []TypeHierarchyItem `json:"parents,omitempty"`
+	Children []TypeHierarchyItem `json:"children,omitempty"`
+}
+
+// Directions for clangd's legacy textDocument/typeHierarchy extension
+const (
+	TypeHierarchyChildren = 0
+	TypeHierarchyParents  = 1
+	TypeHierarchyBoth     = 2
+)
+
 // Range represents a range in a text document
 type Range struct {
 	Start Position `json:"start"`
@@ -22,6 +139,23 @@ type Position struct {
 	Character int `json:"character"`
 }
 
+// Diagnostic represents a compiler error, warning or note
+type Diagnostic struct {
+	Range    Range  `json:"range"`
+	Severity int    `json:"severity,omitempty"`
+	Code     any    `json:"code,omitempty"`
+	Source   string `json:"source,omitempty"`
+	Message  string `json:"message"`
+}
+
+// DiagnosticSeverity constants from LSP specification
+const (
+	DiagnosticSeverityError       = 1
+	DiagnosticSeverityWarning     = 2
+	DiagnosticSeverityInformation = 3
+	DiagnosticSeverityHint        = 4
+)
+
 // SymbolKind constants from LSP specification
 const (
 	SymbolKindFile          = 1
@@ -0,0 +1,59 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestHoverContentsUnmarshal(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"markup content", `{"kind":"markdown","value":"### function"}`, "### function"},
+		{"plain string", `"int x"`, "int x"},
+		{"marked string", `{"language":"cpp","value":"int x"}`, "```cpp\nint x\n```"},
+		{"list", `["first", {"language":"cpp","value":"int x"}]`, "first\n\n```cpp\nint x\n```"},
+	}
+
+	for _, tt := range tests {
+		var h HoverContents
+		if err := json.Unmarshal([]byte(tt.input), &h); err != nil {
+			t.Errorf("%s: unmarshal failed: %v", tt.name, err)
+			continue
+		}
+		if h.Value != tt.expected {
+			t.Errorf("%s: got %q, expected %q", tt.name, h.Value, tt.expected)
+		}
+	}
+}
+
+func TestLocationResultUnmarshal(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string // "uri:line:character"
+	}{
+		{"null", `null`, nil},
+		{"single location", `{"uri":"file:///a.h","range":{"start":{"line":3,"character":7},"end":{"line":3,"character":10}}}`, []string{"file:///a.h:3:7"}},
+		{"locations", `[{"uri":"file:///a.h","range":{"start":{"line":1,"character":2},"end":{"line":1,"character":2}}},{"uri":"file:///a.cpp","range":{"start":{"line":5,"character":0},"end":{"line":5,"character":0}}}]`, []string{"file:///a.h:1:2", "file:///a.cpp:5:0"}},
+		{"location links", `[{"targetUri":"file:///a.cpp","targetRange":{"start":{"line":4,"character":0},"end":{"line":8,"character":1}},"targetSelectionRange":{"start":{"line":4,"character":10},"end":{"line":4,"character":13}}}]`, []string{"file:///a.cpp:4:10"}},
+	}
+
+	for _, tt := range tests {
+		var r locationResult
+		if err := json.Unmarshal([]byte(tt.input), &r); err != nil {
+			t.Errorf("%s: unmarshal failed: %v", tt.name, err)
+			continue
+		}
+		var got []string
+		for _, loc := range r {
+			got = append(got, fmt.Sprintf("%s:%d:%d", loc.URI, loc.Range.Start.Line, loc.Range.Start.Character))
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.expected) {
+			t.Errorf("%s: got %v, expected %v", tt.name, got, tt.expected)
+		}
+	}
+}
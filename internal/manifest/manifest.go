@@ -0,0 +1,152 @@
+// Package manifest records the state of every indexed file so later runs
+// only re-parse what changed.
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"clangd-parser/internal/parser"
+)
+
+// Version is bumped when the manifest or chunk format changes in a way that
+// requires a full reindex
+const Version = 1
+
+// Manifest maps each indexed file to the state it was indexed in and the
+// chunks it produced
+type Manifest struct {
+	Version int `json:"version"`
+
+	// Config fingerprints the settings chunks depend on, such as the server
+	// and the enrichment passes. A different config invalidates every entry.
+	Config string `json:"config"`
+
+	Files map[string]Entry `json:"files"`
+}
+
+// Entry is the indexed state of one file
+type Entry struct {
+	State
+	ChunkIDs []string `json:"chunk_ids"`
+}
+
+// State is what a file and its compile command looked like
+type State struct {
+	ContentHash string `json:"content_hash"`
+	CommandHash string `json:"command_hash,omitempty"`
+}
+
+// New returns an empty manifest for config
+func New(config string) *Manifest {
+	return &Manifest{Version: Version, Config: config, Files: make(map[string]Entry)}
+}
+
+// Load reads a manifest. A missing file yields nil without an error.
+func Load(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parse manifest %s: %w", path, err)
+	}
+	return &m, nil
+}
+
+// Save writes the manifest to path
+func (m *Manifest) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create manifest directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal manifest: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	return nil
+}
+
+// Plan splits the files of a run by what needs to happen to them
+type Plan struct {
+	Parse   []string // new or changed files
+	Reuse   []string // unchanged files whose chunks are carried over
+	Deleted []string // indexed before but gone now
+}
+
+// Plan compares the current state of files with the manifest. Everything is
+// parsed if there is no manifest or it was written for another config.
+func (m *Manifest) Plan(config string, files []string, states map[string]State) Plan {
+	var p Plan
+	valid := m != nil && m.Version == Version && m.Config == config
+
+	current := make(map[string]bool, len(files))
+	for _, file := range files {
+		current[file] = true
+
+		entry, ok := Entry{}, false
+		if valid {
+			entry, ok = m.Files[file]
+		}
+		if ok && entry.State == states[file] {
+			p.Reuse = append(p.Reuse, file)
+		} else {
+			p.Parse = append(p.Parse, file)
+		}
+	}
+
+	if m != nil {
+		for file := range m.Files {
+			if !current[file] {
+				p.Deleted = append(p.Deleted, file)
+			}
+		}
+		sort.Strings(p.Deleted)
+	}
+
+	return p
+}
+
+// HashFile returns the hex SHA-256 of a file's content
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// HashCommand returns the hex SHA-256 of a compile command's directory and
+// arguments
+func HashCommand(cmd parser.CompileCommand) string {
+	return Fingerprint(append([]string{cmd.Directory}, cmd.Args()...)...)
+}
+
+// Fingerprint hashes a list of strings into a stable identifier
+func Fingerprint(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,77 @@
+package manifest
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"clangd-parser/internal/parser"
+)
+
+func TestPlan(t *testing.T) {
+	m := New("cfg")
+	m.Files["/src/same.cpp"] = Entry{State: State{ContentHash: "1"}, ChunkIDs: []string{"/src/same.cpp:1:6"}}
+	m.Files["/src/edited.cpp"] = Entry{State: State{ContentHash: "2"}}
+	m.Files["/src/flags.cpp"] = Entry{State: State{ContentHash: "3", CommandHash: "O1"}}
+	m.Files["/src/gone.cpp"] = Entry{State: State{ContentHash: "4"}}
+
+	files := []string{"/src/same.cpp", "/src/edited.cpp", "/src/flags.cpp", "/src/new.cpp"}
+	states := map[string]State{
+		"/src/same.cpp":   {ContentHash: "1"},
+		"/src/edited.cpp": {ContentHash: "2b"},
+		"/src/flags.cpp":  {ContentHash: "3", CommandHash: "O2"},
+		"/src/new.cpp":    {ContentHash: "5"},
+	}
+
+	p := m.Plan("cfg", files, states)
+	want := Plan{
+		Parse:   []string{"/src/edited.cpp", "/src/flags.cpp", "/src/new.cpp"},
+		Reuse:   []string{"/src/same.cpp"},
+		Deleted: []string{"/src/gone.cpp"},
+	}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("Plan = %+v\nexpected %+v", p, want)
+	}
+
+	if p := m.Plan("other", files, states); len(p.Parse) != len(files) || len(p.Reuse) != 0 {
+		t.Errorf("Expected a config change to parse everything, got %+v", p)
+	}
+
+	var missing *Manifest
+	if p := missing.Plan("cfg", files, states); len(p.Parse) != len(files) {
+		t.Errorf("Expected everything to be parsed without a manifest, got %+v", p)
+	}
+	t.Logf("✓ %d to parse, %d to reuse, %d deleted", len(want.Parse), len(want.Reuse), len(want.Deleted))
+}
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chunks.manifest.json")
+
+	if m, err := Load(path); m != nil || err != nil {
+		t.Fatalf("Expected no manifest and no error for a missing file, got %v, %v", m, err)
+	}
+
+	m := New("cfg")
+	m.Files["/src/a.cpp"] = Entry{
+		State:    State{ContentHash: "abc", CommandHash: HashCommand(parser.CompileCommand{Directory: "/b", Command: "c++ -c a.cpp"})},
+		ChunkIDs: []string{"/src/a.cpp:1:6"},
+	}
+	if err := m.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, m) {
+		t.Errorf("Loaded %+v, saved %+v", loaded, m)
+	}
+
+	// Arguments and the shell form hash the same
+	a := HashCommand(parser.CompileCommand{Directory: "/b", Command: "c++ -c a.cpp"})
+	b := HashCommand(parser.CompileCommand{Directory: "/b", Arguments: []string{"c++", "-c", "a.cpp"}})
+	if a != b {
+		t.Error("Expected equivalent commands to hash the same")
+	}
+}
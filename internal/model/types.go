@@ -1,7 +1,10 @@
 package model
 
+import "fmt"
+
 // SemanticChunk represents a semantic code chunk with NL views
 type SemanticChunk struct {
+	ID        string       `json:"id"`
 	Name      string       `json:"name"`
 	Signature string       `json:"signature"`
 	CodeType  string       `json:"code_type"`
@@ -11,6 +14,71 @@ type SemanticChunk struct {
 	LineTo    int          `json:"line_to"`
 	Context   ChunkContext `json:"context"`
 
+	// Doc is the documentation comment split into its Doxygen sections.
+	// Docstring keeps the whole comment as flat text.
+	Doc *Doc `json:"doc,omitempty"`
+
+	// QualifiedName is the fully qualified name, such as ns::Outer::method.
+	// Scopes are the namespaces, classes and other symbols enclosing the
+	// chunk, outermost first.
+	QualifiedName string  `json:"qualified_name,omitempty"`
+	Scopes        []Scope `json:"scopes,omitempty"`
+
+	// Position of the symbol's name (1-based), where LSP queries about the
+	// symbol are sent
+	NameLine   int `json:"name_line,omitempty"`
+	NameColumn int `json:"name_column,omitempty"`
+
+	// Exact extent of the symbol and of its name, independent of the
+	// position encoding the server used
+	Range     *SourceRange `json:"range,omitempty"`
+	NameRange *SourceRange `json:"name_range,omitempty"`
+
+	// Structured signature resolved from hover, if enrichment ran
+	SignatureInfo *SignatureInfo `json:"signature_info,omitempty"`
+
+	// Call graph edges for functions, methods and constructors
+	Calls    []ChunkRef `json:"calls,omitempty"`
+	CalledBy []ChunkRef `json:"called_by,omitempty"`
+
+	// Inheritance for classes, structs and interfaces; Overrides is set on
+	// methods that override a base class method
+	Bases     []ChunkRef `json:"bases,omitempty"`
+	Derived   []ChunkRef `json:"derived,omitempty"`
+	Overrides *ChunkRef  `json:"overrides,omitempty"`
+
+	// Declaration and Definition link a function declared in one place,
+	// usually a header, and defined in another. A merged chunk keeps the
+	// definition's body and position and points back at the declaration.
+	Declaration *ChunkRef `json:"declaration,omitempty"`
+	Definition  *ChunkRef `json:"definition,omitempty"`
+
+	// Chunks over the token budget are split into parts. A part links to its
+	// Parent, is numbered from 1 in Part and repeats the parent's declaration
+	// in Header. The parent lists its Parts and keeps only the header as its
+	// snippet.
+	Parent *ChunkRef  `json:"parent,omitempty"`
+	Part   int        `json:"part,omitempty"`
+	Header string     `json:"header,omitempty"`
+	Parts  []ChunkRef `json:"parts,omitempty"`
+
+	// A group chunk stands for tiny adjacent siblings, such as getters and
+	// setters, which are listed in Members instead of getting chunks of
+	// their own
+	Members []GroupMember `json:"members,omitempty"`
+
+	// Small symbols folded into this chunk instead of getting chunks of
+	// their own, such as the enumerators of an enum or the fields of a class
+	MemberSymbols []MemberSymbol `json:"member_symbols,omitempty"`
+
+	// How often the symbol is referenced, with a few sample usage sites
+	ReferenceCount int         `json:"reference_count,omitempty"`
+	Usages         []UsageSite `json:"usages,omitempty"`
+
+	// Compiler diagnostics; ParseQuality applies to the whole file
+	ParseQuality string            `json:"parse_quality,omitempty"`
+	Diagnostics  *ChunkDiagnostics `json:"diagnostics,omitempty"`
+
 	// NL-enhanced fields for vectorization
 	TextView    string   `json:"text_view"`    // Natural language representation (384 dims with all-MiniLM-L6-v2)
 	CodeView    string   `json:"code_view"`    // Code representation (768 dims with jina-embeddings-v2-base-code)
@@ -25,3 +93,143 @@ type ChunkContext struct {
 	StructName string `json:"struct_name,omitempty"`
 	Snippet    string `json:"snippet"`
 }
+
+// ChunkID returns the stable ID of the chunk whose name starts at the given
+// 1-based line and column of filePath. Language servers report the same name
+// position for a symbol in every cross-reference, so IDs can be derived from
+// call hierarchy or reference results without a lookup table.
+func ChunkID(filePath string, nameLine, nameColumn int) string {
+	return fmt.Sprintf("%s:%d:%d", filePath, nameLine, nameColumn)
+}
+
+// PartID returns the ID of the given part, numbered from 1, of the chunk
+// with parentID
+func PartID(parentID string, part int) string {
+	return fmt.Sprintf("%s#%d", parentID, part)
+}
+
+// GroupID returns the ID of the group chunk whose first member has memberID
+func GroupID(memberID string) string {
+	return memberID + "#group"
+}
+
+// SourceRange is an exact range in a file. Lines and columns are 1-based;
+// Column counts bytes and RuneColumn Unicode code points. Offsets are 0-based
+// byte offsets into the file, with EndOffset exclusive.
+type SourceRange struct {
+	StartLine       int `json:"start_line"`
+	StartColumn     int `json:"start_column"`
+	StartRuneColumn int `json:"start_rune_column"`
+	EndLine         int `json:"end_line"`
+	EndColumn       int `json:"end_column"`
+	EndRuneColumn   int `json:"end_rune_column"`
+	StartOffset     int `json:"start_offset"`
+	EndOffset       int `json:"end_offset"`
+}
+
+// ChunkRef points at another chunk, which may live in a different file
+type ChunkRef struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	FilePath string `json:"file_path"`
+	Line     int    `json:"line"`
+}
+
+// Doc is a Doxygen documentation comment. Params, TemplateParams and Throws
+// are keyed by parameter and exception type.
+type Doc struct {
+	Brief          string            `json:"brief,omitempty"`
+	Details        string            `json:"details,omitempty"`
+	Params         map[string]string `json:"params,omitempty"`
+	TemplateParams map[string]string `json:"template_params,omitempty"`
+	Returns        string            `json:"returns,omitempty"`
+	Throws         map[string]string `json:"throws,omitempty"`
+	Deprecated     bool              `json:"deprecated,omitempty"`
+	DeprecatedNote string            `json:"deprecated_note,omitempty"`
+	See            []string          `json:"see,omitempty"`
+}
+
+// GroupMember is a symbol merged into a group chunk, with the ID, positions
+// and enrichment it would have had as a chunk of its own
+type GroupMember struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	QualifiedName string `json:"qualified_name,omitempty"`
+	CodeType      string `json:"code_type"`
+	LineFrom      int    `json:"line_from"`
+	LineTo        int    `json:"line_to"`
+	NameLine      int    `json:"name_line,omitempty"`
+	NameColumn    int    `json:"name_column,omitempty"`
+
+	Doc           *Doc              `json:"doc,omitempty"`
+	SignatureInfo *SignatureInfo    `json:"signature_info,omitempty"`
+	MemberSymbols []MemberSymbol    `json:"member_symbols,omitempty"`
+	Diagnostics   *ChunkDiagnostics `json:"diagnostics,omitempty"`
+}
+
+// MemberSymbol is a small symbol listed in its parent chunk. Value is the
+// initializer, or the implied value of an enumerator without one.
+type MemberSymbol struct {
+	Name      string `json:"name"`
+	CodeType  string `json:"code_type"`
+	Signature string `json:"signature,omitempty"`
+	Value     string `json:"value,omitempty"`
+	Docstring string `json:"docstring,omitempty"`
+	Line      int    `json:"line"`
+}
+
+// Scope is a symbol enclosing a chunk. Kind is the code type of the scope,
+// such as Namespace or Class, and empty if it isn't known.
+type Scope struct {
+	Name string `json:"name"`
+	Kind string `json:"kind,omitempty"`
+}
+
+// UsageSite is a place where a symbol is referenced
+type UsageSite struct {
+	FilePath string `json:"file_path"`
+	Line     int    `json:"line"`
+	Snippet  string `json:"snippet"`
+}
+
+// SignatureInfo is a signature split into its parts
+type SignatureInfo struct {
+	ReturnType string   `json:"return_type,omitempty"`
+	Parameters []string `json:"parameters,omitempty"`
+	Scope      string   `json:"scope,omitempty"`
+	Definition string   `json:"definition,omitempty"`
+}
+
+// Parse quality of the file a chunk came from
+const (
+	ParseQualityClean    = "clean"    // no errors or warnings
+	ParseQualityWarnings = "warnings" // warnings only
+	ParseQualityErrors   = "errors"   // failed to compile, symbols may be wrong
+	ParseQualityUnknown  = "unknown"  // the server published no diagnostics in time
+)
+
+// ChunkDiagnostics summarizes the diagnostics that fall inside a chunk
+type ChunkDiagnostics struct {
+	Errors   int                 `json:"errors"`
+	Warnings int                 `json:"warnings"`
+	Messages []DiagnosticMessage `json:"messages,omitempty"`
+}
+
+// DiagnosticMessage is a single compiler error or warning
+type DiagnosticMessage struct {
+	Line     int    `json:"line"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// IndexInfo records how an index was produced so it can be reproduced
+type IndexInfo struct {
+	Server        string   `json:"server"`
+	ServerVersion string   `json:"server_version,omitempty"`
+	ServerArgs    []string `json:"server_args,omitempty"`
+	Enrichments   []string `json:"enrichments,omitempty"`
+	Kinds         string   `json:"kinds,omitempty"`
+	MinTokens     int      `json:"min_tokens,omitempty"`
+	MaxTokens     int      `json:"max_tokens,omitempty"`
+	Chunks        int      `json:"chunks"`
+}
@@ -47,6 +47,10 @@ func BuildViews(c model.SemanticChunk) Views {
 
 	textView := TokenizeForText(summary)
 	codeView := strings.TrimSpace(sigRaw + "\n" + ctx.Snippet)
+	if c.Header != "" {
+		// A part of a split chunk shows where it belongs
+		codeView = strings.TrimSpace(c.Header + "\n" + ctx.Snippet)
+	}
 
 	return Views{TextView: textView, CodeView: codeView, IdentTokens: identTokens}
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"clangd-parser/internal/model"
 )
@@ -50,6 +51,63 @@ func WriteJSONCompact(chunks []model.SemanticChunk, outputPath string) error {
 	return nil
 }
 
+// ReadJSON reads chunks written by WriteJSON or WriteJSONCompact
+func ReadJSON(path string) ([]model.SemanticChunk, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var chunks []model.SemanticChunk
+	if err := json.Unmarshal(data, &chunks); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return chunks, nil
+}
+
+// MetaPath returns the path of the index info written next to outputPath,
+// e.g. chunks.meta.json for chunks.json
+func MetaPath(outputPath string) string {
+	return strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + ".meta.json"
+}
+
+// ManifestPath returns the path of the incremental index manifest written
+// next to outputPath, e.g. chunks.manifest.json for chunks.json
+func ManifestPath(outputPath string) string {
+	return strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + ".manifest.json"
+}
+
+// ReadIndexInfo reads index info written by WriteIndexInfo
+func ReadIndexInfo(path string) (model.IndexInfo, error) {
+	var info model.IndexInfo
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return info, err
+	}
+	if err := json.Unmarshal(data, &info); err != nil {
+		return info, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return info, nil
+}
+
+// WriteIndexInfo writes the description of how an index was produced
+func WriteIndexInfo(info model.IndexInfo, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create output directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal JSON: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("write file: %w", err)
+	}
+
+	return nil
+}
+
 // GetOutputStats returns statistics about the output
 func GetOutputStats(chunks []model.SemanticChunk) map[string]any {
 	stats := make(map[string]any)
@@ -72,5 +130,14 @@ func GetOutputStats(chunks []model.SemanticChunk) map[string]any {
 	}
 	stats["with_docstring"] = withDocs
 
+	// Count chunks from files that failed to compile
+	withErrors := 0
+	for _, chunk := range chunks {
+		if chunk.ParseQuality == model.ParseQualityErrors {
+			withErrors++
+		}
+	}
+	stats["with_errors"] = withErrors
+
 	return stats
 }
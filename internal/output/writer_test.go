@@ -144,7 +144,7 @@ func TestWriteJSONCompact(t *testing.T) {
 func TestGetOutputStats(t *testing.T) {
 	chunks := []model.SemanticChunk{
 		{Name: "func1", CodeType: "Function", Docstring: "Has docs"},
-		{Name: "func2", CodeType: "Function", Docstring: ""},
+		{Name: "func2", CodeType: "Function", Docstring: "", ParseQuality: model.ParseQualityErrors},
 		{Name: "class1", CodeType: "Class", Docstring: "Has docs"},
 		{Name: "method1", CodeType: "Method", Docstring: ""},
 	}
@@ -173,6 +173,10 @@ func TestGetOutputStats(t *testing.T) {
 		t.Errorf("Expected 2 chunks with docstrings, got %v", stats["with_docstring"])
 	}
 
+	if stats["with_errors"].(int) != 1 {
+		t.Errorf("Expected 1 chunk with errors, got %v", stats["with_errors"])
+	}
+
 	t.Logf("✓ Stats: %v", stats)
 }
 
@@ -199,3 +203,29 @@ func TestWriteJSONEmptyChunks(t *testing.T) {
 
 	t.Log("✓ Empty chunks handled correctly")
 }
+
+func TestWriteIndexInfo(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "output-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path := MetaPath(filepath.Join(tmpDir, "out", "chunks.json"))
+	if filepath.Base(path) != "chunks.meta.json" {
+		t.Errorf("Expected chunks.meta.json, got %s", path)
+	}
+
+	info := model.IndexInfo{Server: "clangd", ServerVersion: "clangd version 17.0.6", Chunks: 3}
+	if err := WriteIndexInfo(info, path); err != nil {
+		t.Fatalf("WriteIndexInfo failed: %v", err)
+	}
+
+	decoded, err := ReadIndexInfo(path)
+	if err != nil {
+		t.Fatalf("ReadIndexInfo failed: %v", err)
+	}
+	if decoded.ServerVersion != info.ServerVersion || decoded.Chunks != 3 {
+		t.Errorf("Unexpected index info: %+v", decoded)
+	}
+}
@@ -10,29 +10,67 @@ import (
 	"clangd-parser/internal/model"
 )
 
-// ConvertSymbolsToChunks converts LSP symbols to semantic chunks
-func ConvertSymbolsToChunks(symbols []lsp.DocumentSymbol, filePath string) []model.SemanticChunk {
-	fileLines := readFileLines(filePath)
+// Options controls how the symbols of a file become chunks
+type Options struct {
+	Kinds Kinds     // what becomes of small symbols
+	Size  ChunkSize // grouping and splitting bounds
+}
+
+// ConvertSymbolsToChunks converts LSP symbols to semantic chunks. encoding is
+// the position encoding negotiated with the server that reported symbols;
+// kinds selects what becomes of small symbols such as fields and
+// enumerators, nil meaning DefaultKinds.
+func ConvertSymbolsToChunks(symbols []lsp.DocumentSymbol, filePath string, encoding lsp.PositionEncoding, kinds Kinds) []model.SemanticChunk {
+	content, _ := os.ReadFile(filePath)
+	text := lsp.NewText(content, encoding)
+	fileLines := text.Lines()
 	var chunks []model.SemanticChunk
 
 	for _, symbol := range symbols {
-		processSymbol(symbol, filePath, fileLines, "", &chunks)
+		processSymbol(symbol, filePath, text, fileLines, kinds, "", nil, -1, &chunks)
 	}
 
 	return chunks
 }
 
-func processSymbol(symbol lsp.DocumentSymbol, filePath string, fileLines []string, parentStruct string, chunks *[]model.SemanticChunk) {
-	// Extract this symbol if it's a relevant type
-	if shouldExtractSymbol(symbol.Kind) {
+// processSymbol adds the chunk for a symbol and its children. scopes are the
+// chunks enclosing it and parent is the index of the innermost one, or -1 at
+// the top level.
+func processSymbol(symbol lsp.DocumentSymbol, filePath string, text *lsp.Text, fileLines []string, kinds Kinds, parentStruct string, scopes []model.Scope, parent int, chunks *[]model.SemanticChunk) {
+	codeType := symbolCodeType(symbol, fileLines)
+	mode := symbolMode(symbol, codeType, kinds)
+	if mode == KindMember && parent < 0 {
+		mode = KindChunk // nothing to fold it into
+	}
+
+	switch mode {
+	case KindMember:
+		p := &(*chunks)[parent]
+		p.MemberSymbols = append(p.MemberSymbols, memberSymbol(symbol, codeType, text, fileLines, p.MemberSymbols))
+
+	case KindChunk:
+		doc := docComment(symbol, fileLines)
+		var name string
+		scopes, name = qualify(symbol.Name, codeType, scopes)
+		if parentStruct == "" && len(scopes) > 0 && scopes[len(scopes)-1].Kind == "Class" {
+			parentStruct = scopes[len(scopes)-1].Name // out-of-line method
+		}
+
+		nameColumn := byteColumn(symbol.SelectionRange.Start, text) + 1
 		chunk := model.SemanticChunk{
-			Name:      symbol.Name,
-			Signature: getSignature(symbol),
-			CodeType:  symbolKindToString(symbol.Kind),
-			Docstring: extractDocstring(symbol, fileLines),
-			Line:      symbol.Range.Start.Line + 1, // LSP is 0-indexed
-			LineFrom:  symbol.Range.Start.Line + 1,
-			LineTo:    symbol.Range.End.Line + 1,
+			ID:            model.ChunkID(filePath, symbol.SelectionRange.Start.Line+1, nameColumn),
+			Name:          symbol.Name,
+			Signature:     getSignature(symbol, fileLines),
+			CodeType:      codeType,
+			Docstring:     flattenDoc(doc),
+			Doc:           ParseDoxygen(doc),
+			QualifiedName: qualifiedName(scopes, name),
+			Scopes:        scopes,
+			Line:          symbol.Range.Start.Line + 1, // LSP is 0-indexed
+			LineFrom:      symbol.Range.Start.Line + 1,
+			LineTo:        symbol.Range.End.Line + 1,
+			NameLine:      symbol.SelectionRange.Start.Line + 1,
+			NameColumn:    nameColumn,
 			Context: model.ChunkContext{
 				Module:     extractModule(filePath),
 				FilePath:   filePath,
@@ -42,7 +80,14 @@ func processSymbol(symbol lsp.DocumentSymbol, filePath string, fileLines []strin
 			},
 		}
 
+		if len(fileLines) > 0 {
+			chunk.Range = sourceRange(symbol.Range, text)
+			chunk.NameRange = sourceRange(symbol.SelectionRange, text)
+		}
+
 		*chunks = append(*chunks, chunk)
+		parent = len(*chunks) - 1
+		scopes = append(scopes[:len(scopes):len(scopes)], model.Scope{Name: name, Kind: codeType})
 
 		// Update parent for children if this is a class/struct
 		if symbol.Kind == lsp.SymbolKindClass || symbol.Kind == lsp.SymbolKindStruct {
@@ -52,7 +97,31 @@ func processSymbol(symbol lsp.DocumentSymbol, filePath string, fileLines []strin
 
 	// Process children recursively
 	for _, child := range symbol.Children {
-		processSymbol(child, filePath, fileLines, parentStruct, chunks)
+		processSymbol(child, filePath, text, fileLines, kinds, parentStruct, scopes, parent, chunks)
+	}
+}
+
+// byteColumn returns the 0-based byte column of pos, so that chunk IDs don't
+// depend on the server's position encoding. Positions past the end of the
+// file, which can't be converted, keep their character offset.
+func byteColumn(pos lsp.Position, text *lsp.Text) int {
+	if pos.Line >= text.LineCount() {
+		return pos.Character
+	}
+	return text.Column(pos)
+}
+
+// sourceRange converts an LSP range to byte and rune columns
+func sourceRange(rng lsp.Range, text *lsp.Text) *model.SourceRange {
+	return &model.SourceRange{
+		StartLine:       rng.Start.Line + 1,
+		StartColumn:     text.Column(rng.Start) + 1,
+		StartRuneColumn: text.RuneColumn(rng.Start) + 1,
+		EndLine:         rng.End.Line + 1,
+		EndColumn:       text.Column(rng.End) + 1,
+		EndRuneColumn:   text.RuneColumn(rng.End) + 1,
+		StartOffset:     text.Offset(rng.Start),
+		EndOffset:       text.Offset(rng.End),
 	}
 }
 
@@ -69,14 +138,20 @@ func shouldExtractSymbol(kind int) bool {
 
 func symbolKindToString(kind int) string {
 	kinds := map[int]string{
-		lsp.SymbolKindFunction:    "Function",
-		lsp.SymbolKindMethod:      "Method",
-		lsp.SymbolKindClass:       "Class",
-		lsp.SymbolKindStruct:      "Struct",
-		lsp.SymbolKindConstructor: "Constructor",
-		lsp.SymbolKindEnum:        "Enum",
-		lsp.SymbolKindInterface:   "Interface",
-		lsp.SymbolKindNamespace:   "Namespace",
+		lsp.SymbolKindFunction:      "Function",
+		lsp.SymbolKindMethod:        "Method",
+		lsp.SymbolKindClass:         "Class",
+		lsp.SymbolKindStruct:        "Struct",
+		lsp.SymbolKindConstructor:   "Constructor",
+		lsp.SymbolKindEnum:          "Enum",
+		lsp.SymbolKindInterface:     "Interface",
+		lsp.SymbolKindNamespace:     "Namespace",
+		lsp.SymbolKindField:         "Field",
+		lsp.SymbolKindEnumMember:    "EnumMember",
+		lsp.SymbolKindVariable:      "Variable",
+		lsp.SymbolKindConstant:      "Constant",
+		lsp.SymbolKindProperty:      "Property",
+		lsp.SymbolKindTypeParameter: "TypeParameter",
 	}
 
 	if name, ok := kinds[kind]; ok {
@@ -85,38 +160,41 @@ func symbolKindToString(kind int) string {
 	return "Unknown"
 }
 
-func getSignature(symbol lsp.DocumentSymbol) string {
+func getSignature(symbol lsp.DocumentSymbol, fileLines []string) string {
 	if symbol.Detail != "" {
 		return symbol.Detail
 	}
+	if isFlatSymbol(symbol) {
+		if decl := extractDeclaration(symbol.Range, fileLines); decl != "" {
+			return decl
+		}
+	}
 	return symbol.Name
 }
 
-func extractDocstring(symbol lsp.DocumentSymbol, fileLines []string) string {
-	startLine := symbol.Range.Start.Line
-	if startLine == 0 || startLine > len(fileLines) {
-		return ""
-	}
+// isFlatSymbol reports whether the symbol was nested from a flat
+// SymbolInformation result. Those come without a detail to take the
+// signature from.
+func isFlatSymbol(symbol lsp.DocumentSymbol) bool {
+	return symbol.Flat
+}
 
-	var docLines []string
-	for i := startLine - 1; i >= 0 && i < len(fileLines); i-- {
-		line := strings.TrimSpace(fileLines[i])
+// extractDeclaration returns the source text of a symbol up to its body or
+// terminating semicolon, joined onto one line
+func extractDeclaration(rng lsp.Range, fileLines []string) string {
+	const maxLines = 5
 
-		// C++ documentation comments
-		if strings.HasPrefix(line, "///") {
-			doc := strings.TrimSpace(strings.TrimPrefix(line, "///"))
-			docLines = append([]string{doc}, docLines...)
-		} else if strings.HasPrefix(line, "//!") {
-			doc := strings.TrimSpace(strings.TrimPrefix(line, "//!"))
-			docLines = append([]string{doc}, docLines...)
-		} else if line == "" {
-			continue // Skip empty lines
-		} else {
-			break // Stop at non-comment line
+	var parts []string
+	for i := rng.Start.Line; i >= 0 && i < len(fileLines) && i <= rng.End.Line && len(parts) < maxLines; i++ {
+		line := strings.TrimSpace(fileLines[i])
+		if idx := strings.IndexAny(line, "{;"); idx >= 0 {
+			parts = append(parts, strings.TrimSpace(line[:idx]))
+			break
 		}
+		parts = append(parts, line)
 	}
 
-	return strings.Join(docLines, " ")
+	return strings.TrimSpace(strings.Join(parts, " "))
 }
 
 func extractSnippet(rng lsp.Range, fileLines []string) string {
@@ -140,7 +218,8 @@ func extractModule(filePath string) string {
 	return ""
 }
 
-func readFileLines(filePath string) []string {
+// ReadFileLines returns the lines of a file, or none if it can't be read
+func ReadFileLines(filePath string) []string {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return []string{}
@@ -81,7 +81,7 @@ public:
 	}
 
 	// Convert to chunks
-	chunks := ConvertSymbolsToChunks(symbols, testFile)
+	chunks := ConvertSymbolsToChunks(symbols, testFile, lsp.PositionEncodingUTF16, nil)
 
 	// Verify results
 	if len(chunks) != 4 {
@@ -93,6 +93,10 @@ public:
 		t.Errorf("Expected first chunk name 'testFunction', got '%s'", chunks[0].Name)
 	}
 
+	if chunks[0].ID != model.ChunkID(testFile, 1, 1) {
+		t.Errorf("Expected ID derived from the name position, got '%s'", chunks[0].ID)
+	}
+
 	if chunks[0].CodeType != "Function" {
 		t.Errorf("Expected code type 'Function', got '%s'", chunks[0].CodeType)
 	}
@@ -124,6 +128,62 @@ public:
 	t.Logf("✓ Successfully converted %d symbols to chunks", len(chunks))
 }
 
+func TestConvertSymbolsToChunksRanges(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "ranges.cpp")
+	// "😀" is 4 bytes and 2 UTF-16 code units, "é" 2 bytes and 1 unit
+	testCode := "/* 😀 */ int café(int x) { return x; }\n"
+	if err := os.WriteFile(testFile, []byte(testCode), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	// Positions as a UTF-16 server reports them
+	symbols := []lsp.DocumentSymbol{{
+		Name:   "café",
+		Detail: "int (int)",
+		Kind:   lsp.SymbolKindFunction,
+		Range: lsp.Range{
+			Start: lsp.Position{Line: 0, Character: 9},
+			End:   lsp.Position{Line: 0, Character: 38},
+		},
+		SelectionRange: lsp.Range{
+			Start: lsp.Position{Line: 0, Character: 13},
+			End:   lsp.Position{Line: 0, Character: 17},
+		},
+	}}
+
+	chunks := ConvertSymbolsToChunks(symbols, testFile, lsp.PositionEncodingUTF16, nil)
+	if len(chunks) != 1 || chunks[0].NameRange == nil || chunks[0].Range == nil {
+		t.Fatalf("Expected one chunk with ranges, got %+v", chunks)
+	}
+
+	name := chunks[0].NameRange
+	if got := testCode[name.StartOffset:name.EndOffset]; got != "café" {
+		t.Errorf("Expected the name range to cover 'café', got %q", got)
+	}
+	if name.StartColumn != 16 || name.StartRuneColumn != 13 || name.EndColumn != 21 {
+		t.Errorf("Unexpected name columns: %+v", name)
+	}
+
+	rng := chunks[0].Range
+	if got := testCode[rng.StartOffset:rng.EndOffset]; got != "int café(int x) { return x; }" {
+		t.Errorf("Expected the range to cover the definition, got %q", got)
+	}
+
+	// IDs count bytes, whatever the server's encoding
+	if c := chunks[0]; c.NameColumn != 16 || c.ID != model.ChunkID(testFile, 1, 16) {
+		t.Errorf("Expected the name at byte column 16, got %d in %q", c.NameColumn, c.ID)
+	}
+	utf32 := symbols[0]
+	utf32.Range = lsp.Range{Start: lsp.Position{Character: 8}, End: lsp.Position{Character: 37}}
+	utf32.SelectionRange = lsp.Range{Start: lsp.Position{Character: 12}, End: lsp.Position{Character: 16}}
+	other := ConvertSymbolsToChunks([]lsp.DocumentSymbol{utf32}, testFile, lsp.PositionEncodingUTF32, nil)
+	if len(other) != 1 || other[0].ID != chunks[0].ID {
+		t.Errorf("Expected the same ID from a UTF-32 server, got %+v", other)
+	}
+
+	t.Logf("✓ Name at byte column %d, rune column %d", name.StartColumn, name.StartRuneColumn)
+}
+
 func TestSymbolKindToString(t *testing.T) {
 	tests := []struct {
 		kind     int
@@ -150,7 +210,7 @@ func TestSymbolKindToString(t *testing.T) {
 	t.Log("✓ Symbol kind conversion tests passed")
 }
 
-func TestExtractDocstring(t *testing.T) {
+func TestFlattenDocComment(t *testing.T) {
 	fileLines := []string{
 		"// Regular comment",
 		"/// This is documentation",
@@ -165,17 +225,43 @@ func TestExtractDocstring(t *testing.T) {
 		},
 	}
 
-	docstring := extractDocstring(symbol, fileLines)
+	// The regular comment above the documentation is left out
+	docstring := flattenDoc(docComment(symbol, fileLines))
+	if docstring != "This is documentation on multiple lines" {
+		t.Errorf("Expected the documentation lines joined, got '%s'", docstring)
+	}
+
+	t.Logf("✓ Extracted docstring: %s", docstring)
+}
 
-	if !containsString(docstring, "This is documentation") {
-		t.Errorf("Expected docstring to contain 'This is documentation', got '%s'", docstring)
+func TestGetSignatureFlatSymbol(t *testing.T) {
+	fileLines := []string{
+		"class Widget",
+		"    : public Base {",
+		"  int x;",
+		"};",
 	}
 
-	if !containsString(docstring, "multiple lines") {
-		t.Errorf("Expected docstring to contain 'multiple lines', got '%s'", docstring)
+	rng := lsp.Range{
+		Start: lsp.Position{Line: 0, Character: 0},
+		End:   lsp.Position{Line: 3, Character: 2},
 	}
 
-	t.Logf("✓ Extracted docstring: %s", docstring)
+	// Flat SymbolInformation results have no detail
+	flat := lsp.DocumentSymbol{Name: "Widget", Kind: lsp.SymbolKindClass, Range: rng, SelectionRange: rng, Flat: true}
+	if sig := getSignature(flat, fileLines); sig != "class Widget : public Base" {
+		t.Errorf("Expected declaration from source, got %q", sig)
+	}
+
+	hierarchical := lsp.DocumentSymbol{
+		Name:           "Widget",
+		Kind:           lsp.SymbolKindClass,
+		Range:          rng,
+		SelectionRange: lsp.Range{Start: lsp.Position{Line: 0, Character: 6}, End: lsp.Position{Line: 0, Character: 12}},
+	}
+	if sig := getSignature(hierarchical, fileLines); sig != "Widget" {
+		t.Errorf("Expected name fallback for hierarchical symbol, got %q", sig)
+	}
 }
 
 func TestShouldExtractSymbol(t *testing.T) {
@@ -0,0 +1,252 @@
+package parser
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"clangd-parser/internal/fileuri"
+)
+
+// CompileCommand is an entry of compile_commands.json
+type CompileCommand struct {
+	Directory string   `json:"directory"`
+	File      string   `json:"file"`
+	Command   string   `json:"command,omitempty"`
+	Arguments []string `json:"arguments,omitempty"`
+	Output    string   `json:"output,omitempty"`
+}
+
+// LoadCompileCommands reads a compilation database. path may be the
+// compile_commands.json file or the directory containing it.
+func LoadCompileCommands(path string) ([]CompileCommand, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, "compile_commands.json")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var commands []CompileCommand
+	if err := json.Unmarshal(data, &commands); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return commands, nil
+}
+
+// Path returns the normalized absolute path of the compiled file
+func (c CompileCommand) Path() string {
+	return c.resolve(c.File)
+}
+
+// Args returns the compiler arguments, splitting Command if the entry only
+// has the shell form
+func (c CompileCommand) Args() []string {
+	if len(c.Arguments) > 0 {
+		return c.Arguments
+	}
+	return splitCommand(c.Command)
+}
+
+// includeDirs returns the directories searched for quoted and for angled
+// includes, in search order
+func (c CompileCommand) includeDirs() (quoted, angled []string) {
+	var iquote, dirs, system []string
+
+	args := c.Args()
+	for i := 0; i < len(args); i++ {
+		for _, opt := range []struct {
+			flag string
+			dst  *[]string
+		}{{"-iquote", &iquote}, {"-isystem", &system}, {"-I", &dirs}} {
+			if !strings.HasPrefix(args[i], opt.flag) {
+				continue
+			}
+			dir := strings.TrimPrefix(args[i], opt.flag)
+			if dir == "" && i+1 < len(args) {
+				i++
+				dir = args[i]
+			}
+			if dir != "" {
+				*opt.dst = append(*opt.dst, c.resolve(dir))
+			}
+			break
+		}
+	}
+
+	angled = append(dirs, system...)
+	quoted = append(iquote, angled...)
+	return quoted, angled
+}
+
+// resolve makes path absolute relative to the entry's directory
+func (c CompileCommand) resolve(path string) string {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(c.Directory, path)
+	}
+	return fileuri.Normalize(path)
+}
+
+// splitCommand splits a shell command line into arguments, honoring quotes
+// and backslash escapes
+func splitCommand(command string) []string {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+
+	for i := 0; i < len(command); i++ {
+		ch := rune(command[i])
+		switch {
+		case ch == '\\' && quote != '\'' && i+1 < len(command):
+			i++
+			current.WriteByte(command[i])
+			inArg = true
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			} else {
+				current.WriteRune(ch)
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+			inArg = true
+		case ch == ' ' || ch == '\t' || ch == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(ch)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args
+}
+
+// Discovery is the outcome of compile database driven file discovery
+type Discovery struct {
+	// Files holds the translation units in database order followed by the
+	// project headers they include
+	Files []string
+
+	// Units is the number of translation units at the start of Files
+	Units int
+
+	// NoCommand lists discovered files without a compile command of their
+	// own. The server infers their flags from a file that includes them.
+	NoCommand []string
+
+	// Missing lists database entries whose file doesn't exist
+	Missing []string
+}
+
+// includePattern matches #include "file" and #include <file>
+var includePattern = regexp.MustCompile(`^\s*#\s*include\s*([<"])([^>"]+)[>"]`)
+
+// FindCompileDBFiles returns the translation units of the compilation
+// database at dbPath together with the headers they include, directly or
+// through other headers. Headers are only followed inside rootPath, which
+// keeps system and third party headers out.
+func FindCompileDBFiles(dbPath, rootPath string) (*Discovery, error) {
+	commands, err := LoadCompileCommands(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	root := fileuri.Normalize(rootPath)
+	d := &Discovery{}
+	seen := make(map[string]bool)
+
+	// Headers inherit the include paths of the first unit including them
+	type pending struct {
+		path    string
+		command CompileCommand
+	}
+	var queue []pending
+
+	for _, cmd := range commands {
+		path := cmd.Path()
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		if _, err := os.Stat(path); err != nil {
+			d.Missing = append(d.Missing, path)
+			continue
+		}
+		d.Files = append(d.Files, path)
+		queue = append(queue, pending{path, cmd})
+	}
+	d.Units = len(d.Files)
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, header := range resolveIncludes(current.path, current.command) {
+			if seen[header] || !isWithin(header, root) {
+				continue
+			}
+			seen[header] = true
+			d.Files = append(d.Files, header)
+			d.NoCommand = append(d.NoCommand, header)
+			queue = append(queue, pending{header, current.command})
+		}
+	}
+
+	return d, nil
+}
+
+// resolveIncludes returns the existing files that path includes, resolved
+// with the include directories of cmd. Includes that aren't found, such as
+// standard library headers, are skipped.
+func resolveIncludes(path string, cmd CompileCommand) []string {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	quoted, angled := cmd.includeDirs()
+	quoted = append([]string{filepath.Dir(path)}, quoted...)
+
+	var includes []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		m := includePattern.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+
+		dirs := angled
+		if m[1] == `"` {
+			dirs = quoted
+		}
+		for _, dir := range dirs {
+			candidate := filepath.Join(dir, m[2])
+			if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+				includes = append(includes, fileuri.Normalize(candidate))
+				break
+			}
+		}
+	}
+	return includes
+}
+
+// isWithin reports whether path is root or below it
+func isWithin(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
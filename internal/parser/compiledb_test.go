@@ -0,0 +1,96 @@
+package parser
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		command  string
+		expected []string
+	}{
+		{"c++ -c a.cpp", []string{"c++", "-c", "a.cpp"}},
+		{`c++ -DNAME="a b" -I'dir with space' a.cpp`, []string{"c++", "-DNAME=a b", "-Idir with space", "a.cpp"}},
+		{`c++ -DQ=\"x\"  a.cpp`, []string{"c++", `-DQ="x"`, "a.cpp"}},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		if got := splitCommand(tt.command); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("splitCommand(%q) = %q, expected %q", tt.command, got, tt.expected)
+		}
+	}
+}
+
+func TestFindCompileDBFiles(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "project")
+	outside := filepath.Join(base, "third_party")
+
+	files := map[string]string{
+		"project/src/a.cpp":             "#include \"a.h\"\n#include <util/u.h>\n#include <vector>\n#include <ext.h>\n",
+		"project/src/a.h":               "#pragma once\n",
+		"project/include/util/u.h":      "#include \"detail.h\"\n",
+		"project/include/util/detail.h": "#include \"u.h\"\n",
+		"project/build/gen/gen.cpp":     "  #  include \"gen.h\"\n",
+		"project/build/gen/gen.h":       "",
+		"project/scratch/unused.cpp":    "#include \"../src/a.h\"\n",
+		"third_party/ext.h":             "",
+	}
+	for name, content := range files {
+		path := filepath.Join(base, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	buildDir := filepath.Join(root, "build")
+	commands := []CompileCommand{
+		{Directory: buildDir, File: "../src/a.cpp", Arguments: []string{"c++", "-I", "../include", "-isystem" + outside, "-c", "../src/a.cpp"}},
+		{Directory: buildDir, File: "gen/gen.cpp", Command: "c++ -c gen/gen.cpp"},
+		{Directory: buildDir, File: "../src/a.cpp", Command: "c++ -DDEBUG -c ../src/a.cpp"},
+		{Directory: buildDir, File: "../src/deleted.cpp", Command: "c++ -c ../src/deleted.cpp"},
+	}
+	data, _ := json.Marshal(commands)
+	if err := os.WriteFile(filepath.Join(buildDir, "compile_commands.json"), data, 0644); err != nil {
+		t.Fatalf("Failed to write compile database: %v", err)
+	}
+
+	d, err := FindCompileDBFiles(buildDir, root)
+	if err != nil {
+		t.Fatalf("FindCompileDBFiles failed: %v", err)
+	}
+
+	in := func(names ...string) []string {
+		var paths []string
+		for _, name := range names {
+			paths = append(paths, filepath.Join(root, name))
+		}
+		return paths
+	}
+
+	// Units in database order, then headers breadth first; the unused file,
+	// the third party header and <vector> are left out
+	wantFiles := in("src/a.cpp", "build/gen/gen.cpp", "src/a.h", "include/util/u.h", "build/gen/gen.h", "include/util/detail.h")
+	if !reflect.DeepEqual(d.Files, wantFiles) {
+		t.Errorf("Files = %q\nexpected %q", d.Files, wantFiles)
+	}
+	if d.Units != 2 {
+		t.Errorf("Expected 2 translation units, got %d", d.Units)
+	}
+	if !reflect.DeepEqual(d.NoCommand, wantFiles[2:]) {
+		t.Errorf("NoCommand = %q, expected the headers", d.NoCommand)
+	}
+	if !reflect.DeepEqual(d.Missing, in("src/deleted.cpp")) {
+		t.Errorf("Missing = %q", d.Missing)
+	}
+
+	t.Logf("✓ Discovered %d units and %d headers", d.Units, len(d.NoCommand))
+}
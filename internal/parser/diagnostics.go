@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"clangd-parser/internal/lsp"
+	"clangd-parser/internal/model"
+)
+
+// maxChunkDiagnostics caps the messages kept per chunk
+const maxChunkDiagnostics = 10
+
+// AttachDiagnostics adds the errors and warnings that fall inside each chunk's
+// line range, and each group member's, and marks every chunk with the parse
+// quality of its file. published is false if the server never published
+// diagnostics for the file.
+func AttachDiagnostics(chunks []model.SemanticChunk, diags []lsp.Diagnostic, published bool) {
+	quality := ParseQuality(diags, published)
+
+	for i := range chunks {
+		chunk := &chunks[i]
+		chunk.ParseQuality = quality
+		if cd := diagnosticsIn(diags, chunk.LineFrom, chunk.LineTo); cd != nil {
+			chunk.Diagnostics = cd
+		}
+		for j := range chunk.Members {
+			m := &chunk.Members[j]
+			if cd := diagnosticsIn(diags, m.LineFrom, m.LineTo); cd != nil {
+				m.Diagnostics = cd
+			}
+		}
+	}
+}
+
+// diagnosticsIn summarizes the errors and warnings between two 1-based
+// lines, or returns nil if there are none
+func diagnosticsIn(diags []lsp.Diagnostic, from, to int) *model.ChunkDiagnostics {
+	var cd model.ChunkDiagnostics
+	for _, d := range diags {
+		line := d.Range.Start.Line + 1 // LSP is 0-indexed
+		if line < from || line > to {
+			continue
+		}
+
+		switch d.Severity {
+		case lsp.DiagnosticSeverityError:
+			cd.Errors++
+		case lsp.DiagnosticSeverityWarning:
+			cd.Warnings++
+		default:
+			continue
+		}
+
+		if len(cd.Messages) < maxChunkDiagnostics {
+			cd.Messages = append(cd.Messages, model.DiagnosticMessage{
+				Line:     line,
+				Severity: severityToString(d.Severity),
+				Message:  d.Message,
+			})
+		}
+	}
+
+	if cd.Errors == 0 && cd.Warnings == 0 {
+		return nil
+	}
+	return &cd
+}
+
+// ParseQuality classifies a file by its worst diagnostic, or as unknown if
+// the server published none
+func ParseQuality(diags []lsp.Diagnostic, published bool) string {
+	if !published {
+		return model.ParseQualityUnknown
+	}
+	quality := model.ParseQualityClean
+	for _, d := range diags {
+		switch d.Severity {
+		case lsp.DiagnosticSeverityError:
+			return model.ParseQualityErrors
+		case lsp.DiagnosticSeverityWarning:
+			quality = model.ParseQualityWarnings
+		}
+	}
+	return quality
+}
+
+func severityToString(severity int) string {
+	switch severity {
+	case lsp.DiagnosticSeverityError:
+		return "error"
+	case lsp.DiagnosticSeverityWarning:
+		return "warning"
+	case lsp.DiagnosticSeverityInformation:
+		return "information"
+	case lsp.DiagnosticSeverityHint:
+		return "hint"
+	}
+	return "unknown"
+}
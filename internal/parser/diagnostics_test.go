@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"testing"
+
+	"clangd-parser/internal/lsp"
+	"clangd-parser/internal/model"
+)
+
+func diagAt(line, severity int, message string) lsp.Diagnostic {
+	return lsp.Diagnostic{
+		Range:    lsp.Range{Start: lsp.Position{Line: line}, End: lsp.Position{Line: line}},
+		Severity: severity,
+		Message:  message,
+	}
+}
+
+func TestAttachDiagnostics(t *testing.T) {
+	chunks := []model.SemanticChunk{
+		{Name: "good", LineFrom: 1, LineTo: 5},
+		{Name: "bad", LineFrom: 10, LineTo: 20},
+	}
+
+	diags := []lsp.Diagnostic{
+		diagAt(11, lsp.DiagnosticSeverityError, "unknown type name 'Foo'"),
+		diagAt(14, lsp.DiagnosticSeverityWarning, "unused variable 'x'"),
+		diagAt(15, lsp.DiagnosticSeverityHint, "ignored hint"),
+		diagAt(30, lsp.DiagnosticSeverityError, "outside every chunk"),
+	}
+
+	AttachDiagnostics(chunks, diags, true)
+
+	for _, c := range chunks {
+		if c.ParseQuality != model.ParseQualityErrors {
+			t.Errorf("%s: expected parse quality %q, got %q", c.Name, model.ParseQualityErrors, c.ParseQuality)
+		}
+	}
+
+	if chunks[0].Diagnostics != nil {
+		t.Errorf("Expected no diagnostics on 'good', got %+v", chunks[0].Diagnostics)
+	}
+
+	d := chunks[1].Diagnostics
+	if d == nil {
+		t.Fatal("Expected diagnostics on 'bad'")
+	}
+	if d.Errors != 1 || d.Warnings != 1 {
+		t.Errorf("Expected 1 error and 1 warning, got %d and %d", d.Errors, d.Warnings)
+	}
+	if len(d.Messages) != 2 || d.Messages[0].Line != 12 || d.Messages[0].Severity != "error" {
+		t.Errorf("Unexpected messages: %+v", d.Messages)
+	}
+
+	// Members of a group get the diagnostics in their own lines
+	group := []model.SemanticChunk{{
+		Name: "bad, good", LineFrom: 10, LineTo: 20,
+		Members: []model.GroupMember{{Name: "bad", LineFrom: 10, LineTo: 12}, {Name: "good", LineFrom: 16, LineTo: 20}},
+	}}
+	AttachDiagnostics(group, diags, true)
+	if m := group[0].Members; m[0].Diagnostics == nil || m[0].Diagnostics.Errors != 1 || m[1].Diagnostics != nil {
+		t.Errorf("Unexpected member diagnostics %+v, %+v", m[0].Diagnostics, m[1].Diagnostics)
+	}
+}
+
+func TestParseQuality(t *testing.T) {
+	tests := []struct {
+		diags     []lsp.Diagnostic
+		published bool
+		expected  string
+	}{
+		{nil, true, model.ParseQualityClean},
+		{nil, false, model.ParseQualityUnknown},
+		{[]lsp.Diagnostic{diagAt(0, lsp.DiagnosticSeverityHint, "")}, true, model.ParseQualityClean},
+		{[]lsp.Diagnostic{diagAt(0, lsp.DiagnosticSeverityWarning, "")}, true, model.ParseQualityWarnings},
+		{[]lsp.Diagnostic{
+			diagAt(0, lsp.DiagnosticSeverityWarning, ""),
+			diagAt(1, lsp.DiagnosticSeverityError, ""),
+		}, true, model.ParseQualityErrors},
+	}
+
+	for i, tt := range tests {
+		if got := ParseQuality(tt.diags, tt.published); got != tt.expected {
+			t.Errorf("case %d: ParseQuality = %s, expected %s", i, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+
+	"clangd-parser/internal/lsp"
+	"clangd-parser/internal/model"
+)
+
+// docComment returns the text of a symbol's documentation comment without
+// the comment markers. A comment above the symbol wins over a trailing
+// ///< comment on its last line.
+func docComment(symbol lsp.DocumentSymbol, fileLines []string) []string {
+	if lines := leadingComment(symbol.Range.Start.Line, fileLines); lines != nil {
+		return lines
+	}
+	return trailingComment(symbol.Range.End.Line, fileLines)
+}
+
+// leadingComment collects the /// or //! lines or the /** */ or /*! */
+// block ending above line start. Blank lines in between are skipped.
+func leadingComment(start int, fileLines []string) []string {
+	i := start - 1
+	for i >= 0 && i < len(fileLines) && strings.TrimSpace(fileLines[i]) == "" {
+		i--
+	}
+	if i < 0 || i >= len(fileLines) {
+		return nil
+	}
+
+	if strings.HasSuffix(strings.TrimSpace(fileLines[i]), "*/") {
+		for j := i; j >= 0; j-- {
+			line := strings.TrimSpace(fileLines[j])
+			idx := strings.Index(line, "/*")
+			switch {
+			case idx > 0:
+				return nil // trailing comment of a line of code
+			case idx == 0 && isDocBlock(line):
+				return blockComment(fileLines[j : i+1])
+			case idx == 0:
+				return nil
+			}
+		}
+		return nil
+	}
+
+	var lines []string
+	for ; i >= 0; i-- {
+		line := strings.TrimSpace(fileLines[i])
+		if line == "" {
+			continue
+		}
+		text, ok := lineComment(line)
+		if !ok {
+			break
+		}
+		lines = append([]string{text}, lines...)
+	}
+	return lines
+}
+
+// trailingComment returns a ///<, //!<, /**< or /*!< comment that follows
+// the code on a line
+func trailingComment(line int, fileLines []string) []string {
+	if line < 0 || line >= len(fileLines) {
+		return nil
+	}
+
+	for _, marker := range []string{"///<", "//!<", "/**<", "/*!<"} {
+		idx := strings.Index(fileLines[line], marker)
+		if idx < 0 {
+			continue
+		}
+		text := fileLines[line][idx+len(marker):]
+		if strings.HasPrefix(marker, "/*") {
+			text, _, _ = strings.Cut(text, "*/")
+		}
+		return []string{strings.TrimSpace(text)}
+	}
+	return nil
+}
+
+// lineComment strips the marker of a /// or //! documentation line
+func lineComment(line string) (string, bool) {
+	for _, marker := range []string{"///", "//!"} {
+		if !strings.HasPrefix(line, marker) {
+			continue
+		}
+		rest := line[len(marker):]
+		if strings.HasPrefix(rest, "<") || strings.HasPrefix(rest, "/") {
+			return "", false // trailing comment or separator
+		}
+		return strings.TrimSpace(rest), true
+	}
+	return "", false
+}
+
+// isDocBlock reports whether a block comment starts as documentation of the
+// symbol below
+func isDocBlock(line string) bool {
+	if strings.HasPrefix(line, "/*!") {
+		return !strings.HasPrefix(line, "/*!<")
+	}
+	return strings.HasPrefix(line, "/**") && !strings.HasPrefix(line, "/***") &&
+		!strings.HasPrefix(line, "/**/") && !strings.HasPrefix(line, "/**<")
+}
+
+// blockComment strips the markers and leading asterisks of a block comment
+func blockComment(block []string) []string {
+	lines := make([]string, len(block))
+	for i, line := range block {
+		line = strings.TrimSpace(line)
+		if i == 0 {
+			line = line[3:]
+		}
+		if i == len(block)-1 {
+			line = strings.TrimRight(strings.TrimSuffix(line, "*/"), "*")
+		}
+		if i > 0 {
+			line = strings.TrimPrefix(line, "*")
+		}
+		lines[i] = strings.TrimSpace(line)
+	}
+
+	// Drop the empty lines left by markers on lines of their own
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// flattenDoc joins the lines of a comment into the flat docstring
+func flattenDoc(lines []string) string {
+	var parts []string
+	for _, line := range lines {
+		if line != "" {
+			parts = append(parts, line)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+var (
+	// docCommand matches a command at the start of a line, such as
+	// "@param[in] x" or "\brief"
+	docCommand = regexp.MustCompile(`^[@\\]([a-z]+)(\[[^\]]*\])?(\s+|$)`)
+
+	// inlineCommand matches formatting commands around words within text
+	inlineCommand = regexp.MustCompile(`[@\\](?:c|p|a|e|b|em|ref)\s+`)
+)
+
+// docSection is a paragraph of a comment, either free text or the argument
+// of a command
+type docSection struct {
+	command string
+	text    []string
+}
+
+// detailLabels are commands whose paragraphs go into the details with a
+// label
+var detailLabels = map[string]string{
+	"note":      "Note",
+	"warning":   "Warning",
+	"attention": "Attention",
+	"remark":    "Remark",
+	"remarks":   "Remark",
+	"pre":       "Precondition",
+	"post":      "Postcondition",
+	"invariant": "Invariant",
+	"since":     "Since",
+	"todo":      "Todo",
+}
+
+// structuralCommands describe where the comment belongs rather than what
+// the symbol does; their lines are dropped
+var structuralCommands = map[string]bool{
+	"file": true, "class": true, "struct": true, "union": true, "fn": true,
+	"var": true, "typedef": true, "def": true, "enum": true, "namespace": true,
+	"ingroup": true, "defgroup": true, "addtogroup": true, "name": true,
+}
+
+// ParseDoxygen splits the lines of a documentation comment, without the
+// comment markers, into its Doxygen sections. Without @brief the first
+// paragraph is the brief description. It returns nil for an empty comment.
+func ParseDoxygen(lines []string) *model.Doc {
+	var sections []docSection
+	var current *docSection
+	end := func() {
+		if current != nil && (current.command != "" || len(current.text) > 0) {
+			sections = append(sections, *current)
+		}
+		current = nil
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" {
+			end()
+			continue
+		}
+		if line == "@{" || line == "@}" || line == `\{` || line == `\}` {
+			continue // member group markers
+		}
+
+		m := docCommand.FindStringSubmatch(line)
+		if m == nil {
+			if current == nil {
+				current = &docSection{}
+			}
+			current.text = append(current.text, line)
+			continue
+		}
+
+		command, rest := m[1], line[len(m[0]):]
+		switch {
+		case command == "code" || command == "verbatim":
+			end()
+			closing := "end" + command
+			var code []string
+			for i++; i < len(lines); i++ {
+				if c := docCommand.FindStringSubmatch(strings.TrimSpace(lines[i])); c != nil && c[1] == closing {
+					break
+				}
+				code = append(code, lines[i])
+			}
+			sections = append(sections, docSection{command: command, text: code})
+		case structuralCommands[command]:
+			end()
+		default:
+			end()
+			current = &docSection{command: command}
+			if rest != "" {
+				current.text = []string{rest}
+			}
+		}
+	}
+	end()
+
+	if len(sections) == 0 {
+		return nil
+	}
+	return buildDoc(sections)
+}
+
+// buildDoc fills a Doc from the sections of a comment in order
+func buildDoc(sections []docSection) *model.Doc {
+	doc := &model.Doc{}
+	var details []string
+	briefIndex := -1
+
+	for _, s := range sections {
+		text := inlineCommand.ReplaceAllString(strings.Join(s.text, " "), "")
+
+		switch s.command {
+		case "":
+			if doc.Brief == "" && briefIndex < 0 {
+				briefIndex = len(details)
+			}
+			details = append(details, text)
+		case "brief", "short":
+			doc.Brief = joinText(doc.Brief, text)
+		case "details", "par":
+			details = append(details, text)
+		case "code", "verbatim":
+			details = append(details, strings.Join(s.text, "\n"))
+		case "param":
+			doc.Params = addEntry(doc.Params, text)
+		case "tparam":
+			doc.TemplateParams = addEntry(doc.TemplateParams, text)
+		case "throw", "throws", "exception":
+			doc.Throws = addEntry(doc.Throws, text)
+		case "return", "returns", "result", "retval":
+			doc.Returns = joinText(doc.Returns, text)
+		case "deprecated":
+			doc.Deprecated = true
+			doc.DeprecatedNote = joinText(doc.DeprecatedNote, text)
+		case "see", "sa":
+			for _, ref := range strings.Split(text, ",") {
+				if ref = strings.TrimSpace(ref); ref != "" {
+					doc.See = append(doc.See, ref)
+				}
+			}
+		default:
+			if label, ok := detailLabels[s.command]; ok {
+				text = label + ": " + text
+			}
+			details = append(details, text)
+		}
+	}
+
+	// Without @brief the first free paragraph is the brief description
+	if doc.Brief == "" && briefIndex >= 0 {
+		doc.Brief = details[briefIndex]
+		details = append(details[:briefIndex], details[briefIndex+1:]...)
+	}
+	doc.Details = strings.Join(details, "\n\n")
+
+	return doc
+}
+
+// addEntry adds "name description" text to a map keyed by name
+func addEntry(entries map[string]string, text string) map[string]string {
+	name, description, _ := strings.Cut(text, " ")
+	if name == "" {
+		return entries
+	}
+	if entries == nil {
+		entries = make(map[string]string)
+	}
+	entries[name] = joinText(entries[name], strings.TrimSpace(description))
+	return entries
+}
+
+// joinText appends text to a description, separated by a space
+func joinText(description, text string) string {
+	if description == "" {
+		return text
+	}
+	if text == "" {
+		return description
+	}
+	return description + " " + text
+}
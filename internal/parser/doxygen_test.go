@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"clangd-parser/internal/lsp"
+	"clangd-parser/internal/model"
+)
+
+func TestDocComment(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string // the symbol is on the last line
+		want   []string
+	}{
+		{"line comments", "/// Adds one\n///\n/// More\nint inc(int x);", []string{"Adds one", "", "More"}},
+		{"bang lines", "//! Adds one\nint inc(int x);", []string{"Adds one"}},
+		{"javadoc block", "/**\n * Adds one\n *\n * More\n */\nint inc(int x);", []string{"Adds one", "", "More"}},
+		{"qt block", "/*! Adds one */\nint inc(int x);", []string{"Adds one"}},
+		{"blank line before symbol", "/** Adds one */\n\nint inc(int x);", []string{"Adds one"}},
+		{"plain block", "/* Not docs */\nint inc(int x);", nil},
+		{"plain line", "// Not docs\nint inc(int x);", nil},
+		{"banner", "/******/\nint inc(int x);", nil},
+		{"trailing", "int count; ///< How many", []string{"How many"}},
+		{"trailing block", "int count; /**< How many */", []string{"How many"}},
+		{"previous member's", "int a; ///< First\nint b;", nil},
+		{"previous member's block", "int a; /**< First */\nint b;", nil},
+	}
+
+	for _, tt := range tests {
+		lines := strings.Split(tt.source, "\n")
+		last := len(lines) - 1
+		symbol := lsp.DocumentSymbol{Range: lsp.Range{Start: lsp.Position{Line: last}, End: lsp.Position{Line: last}}}
+		if got := docComment(symbol, lines); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, expected %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseDoxygen(t *testing.T) {
+	comment := blockComment(strings.Split(`/**
+ * @brief Looks up a widget
+ *        by its id.
+ *
+ * Searches the registry first and falls back to
+ * the cache. Use @c find_all for several ids.
+ *
+ * @param[in] id  the widget id
+ * @param cache   where to look
+ *                second
+ * @tparam T the widget type
+ * @return the widget, or nullptr
+ * @throws std::out_of_range if id is negative
+ * @deprecated Use \ref lookup instead.
+ * @note Not thread safe.
+ * \see lookup, find_all
+ * @code
+ * auto w = find(1);
+ * @endcode
+ * @ingroup widgets
+ */`, "\n"))
+
+	want := &model.Doc{
+		Brief:          "Looks up a widget by its id.",
+		Details:        "Searches the registry first and falls back to the cache. Use find_all for several ids.\n\nNote: Not thread safe.\n\nauto w = find(1);",
+		Params:         map[string]string{"id": "the widget id", "cache": "where to look second"},
+		TemplateParams: map[string]string{"T": "the widget type"},
+		Returns:        "the widget, or nullptr",
+		Throws:         map[string]string{"std::out_of_range": "if id is negative"},
+		Deprecated:     true,
+		DeprecatedNote: "Use lookup instead.",
+		See:            []string{"lookup", "find_all"},
+	}
+	if got := ParseDoxygen(comment); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseDoxygen =\n%+v\nexpected\n%+v", got, want)
+	}
+
+	// Without @brief the first paragraph is the brief
+	got := ParseDoxygen([]string{"Adds one.", "", "Wraps around at the maximum.", "@deprecated"})
+	if got.Brief != "Adds one." || got.Details != "Wraps around at the maximum." || !got.Deprecated {
+		t.Errorf("Got %+v", got)
+	}
+
+	if got := ParseDoxygen(nil); got != nil {
+		t.Errorf("Expected no doc for an empty comment, got %+v", got)
+	}
+	t.Logf("✓ Parsed %d params", len(want.Params))
+}
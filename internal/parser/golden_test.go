@@ -0,0 +1,104 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"clangd-parser/internal/lsp"
+	"clangd-parser/internal/lsp/transcript"
+	"clangd-parser/internal/model"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+
+// TestGolden replays the language server sessions in testdata/golden and
+// compares the chunks built from them with the checked-in golden files.
+// To add a case, put a source file there together with a transcript
+// recorded by `clangd-parser -test-file <file> -record <name>.transcript.jsonl`
+// and run the test with -update.
+func TestGolden(t *testing.T) {
+	transcripts, err := filepath.Glob(filepath.Join("testdata", "golden", "*.transcript.jsonl"))
+	if err != nil || len(transcripts) == 0 {
+		t.Fatalf("No transcripts found: %v", err)
+	}
+
+	for _, path := range transcripts {
+		name := strings.TrimSuffix(filepath.Base(path), ".transcript.jsonl")
+		t.Run(name, func(t *testing.T) {
+			sources, _ := filepath.Glob(filepath.Join("testdata", "golden", name+".*"))
+			source := ""
+			for _, s := range sources {
+				if !strings.HasSuffix(s, ".jsonl") && !strings.HasSuffix(s, ".json") {
+					source = s
+				}
+			}
+			if source == "" {
+				t.Fatalf("No source file for %s", name)
+			}
+
+			got, err := json.MarshalIndent(replayChunks(t, path, source), "", "  ")
+			if err != nil {
+				t.Fatalf("Failed to marshal chunks: %v", err)
+			}
+
+			goldenPath := filepath.Join("testdata", "golden", name+".golden.json")
+			if *update {
+				if err := os.WriteFile(goldenPath, got, 0644); err != nil {
+					t.Fatalf("Failed to update %s: %v", goldenPath, err)
+				}
+				return
+			}
+
+			want, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("Failed to read golden file (run with -update to create it): %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("Chunks differ from %s (run with -update if intended):\n%s", goldenPath, got)
+			}
+		})
+	}
+}
+
+// replayChunks replays a transcript through the LSP client and converts the
+// symbols it returns for source
+func replayChunks(t *testing.T, transcriptPath, source string) []model.SemanticChunk {
+	t.Helper()
+	ctx := context.Background()
+
+	f, err := os.Open(transcriptPath)
+	if err != nil {
+		t.Fatalf("Failed to open transcript: %v", err)
+	}
+	defer f.Close()
+
+	entries, err := transcript.Load(f)
+	if err != nil {
+		t.Fatalf("Failed to load transcript: %v", err)
+	}
+
+	replayer, rwc := transcript.Replay(entries)
+	defer replayer.Close()
+
+	client, err := lsp.NewClientFromStream(ctx, rwc, lsp.ClangdSpec(""), filepath.Dir(source))
+	if err != nil {
+		t.Fatalf("Failed to initialize from transcript: %v", err)
+	}
+	defer client.Close(ctx)
+
+	symbols, err := client.GetDocumentSymbols(ctx, source)
+	if err != nil {
+		t.Fatalf("GetDocumentSymbols failed: %v (replay: %v)", err, replayer.Err())
+	}
+
+	chunks := ConvertSymbolsToChunks(symbols, source, client.PositionEncoding(), nil)
+	diags, published := client.Diagnostics(source)
+	AttachDiagnostics(chunks, diags, published)
+	return chunks
+}
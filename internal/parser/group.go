@@ -0,0 +1,263 @@
+package parser
+
+import (
+	"os"
+	"strings"
+
+	"clangd-parser/internal/lsp"
+	"clangd-parser/internal/model"
+	"clangd-parser/internal/nl"
+)
+
+// CodeTypeGroup is the code type of chunks that group tiny siblings
+const CodeTypeGroup = "Group"
+
+// GroupChunks merges runs of adjacent sibling chunks under minTokens, such
+// as the getters and setters of a class, into group chunks of at least
+// minTokens. Only chunks without nested chunks are merged, and a leftover
+// too small to make a group of its own joins the group before it. Zero
+// minTokens disables grouping.
+//
+// Grouping runs after enrichment: a group takes over the calls and
+// references of its members, while chunks linked to a single other symbol,
+// such as a declaration with its definition elsewhere or an overriding
+// method, are left as they are.
+func GroupChunks(chunks []model.SemanticChunk, filePath string, minTokens int) []model.SemanticChunk {
+	if minTokens <= 0 || len(chunks) < 2 {
+		return chunks
+	}
+
+	content, _ := os.ReadFile(filePath)
+	lines := lsp.NewText(content, lsp.PositionEncodingUTF8).Lines()
+	parents := enclosingChunks(chunks)
+
+	// tiny reports whether chunk i can join a group
+	tiny := func(i int) bool {
+		if EstimateTokens(chunks[i].Context.Snippet) >= minTokens || linked(chunks[i]) {
+			return false
+		}
+		return i+1 == len(chunks) || parents[i+1] != i
+	}
+
+	var result []model.SemanticChunk
+	for i := 0; i < len(chunks); {
+		if !tiny(i) {
+			result = append(result, chunks[i])
+			i++
+			continue
+		}
+
+		// Siblings are adjacent in the list since tiny chunks have no
+		// nested chunks following them
+		end := i + 1
+		for end < len(chunks) && parents[end] == parents[i] && tiny(end) {
+			end++
+		}
+		result = append(result, groupRun(chunks[i:end], lines, minTokens)...)
+		i = end
+	}
+	return result
+}
+
+// groupRun splits a run of tiny siblings into groups of at least minTokens
+func groupRun(run []model.SemanticChunk, lines []string, minTokens int) []model.SemanticChunk {
+	var groups [][]model.SemanticChunk
+	var current []model.SemanticChunk
+	tokens := 0
+
+	for _, c := range run {
+		current = append(current, c)
+		tokens += EstimateTokens(c.Context.Snippet)
+		if tokens >= minTokens {
+			groups = append(groups, current)
+			current, tokens = nil, 0
+		}
+	}
+
+	switch {
+	case len(current) == 1 && len(groups) > 0:
+		groups[len(groups)-1] = append(groups[len(groups)-1], current...)
+	case len(current) > 0:
+		groups = append(groups, current)
+	}
+
+	var result []model.SemanticChunk
+	for _, members := range groups {
+		if len(members) == 1 {
+			result = append(result, members[0])
+		} else {
+			result = append(result, newGroup(members, lines))
+		}
+	}
+	return result
+}
+
+// newGroup builds the chunk standing for members, spanning from the first
+// to the last of them
+func newGroup(members []model.SemanticChunk, lines []string) model.SemanticChunk {
+	first, last := members[0], members[len(members)-1]
+
+	group := model.SemanticChunk{
+		ID:       model.GroupID(first.ID),
+		CodeType: CodeTypeGroup,
+		Line:     first.LineFrom,
+		LineFrom: first.LineFrom,
+		LineTo:   last.LineTo,
+		Context:  first.Context,
+
+		// Siblings share their enclosing scopes
+		Scopes: first.Scopes,
+	}
+	group.Context.Snippet = extractSnippet(lsp.Range{
+		Start: lsp.Position{Line: first.LineFrom - 1},
+		End:   lsp.Position{Line: last.LineTo - 1},
+	}, lines)
+
+	if first.Range != nil && last.Range != nil {
+		rng := *first.Range
+		rng.EndLine = last.Range.EndLine
+		rng.EndColumn = last.Range.EndColumn
+		rng.EndRuneColumn = last.Range.EndRuneColumn
+		rng.EndOffset = last.Range.EndOffset
+		group.Range = &rng
+	}
+
+	var names, signatures, docs, snippets []string
+	var usages [][]model.UsageSite
+	seen := make(map[string]bool)
+	memberIDs := make(map[string]bool, len(members))
+	for _, m := range members {
+		memberIDs[m.ID] = true
+	}
+	for _, m := range members {
+		names = append(names, m.Name)
+		snippets = append(snippets, m.Context.Snippet)
+		signatures = append(signatures, m.Signature)
+		if m.Docstring != "" {
+			docs = append(docs, m.Docstring)
+		}
+		for _, tok := range nl.Subtokenize(m.Name) {
+			if !seen[tok] {
+				seen[tok] = true
+				group.IdentTokens = append(group.IdentTokens, tok)
+			}
+		}
+		group.Calls = appendOuterRefs(group.Calls, m.Calls, memberIDs)
+		group.CalledBy = appendOuterRefs(group.CalledBy, m.CalledBy, memberIDs)
+		group.ReferenceCount += m.ReferenceCount
+		usages = append(usages, m.Usages)
+		group.Diagnostics = mergeDiagnostics(group.Diagnostics, m.Diagnostics)
+		group.Members = append(group.Members, model.GroupMember{
+			ID:            m.ID,
+			Name:          m.Name,
+			QualifiedName: m.QualifiedName,
+			CodeType:      m.CodeType,
+			LineFrom:      m.LineFrom,
+			LineTo:        m.LineTo,
+			NameLine:      m.NameLine,
+			NameColumn:    m.NameColumn,
+			Doc:           m.Doc,
+			SignatureInfo: m.SignatureInfo,
+			MemberSymbols: m.MemberSymbols,
+			Diagnostics:   m.Diagnostics,
+		})
+	}
+	group.Usages = mergeUsages(usages)
+
+	if group.Context.Snippet == "" {
+		group.Context.Snippet = strings.Join(snippets, "\n")
+	}
+	group.Name = strings.Join(names, ", ")
+	group.Signature = strings.Join(signatures, "\n")
+	group.Docstring = strings.Join(docs, " ")
+	return group
+}
+
+// mergeUsages takes the usage sites of members in turns, leaving out
+// duplicates, up to as many as the member with the most has. That is the
+// limit the sites were sampled with.
+func mergeUsages(usages [][]model.UsageSite) []model.UsageSite {
+	limit := 0
+	for _, u := range usages {
+		limit = max(limit, len(u))
+	}
+
+	var merged []model.UsageSite
+	seen := make(map[model.UsageSite]bool)
+	for i := 0; i < limit && len(merged) < limit; i++ {
+		for _, u := range usages {
+			if i < len(u) && !seen[u[i]] && len(merged) < limit {
+				seen[u[i]] = true
+				merged = append(merged, u[i])
+			}
+		}
+	}
+	return merged
+}
+
+// mergeDiagnostics adds the diagnostics of a member to those of its group
+func mergeDiagnostics(group, member *model.ChunkDiagnostics) *model.ChunkDiagnostics {
+	if member == nil {
+		return group
+	}
+	if group == nil {
+		group = &model.ChunkDiagnostics{}
+	}
+	group.Errors += member.Errors
+	group.Warnings += member.Warnings
+	for _, m := range member.Messages {
+		if len(group.Messages) < maxChunkDiagnostics {
+			group.Messages = append(group.Messages, m)
+		}
+	}
+	return group
+}
+
+// linked reports whether a chunk points at a single other symbol that must
+// keep finding it by ID
+func linked(c model.SemanticChunk) bool {
+	return c.Declaration != nil || c.Definition != nil || c.Overrides != nil || len(c.Bases) > 0 || len(c.Derived) > 0
+}
+
+// appendOuterRefs appends the refs to chunks outside a group that aren't
+// present yet
+func appendOuterRefs(refs, add []model.ChunkRef, members map[string]bool) []model.ChunkRef {
+	for _, ref := range add {
+		present := members[ref.ID]
+		for _, r := range refs {
+			present = present || r.ID == ref.ID
+		}
+		if !present {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// enclosingChunks returns the index of the innermost chunk containing each
+// chunk, or -1 at the top level. Chunks are in the order the symbol tree is
+// walked, so each one follows the chunks enclosing it.
+func enclosingChunks(chunks []model.SemanticChunk) []int {
+	parents := make([]int, len(chunks))
+	var stack []int
+	for i := range chunks {
+		for len(stack) > 0 && !contains(chunks[stack[len(stack)-1]], chunks[i]) {
+			stack = stack[:len(stack)-1]
+		}
+		parents[i] = -1
+		if len(stack) > 0 {
+			parents[i] = stack[len(stack)-1]
+		}
+		stack = append(stack, i)
+	}
+	return parents
+}
+
+// contains reports whether inner lies within outer, by offset when both
+// have exact ranges
+func contains(outer, inner model.SemanticChunk) bool {
+	if outer.Range != nil && inner.Range != nil {
+		return outer.Range.StartOffset <= inner.Range.StartOffset && inner.Range.EndOffset <= outer.Range.EndOffset
+	}
+	return outer.LineFrom <= inner.LineFrom && inner.LineTo <= outer.LineTo
+}
@@ -0,0 +1,228 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"clangd-parser/internal/lsp"
+	"clangd-parser/internal/model"
+)
+
+func TestGroupChunks(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "point.h")
+	code := `class Point {
+public:
+    int x() const { return x_; }
+    void setX(int x) { x_ = x; }
+    int y() const { return y_; }
+    void setY(int y) { y_ = y; }
+    /// Moves the point
+    void move(int dx, int dy) {
+        x_ += dx;
+        y_ += dy;
+    }
+    int z() const { return 0; }
+private:
+    int x_, y_;
+};
+`
+	if err := os.WriteFile(testFile, []byte(code), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	method := func(name string, from, to, endChar int) lsp.DocumentSymbol {
+		return lsp.DocumentSymbol{
+			Name:           name,
+			Kind:           lsp.SymbolKindMethod,
+			Range:          lsp.Range{Start: lsp.Position{Line: from, Character: 4}, End: lsp.Position{Line: to, Character: endChar}},
+			SelectionRange: lsp.Range{Start: lsp.Position{Line: from, Character: 8}, End: lsp.Position{Line: from, Character: 8 + len(name)}},
+		}
+	}
+	symbols := []lsp.DocumentSymbol{{
+		Name:           "Point",
+		Kind:           lsp.SymbolKindClass,
+		Range:          lsp.Range{Start: lsp.Position{Line: 0}, End: lsp.Position{Line: 14, Character: 2}},
+		SelectionRange: lsp.Range{Start: lsp.Position{Line: 0, Character: 6}, End: lsp.Position{Line: 0, Character: 11}},
+		Children: []lsp.DocumentSymbol{
+			method("x", 2, 2, 32),
+			method("setX", 3, 3, 32),
+			method("y", 4, 4, 32),
+			method("setY", 5, 5, 32),
+			method("move", 7, 10, 5),
+			method("z", 11, 11, 31),
+		},
+	}}
+	chunks := ConvertSymbolsToChunks(symbols, testFile, lsp.PositionEncodingUTF8, nil)
+
+	names := func(chunks []model.SemanticChunk) []string {
+		var names []string
+		for _, c := range chunks {
+			names = append(names, c.Name)
+		}
+		return names
+	}
+
+	grouped := GroupChunks(chunks, testFile, 20)
+	want := []string{"Point", "x, setX", "y, setY", "move", "z"}
+	if got := names(grouped); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got chunks %q, expected %q", got, want)
+	}
+
+	group := grouped[1]
+	if group.CodeType != CodeTypeGroup || group.ID != model.GroupID(chunks[1].ID) || group.Context.StructName != "Point" {
+		t.Errorf("Unexpected group %s %q in %q", group.CodeType, group.ID, group.Context.StructName)
+	}
+	if group.LineFrom != 3 || group.LineTo != 4 || group.Context.Snippet != "    int x() const { return x_; }\n    void setX(int x) { x_ = x; }" {
+		t.Errorf("Expected the group to span both members, got lines %d-%d: %q", group.LineFrom, group.LineTo, group.Context.Snippet)
+	}
+	if group.Range == nil || group.Range.StartLine != 3 || group.Range.EndLine != 4 || group.Range.EndColumn != 33 {
+		t.Errorf("Unexpected group range %+v", group.Range)
+	}
+
+	wantMembers := []model.GroupMember{
+		{ID: chunks[1].ID, Name: "x", QualifiedName: "Point::x", CodeType: "Method", LineFrom: 3, LineTo: 3, NameLine: 3, NameColumn: 9},
+		{ID: chunks[2].ID, Name: "setX", QualifiedName: "Point::setX", CodeType: "Method", LineFrom: 4, LineTo: 4, NameLine: 4, NameColumn: 9},
+	}
+	if !reflect.DeepEqual(group.Members, wantMembers) {
+		t.Errorf("Members = %+v\nexpected %+v", group.Members, wantMembers)
+	}
+	for _, tok := range []string{"x", "setX", "set", "X"} {
+		found := false
+		for _, got := range group.IdentTokens {
+			found = found || got == tok
+		}
+		if !found {
+			t.Errorf("Expected %q in IdentTokens %q", tok, group.IdentTokens)
+		}
+	}
+
+	// A leftover too small for a group of its own joins the one before
+	want = []string{"Point", "x, setX, y", "setY, move, z"}
+	if got := names(GroupChunks(chunks, testFile, 25)); !reflect.DeepEqual(got, want) {
+		t.Errorf("Got chunks %q, expected %q", got, want)
+	}
+
+	if got := GroupChunks(chunks, testFile, 0); !reflect.DeepEqual(got, chunks) {
+		t.Error("Expected no grouping without a minimum size")
+	}
+	t.Logf("✓ Grouped %d chunks into %d", len(chunks), len(grouped))
+}
+
+func TestGroupChunksKeepsEnrichment(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "size.h")
+	code := `struct Size {
+    /// Width in pixels
+    int width() const { return w; }
+    int height() const { return h; }
+    int w, h;
+};
+`
+	if err := os.WriteFile(testFile, []byte(code), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	method := func(name string, line int) lsp.DocumentSymbol {
+		return lsp.DocumentSymbol{
+			Name:           name,
+			Kind:           lsp.SymbolKindMethod,
+			Range:          lsp.Range{Start: lsp.Position{Line: line, Character: 4}, End: lsp.Position{Line: line, Character: 36}},
+			SelectionRange: lsp.Range{Start: lsp.Position{Line: line, Character: 8}, End: lsp.Position{Line: line, Character: 8 + len(name)}},
+		}
+	}
+	symbols := []lsp.DocumentSymbol{{
+		Name:           "Size",
+		Kind:           lsp.SymbolKindStruct,
+		Range:          lsp.Range{Start: lsp.Position{Line: 0}, End: lsp.Position{Line: 5, Character: 2}},
+		SelectionRange: lsp.Range{Start: lsp.Position{Line: 0, Character: 7}, End: lsp.Position{Line: 0, Character: 11}},
+		Children:       []lsp.DocumentSymbol{method("width", 2), method("height", 3)},
+	}}
+	chunks := ConvertSymbolsToChunks(symbols, testFile, lsp.PositionEncodingUTF8, nil)
+
+	// Enrichment runs before grouping
+	shared := model.UsageSite{FilePath: "/src/view.cpp", Line: 7, Snippet: "area(s.width(), s.height())"}
+	width, height := &chunks[1], &chunks[2]
+	width.SignatureInfo = &model.SignatureInfo{ReturnType: "int"}
+	width.Usages = []model.UsageSite{shared, {FilePath: "/src/view.cpp", Line: 9}}
+	height.Usages = []model.UsageSite{shared, {FilePath: "/src/main.cpp", Line: 3}}
+	height.Diagnostics = &model.ChunkDiagnostics{Warnings: 1, Messages: []model.DiagnosticMessage{{Line: 4, Severity: "warning", Message: "unused"}}}
+	if width.Doc == nil || width.Doc.Brief != "Width in pixels" {
+		t.Fatalf("Expected width to be documented, got %+v", width.Doc)
+	}
+
+	grouped := GroupChunks(chunks, testFile, 100)
+	if len(grouped) != 2 || grouped[1].CodeType != CodeTypeGroup {
+		t.Fatalf("Expected width and height to be grouped, got %+v", grouped)
+	}
+	group := grouped[1]
+
+	members := group.Members
+	if members[0].Doc != width.Doc || members[0].SignatureInfo != width.SignatureInfo {
+		t.Errorf("Expected width's doc and signature on its member, got %+v", members[0])
+	}
+	if members[1].QualifiedName != "Size::height" || members[1].NameLine != 4 || members[1].Diagnostics != height.Diagnostics {
+		t.Errorf("Expected height's name, position and diagnostics on its member, got %+v", members[1])
+	}
+	if wantScopes := []model.Scope{{Name: "Size", Kind: "Struct"}}; !reflect.DeepEqual(group.Scopes, wantScopes) {
+		t.Errorf("Expected the group in the members' scope, got %+v", group.Scopes)
+	}
+	if group.Diagnostics == nil || group.Diagnostics.Warnings != 1 {
+		t.Errorf("Expected the member's warning on the group, got %+v", group.Diagnostics)
+	}
+
+	// Usages are de-duplicated and capped like a single member's
+	wantUsages := []model.UsageSite{shared, {FilePath: "/src/view.cpp", Line: 9}}
+	if !reflect.DeepEqual(group.Usages, wantUsages) {
+		t.Errorf("Usages = %+v\nexpected %+v", group.Usages, wantUsages)
+	}
+	t.Logf("✓ Kept the enrichment of %d members", len(members))
+}
+
+func TestGroupChunksKeepsMemberSymbols(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "modes.h")
+	code := `enum Dir { Up, Down };
+enum Mode { On = 2, Off };
+`
+	if err := os.WriteFile(testFile, []byte(code), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	at := func(line, from, to int) lsp.Range {
+		return lsp.Range{Start: lsp.Position{Line: line, Character: from}, End: lsp.Position{Line: line, Character: to}}
+	}
+	enum := func(name string, line, end int, enumerators ...lsp.DocumentSymbol) lsp.DocumentSymbol {
+		return lsp.DocumentSymbol{
+			Name:           name,
+			Kind:           lsp.SymbolKindEnum,
+			Range:          at(line, 0, end),
+			SelectionRange: at(line, 5, 5+len(name)),
+			Children:       enumerators,
+		}
+	}
+	enumerator := func(name string, line, from, to int) lsp.DocumentSymbol {
+		return lsp.DocumentSymbol{Name: name, Kind: lsp.SymbolKindEnumMember, Range: at(line, from, to), SelectionRange: at(line, from, from+len(name))}
+	}
+	symbols := []lsp.DocumentSymbol{
+		enum("Dir", 0, 21, enumerator("Up", 0, 11, 13), enumerator("Down", 0, 15, 19)),
+		enum("Mode", 1, 25, enumerator("On", 1, 12, 18), enumerator("Off", 1, 20, 23)),
+	}
+
+	chunks := ConvertSymbolsToChunks(symbols, testFile, lsp.PositionEncodingUTF8, Kinds{"EnumMember": KindMember})
+	grouped := GroupChunks(chunks, testFile, 100)
+	if len(grouped) != 1 || grouped[0].CodeType != CodeTypeGroup {
+		t.Fatalf("Expected both enums in one group, got %+v", grouped)
+	}
+
+	var got []string
+	for _, m := range grouped[0].Members {
+		for _, s := range m.MemberSymbols {
+			got = append(got, m.Name+"::"+s.Name+"="+s.Value)
+		}
+	}
+	want := []string{"Dir::Up=0", "Dir::Down=1", "Mode::On=2", "Mode::Off=3"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Got enumerators %q, expected %q", got, want)
+	}
+	t.Logf("✓ Kept %d enumerators of grouped enums", len(got))
+}
@@ -0,0 +1,166 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// IgnoreFileName is the project-level ignore file read from the root. It
+// uses .gitignore syntax and takes precedence over the git ignore files.
+const IgnoreFileName = ".chunkerignore"
+
+// Filter narrows down the files discovery returns
+type Filter struct {
+	// Include and Exclude are doublestar globs matched against the path
+	// relative to the root with forward slashes, e.g. "src/**/*.cpp". When
+	// Include is set only matching files are kept. Exclude always wins.
+	Include []string
+	Exclude []string
+}
+
+// Validate checks that all globs are well formed
+func (f Filter) Validate() error {
+	for _, pattern := range append(append([]string(nil), f.Include...), f.Exclude...) {
+		if !doublestar.ValidatePattern(pattern) {
+			return fmt.Errorf("invalid glob %q", pattern)
+		}
+	}
+	return nil
+}
+
+// Keep reports whether the file at rel, relative to the root, passes the
+// include and exclude globs
+func (f Filter) Keep(rel string) bool {
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range f.Exclude {
+		if ok, _ := doublestar.Match(pattern, rel); ok {
+			return false
+		}
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, pattern := range f.Include {
+		if ok, _ := doublestar.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoreRule is a single line of an ignore file
+type ignoreRule struct {
+	base    string // directory of the ignore file relative to the root, "" for the root
+	pattern string // doublestar pattern relative to base
+	negate  bool
+	dirOnly bool
+}
+
+// matches reports whether the rule applies to rel, a slash separated path
+// relative to the root
+func (r ignoreRule) matches(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		if !strings.HasPrefix(rel, r.base+"/") {
+			return false
+		}
+		rel = rel[len(r.base)+1:]
+	}
+	ok, _ := doublestar.Match(r.pattern, rel)
+	return ok
+}
+
+// parseIgnoreFile reads the rules of a .gitignore style file whose patterns
+// are relative to base. A missing file has no rules.
+func parseIgnoreFile(filePath, base string) []ignoreRule {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreLine(scanner.Text(), base); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// parseIgnoreLine turns a .gitignore line into a rule. Patterns without a
+// slash other than a trailing one match at any depth below base.
+func parseIgnoreLine(line, base string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	rule := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:] // escaped leading # or !
+	}
+
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	if strings.Contains(line, "/") {
+		line = strings.TrimPrefix(line, "/")
+	} else {
+		line = "**/" + line
+	}
+	rule.pattern = path.Clean(line)
+	return rule, true
+}
+
+// ignoreSet evaluates ignore rules by precedence. Within and across the
+// lists the last matching rule decides, so later lists override earlier
+// ones: .git/info/exclude, then the .gitignore files from the root down,
+// then the project ignore file.
+type ignoreSet struct {
+	exclude   []ignoreRule
+	gitignore []ignoreRule
+	project   []ignoreRule
+}
+
+// newIgnoreSet loads the ignore files found at the root
+func newIgnoreSet(root string) *ignoreSet {
+	return &ignoreSet{
+		exclude: parseIgnoreFile(filepath.Join(root, ".git", "info", "exclude"), ""),
+		project: parseIgnoreFile(filepath.Join(root, IgnoreFileName), ""),
+	}
+}
+
+// enter adds the rules of the .gitignore in dir, relative to the root as rel
+func (s *ignoreSet) enter(dir, rel string) {
+	s.gitignore = append(s.gitignore, parseIgnoreFile(filepath.Join(dir, ".gitignore"), rel)...)
+}
+
+func (s *ignoreSet) ignored(rel string, isDir bool) bool {
+	ignored := false
+	for _, rules := range [][]ignoreRule{s.exclude, s.gitignore, s.project} {
+		for _, r := range rules {
+			if r.matches(rel, isDir) {
+				ignored = !r.negate
+			}
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,104 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestIgnoreRules(t *testing.T) {
+	tests := []struct {
+		line    string
+		base    string
+		rel     string
+		isDir   bool
+		matches bool
+	}{
+		{"*.pb.cc", "", "proto/gen/msg.pb.cc", false, true},
+		{"third_party/", "", "third_party", true, true},
+		{"third_party/", "", "third_party", false, false},
+		{"third_party/", "", "src/third_party", true, true},
+		{"/external", "", "external", true, true},
+		{"/external", "", "src/external", true, false},
+		{"gen/*.h", "", "gen/a.h", false, true},
+		{"gen/*.h", "", "src/gen/a.h", false, false},
+		{"docs/**", "", "docs/a/b.cpp", false, true},
+		{"*.h", "src", "src/x/a.h", false, true},
+		{"*.h", "src", "lib/a.h", false, false},
+		{`\#literal.cpp`, "", "#literal.cpp", false, true},
+		{"# comment", "", "# comment", false, false},
+	}
+
+	for _, tt := range tests {
+		rule, ok := parseIgnoreLine(tt.line, tt.base)
+		got := ok && rule.matches(tt.rel, tt.isDir)
+		if got != tt.matches {
+			t.Errorf("%q in %q against %q (dir %v): got %v, expected %v", tt.line, tt.base, tt.rel, tt.isDir, got, tt.matches)
+		}
+	}
+}
+
+func TestFindCppFilesIgnores(t *testing.T) {
+	root := t.TempDir()
+
+	files := map[string]string{
+		".gitignore":           "third_party/\n*.pb.cc\n/out\n",
+		".git/info/exclude":    "scratch/\n",
+		IgnoreFileName:         "external/\n!keep.pb.cc\n",
+		"src/.gitignore":       "generated_*.h\n!generated_api.h\n",
+		"src/main.cpp":         "",
+		"src/msg.pb.cc":        "",
+		"src/keep.pb.cc":       "",
+		"src/generated_x.h":    "",
+		"src/generated_api.h":  "",
+		"src/tests/a_test.cpp": "",
+		"lib/generated_y.h":    "",
+		"third_party/z.cpp":    "",
+		"external/e.cpp":       "",
+		"scratch/s.cpp":        "",
+		"out/o.cpp":            "",
+		"tools/out/t.cpp":      "",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	find := func(filter Filter) []string {
+		t.Helper()
+		found, err := FindCppFiles(root, filter)
+		if err != nil {
+			t.Fatalf("FindCppFiles failed: %v", err)
+		}
+		var rel []string
+		for _, f := range found {
+			r, _ := filepath.Rel(root, f)
+			rel = append(rel, filepath.ToSlash(r))
+		}
+		sort.Strings(rel)
+		return rel
+	}
+
+	want := []string{"lib/generated_y.h", "src/generated_api.h", "src/keep.pb.cc", "src/main.cpp", "src/tests/a_test.cpp", "tools/out/t.cpp"}
+	if got := find(Filter{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Without globs got %q\nexpected %q", got, want)
+	}
+
+	want = []string{"src/generated_api.h", "src/keep.pb.cc", "src/main.cpp"}
+	if got := find(Filter{Include: []string{"src/**"}, Exclude: []string{"**/tests/**"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("With globs got %q\nexpected %q", got, want)
+	}
+
+	if _, err := FindCppFiles(root, Filter{Exclude: []string{"src/[a"}}); err == nil {
+		t.Error("Expected an error for a malformed glob")
+	}
+
+	t.Logf("✓ Kept %d of %d files", len(want), len(files))
+}
@@ -0,0 +1,181 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"clangd-parser/internal/lsp"
+	"clangd-parser/internal/model"
+)
+
+// KindMode is what becomes of a symbol of a small kind
+type KindMode string
+
+const (
+	KindSkip   KindMode = "skip"   // dropped
+	KindChunk  KindMode = "chunk"  // a lightweight chunk of its own
+	KindMember KindMode = "member" // listed in the parent chunk's MemberSymbols
+)
+
+// SmallKinds are the code types of symbols too small to always get a chunk
+var SmallKinds = []string{"Field", "EnumMember", "Variable", "Constant", "Property", "TypeParameter", "Typedef", "TypeAlias"}
+
+// Kinds selects what becomes of small symbols by code type. Functions,
+// classes and the other main kinds always get chunks. Small kinds that
+// aren't selected fall back to DefaultKinds and are skipped otherwise.
+type Kinds map[string]KindMode
+
+// DefaultKinds keeps typedefs and aliases as chunks, since servers report
+// them as classes
+var DefaultKinds = Kinds{"Typedef": KindChunk, "TypeAlias": KindChunk}
+
+// Mode returns what becomes of symbols of codeType
+func (k Kinds) Mode(codeType string) KindMode {
+	if mode, ok := k[codeType]; ok {
+		return mode
+	}
+	if mode, ok := DefaultKinds[codeType]; ok {
+		return mode
+	}
+	return KindSkip
+}
+
+// String lists the selection as sorted kind=mode pairs
+func (k Kinds) String() string {
+	var pairs []string
+	for codeType, mode := range k {
+		pairs = append(pairs, codeType+"="+string(mode))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Set adds comma separated kind=mode pairs such as
+// "EnumMember=member,Constant=chunk". Kind names are case insensitive and
+// may contain dashes, e.g. enum-member.
+func (k *Kinds) Set(spec string) error {
+	if *k == nil {
+		*k = make(Kinds)
+	}
+
+	for _, pair := range strings.Split(spec, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		name, mode, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("invalid kind selection %q (want kind=mode)", pair)
+		}
+
+		codeType, ok := smallKind(name)
+		if !ok {
+			return fmt.Errorf("unknown symbol kind %q (want one of %s)", name, strings.Join(SmallKinds, ", "))
+		}
+		switch m := KindMode(strings.ToLower(strings.TrimSpace(mode))); m {
+		case KindSkip, KindChunk, KindMember:
+			(*k)[codeType] = m
+		default:
+			return fmt.Errorf("unknown mode %q for %s (want skip, chunk or member)", mode, codeType)
+		}
+	}
+	return nil
+}
+
+// smallKind finds the code type a user-given kind name refers to
+func smallKind(name string) (string, bool) {
+	name = strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(strings.TrimSpace(name)))
+	for _, codeType := range SmallKinds {
+		if strings.ToLower(codeType) == name {
+			return codeType, true
+		}
+	}
+	return "", false
+}
+
+var (
+	typedefPattern = regexp.MustCompile(`^typedef\b`)
+	aliasPattern   = regexp.MustCompile(`^(template\s*<.*>\s*)?using\s+\w+\s*=`)
+)
+
+// symbolCodeType returns the code type of a symbol. Servers report typedefs
+// and alias declarations as classes, so those are told apart by their
+// source.
+func symbolCodeType(symbol lsp.DocumentSymbol, fileLines []string) string {
+	if symbol.Kind == lsp.SymbolKindClass || symbol.Kind == lsp.SymbolKindStruct {
+		decl := extractDeclaration(symbol.Range, fileLines)
+		switch {
+		case typedefPattern.MatchString(decl):
+			return "Typedef"
+		case aliasPattern.MatchString(decl):
+			return "TypeAlias"
+		}
+	}
+	return symbolKindToString(symbol.Kind)
+}
+
+// symbolMode returns what becomes of a symbol of codeType
+func symbolMode(symbol lsp.DocumentSymbol, codeType string, kinds Kinds) KindMode {
+	for _, small := range SmallKinds {
+		if codeType == small {
+			return kinds.Mode(codeType)
+		}
+	}
+	if shouldExtractSymbol(symbol.Kind) {
+		return KindChunk
+	}
+	return KindSkip
+}
+
+// memberSymbol describes a small symbol for its parent's member list.
+// Enumerators without an initializer continue from the previous one.
+func memberSymbol(symbol lsp.DocumentSymbol, codeType string, text *lsp.Text, fileLines []string, siblings []model.MemberSymbol) model.MemberSymbol {
+	source := symbolSource(symbol.Range, text)
+	member := model.MemberSymbol{
+		Name:      symbol.Name,
+		CodeType:  codeType,
+		Signature: source,
+		Docstring: flattenDoc(docComment(symbol, fileLines)),
+		Line:      symbol.Range.Start.Line + 1,
+	}
+	if source == "" {
+		member.Signature = getSignature(symbol, fileLines)
+	}
+
+	if _, value, ok := strings.Cut(source, "="); ok {
+		member.Value = strings.TrimSpace(value)
+	} else if codeType == "EnumMember" {
+		member.Value = nextEnumValue(siblings)
+	}
+	return member
+}
+
+// symbolSource returns the source of a range on one line, without a
+// trailing separator
+func symbolSource(rng lsp.Range, text *lsp.Text) string {
+	start, end := text.Offset(rng.Start), text.Offset(rng.End)
+	if text.LineCount() == 0 || start >= end {
+		return ""
+	}
+	source := strings.Join(strings.Fields(text.Slice(start, end)), " ")
+	return strings.TrimRight(source, ",; ")
+}
+
+// nextEnumValue returns the value of an enumerator without an initializer:
+// one more than the previous enumerator, or zero for the first. It is empty
+// if the previous value isn't an integer literal.
+func nextEnumValue(siblings []model.MemberSymbol) string {
+	for i := len(siblings) - 1; i >= 0; i-- {
+		if siblings[i].CodeType != "EnumMember" {
+			continue
+		}
+		n, err := strconv.ParseInt(strings.TrimRight(siblings[i].Value, "uUlL"), 0, 64)
+		if err != nil {
+			return ""
+		}
+		return strconv.FormatInt(n+1, 10)
+	}
+	return "0"
+}
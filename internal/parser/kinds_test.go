@@ -0,0 +1,145 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"clangd-parser/internal/lsp"
+	"clangd-parser/internal/model"
+)
+
+func TestSmallKinds(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "config.h")
+	code := `enum Color {
+    Red = 1, ///< Warm
+    Green,
+    Blue ///< Cold
+};
+const char *kConfigKey = "config";
+typedef unsigned int Id;
+`
+	if err := os.WriteFile(testFile, []byte(code), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	symbol := func(name string, kind, line, from, to int) lsp.DocumentSymbol {
+		return lsp.DocumentSymbol{
+			Name:           name,
+			Kind:           kind,
+			Range:          lsp.Range{Start: lsp.Position{Line: line, Character: from}, End: lsp.Position{Line: line, Character: to}},
+			SelectionRange: lsp.Range{Start: lsp.Position{Line: line, Character: from}, End: lsp.Position{Line: line, Character: from + len(name)}},
+		}
+	}
+	enum := symbol("Color", lsp.SymbolKindEnum, 0, 0, 0)
+	enum.Range.End = lsp.Position{Line: 4, Character: 1}
+	enum.SelectionRange = lsp.Range{Start: lsp.Position{Line: 0, Character: 5}, End: lsp.Position{Line: 0, Character: 10}}
+	enum.Children = []lsp.DocumentSymbol{
+		symbol("Red", lsp.SymbolKindEnumMember, 1, 4, 11),
+		symbol("Green", lsp.SymbolKindEnumMember, 2, 4, 9),
+		symbol("Blue", lsp.SymbolKindEnumMember, 3, 4, 8),
+	}
+	symbols := []lsp.DocumentSymbol{
+		enum,
+		symbol("kConfigKey", lsp.SymbolKindVariable, 5, 0, 37),
+		symbol("Id", lsp.SymbolKindClass, 6, 0, 23),
+	}
+
+	codeTypes := func(chunks []model.SemanticChunk) []string {
+		var types []string
+		for _, c := range chunks {
+			types = append(types, c.CodeType)
+		}
+		return types
+	}
+
+	// By default only typedefs and aliases of the small kinds get chunks
+	chunks := ConvertSymbolsToChunks(symbols, testFile, lsp.PositionEncodingUTF8, nil)
+	if got, want := codeTypes(chunks), []string{"Enum", "Typedef"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got code types %q, expected %q", got, want)
+	}
+	if chunks[0].MemberSymbols != nil {
+		t.Errorf("Expected no members by default, got %+v", chunks[0].MemberSymbols)
+	}
+
+	var kinds Kinds
+	if err := kinds.Set("enum-member=member, variable=CHUNK"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	chunks = ConvertSymbolsToChunks(symbols, testFile, lsp.PositionEncodingUTF8, kinds)
+	if got, want := codeTypes(chunks), []string{"Enum", "Variable", "Typedef"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got code types %q, expected %q", got, want)
+	}
+
+	wantMembers := []model.MemberSymbol{
+		{Name: "Red", CodeType: "EnumMember", Signature: "Red = 1", Value: "1", Docstring: "Warm", Line: 2},
+		{Name: "Green", CodeType: "EnumMember", Signature: "Green", Value: "2", Line: 3},
+		{Name: "Blue", CodeType: "EnumMember", Signature: "Blue", Value: "3", Docstring: "Cold", Line: 4},
+	}
+	if !reflect.DeepEqual(chunks[0].MemberSymbols, wantMembers) {
+		t.Errorf("MemberSymbols = %+v\nexpected %+v", chunks[0].MemberSymbols, wantMembers)
+	}
+	if c := chunks[1]; c.Name != "kConfigKey" || c.Context.Snippet != `const char *kConfigKey = "config";` {
+		t.Errorf("Unexpected variable chunk %q: %q", c.Name, c.Context.Snippet)
+	}
+	t.Logf("✓ Folded %d enumerators into %s", len(wantMembers), chunks[0].Name)
+}
+
+func TestKindsSet(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Kinds
+		wantErr bool
+	}{
+		{"Field=member", Kinds{"Field": KindMember}, false},
+		{"enum_member=chunk,TypeAlias=skip", Kinds{"EnumMember": KindChunk, "TypeAlias": KindSkip}, false},
+		{"", Kinds{}, false},
+		{"Field", nil, true},
+		{"Method=chunk", nil, true},
+		{"Field=inline", nil, true},
+	}
+
+	for _, tt := range tests {
+		var kinds Kinds
+		err := kinds.Set(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Set(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(kinds, tt.want) {
+			t.Errorf("Set(%q) = %v, expected %v", tt.spec, kinds, tt.want)
+		}
+	}
+
+	kinds := Kinds{"Variable": KindChunk, "Field": KindMember}
+	if got := kinds.String(); got != "Field=member,Variable=chunk" {
+		t.Errorf("String() = %q", got)
+	}
+	if kinds.Mode("Constant") != KindSkip || kinds.Mode("TypeAlias") != KindChunk {
+		t.Error("Expected unselected kinds to fall back to the defaults")
+	}
+}
+
+func TestSymbolCodeType(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"typedef struct { int x; } Point;", "Typedef"},
+		{"using Callback = void (*)(int);", "TypeAlias"},
+		{"template <typename T> using Vec = std::vector<T>;", "TypeAlias"},
+		{"struct Point { int x; };", "Struct"},
+	}
+
+	for _, tt := range tests {
+		kind := lsp.SymbolKindClass
+		if tt.want == "Struct" {
+			kind = lsp.SymbolKindStruct
+		}
+		symbol := lsp.DocumentSymbol{Kind: kind, Range: lsp.Range{End: lsp.Position{Character: len(tt.source)}}}
+		if got := symbolCodeType(symbol, []string{tt.source}); got != tt.want {
+			t.Errorf("symbolCodeType(%q) = %q, expected %q", tt.source, got, tt.want)
+		}
+	}
+}
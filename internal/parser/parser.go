@@ -1,13 +1,19 @@
 package parser
 
 import (
-	"os"
+	"io/fs"
 	"path/filepath"
 	"strings"
 )
 
-// FindCppFiles recursively finds all C++ source and header files
-func FindCppFiles(rootPath string) ([]string, error) {
+// FindCppFiles recursively finds all C++ source and header files. Paths
+// ignored by .gitignore files, .git/info/exclude or the project ignore file
+// are skipped, as are files the filter doesn't keep.
+func FindCppFiles(rootPath string, filter Filter) ([]string, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
 	var files []string
 
 	// Directories to skip
@@ -20,22 +26,32 @@ func FindCppFiles(rootPath string) ([]string, error) {
 		"vendor":       true,
 	}
 
-	err := filepath.Walk(rootPath, func(path string, info os.FileInfo, err error) error {
+	ignores := newIgnoreSet(rootPath)
+
+	err := filepath.WalkDir(rootPath, func(path string, entry fs.DirEntry, err error) error {
 		if err != nil {
 			// Log but continue walking
 			return nil
 		}
 
+		rel, _ := filepath.Rel(rootPath, path)
+		rel = filepath.ToSlash(rel)
+
 		// Skip excluded directories
-		if info.IsDir() {
-			if skipDirs[info.Name()] {
+		if entry.IsDir() {
+			if rel == "." {
+				ignores.enter(path, "")
+				return nil
+			}
+			if skipDirs[entry.Name()] || ignores.ignored(rel, true) {
 				return filepath.SkipDir
 			}
+			ignores.enter(path, rel)
 			return nil
 		}
 
 		// Check for C++ file extensions
-		if isCppFile(path) {
+		if isCppFile(path) && !ignores.ignored(rel, false) && filter.Keep(rel) {
 			files = append(files, path)
 		}
 
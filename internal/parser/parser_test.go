@@ -47,7 +47,7 @@ func TestFindCppFiles(t *testing.T) {
 	}
 
 	// Run FindCppFiles
-	files, err := FindCppFiles(tmpDir)
+	files, err := FindCppFiles(tmpDir, Filter{})
 	if err != nil {
 		t.Fatalf("FindCppFiles failed: %v", err)
 	}
@@ -141,7 +141,7 @@ func TestFindCppFilesEmptyDirectory(t *testing.T) {
 	}
 	defer os.RemoveAll(tmpDir)
 
-	files, err := FindCppFiles(tmpDir)
+	files, err := FindCppFiles(tmpDir, Filter{})
 	if err != nil {
 		t.Fatalf("FindCppFiles failed: %v", err)
 	}
@@ -154,7 +154,7 @@ func TestFindCppFilesEmptyDirectory(t *testing.T) {
 }
 
 func TestFindCppFilesNonExistentDirectory(t *testing.T) {
-	files, err := FindCppFiles("/nonexistent/directory/that/does/not/exist")
+	files, err := FindCppFiles("/nonexistent/directory/that/does/not/exist", Filter{})
 
 	// Should handle gracefully
 	if err != nil {
@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"strings"
+
+	"clangd-parser/internal/model"
+)
+
+// qualify returns the scopes enclosing a symbol and its unqualified name.
+// Out-of-line definitions such as Foo::bar are reported with their
+// qualifier, whose parts become scopes of their own: namespaces for a
+// namespace, a class for the last part before a method, and of unknown kind
+// otherwise. Template arguments are left out, so that a definition in
+// Foo<T>::bar matches its declaration in Foo.
+func qualify(name, codeType string, parents []model.Scope) ([]model.Scope, string) {
+	parts := splitQualified(name)
+	if len(parts) == 1 && parts[0] == "" {
+		parts[0] = "(anonymous " + strings.ToLower(codeType) + ")"
+	}
+
+	scopes := parents[:len(parents):len(parents)]
+	qualifiers := parts[:len(parts)-1]
+	for i, part := range qualifiers {
+		scope := model.Scope{Name: stripTemplateArgs(part)}
+		switch {
+		case codeType == "Namespace":
+			scope.Kind = "Namespace"
+		case i == len(qualifiers)-1 && (codeType == "Method" || codeType == "Constructor"):
+			scope.Kind = "Class"
+		}
+		scopes = append(scopes, scope)
+	}
+	return scopes, stripTemplateArgs(parts[len(parts)-1])
+}
+
+// qualifiedName joins the names of scopes and a symbol's own name
+func qualifiedName(scopes []model.Scope, name string) string {
+	var b strings.Builder
+	for _, s := range scopes {
+		b.WriteString(s.Name)
+		b.WriteString("::")
+	}
+	b.WriteString(name)
+	return b.String()
+}
+
+// splitQualified splits a name such as "ns::Foo<a::B>::bar" at the scope
+// separators outside template arguments and parentheses. An operator name
+// is kept whole.
+func splitQualified(name string) []string {
+	name = strings.TrimPrefix(strings.TrimSpace(name), "::")
+
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i < len(name); i++ {
+		if i == start && strings.HasPrefix(name[i:], "operator") {
+			break
+		}
+		switch name[i] {
+		case '<', '(':
+			depth++
+		case '>', ')':
+			if depth > 0 {
+				depth--
+			}
+		case ':':
+			if depth == 0 && strings.HasPrefix(name[i:], "::") {
+				parts = append(parts, name[start:i])
+				start = i + 2
+				i++
+			}
+		}
+	}
+	return append(parts, name[start:])
+}
+
+// stripTemplateArgs removes the template argument lists from a name, except
+// from operator names such as operator<
+func stripTemplateArgs(name string) string {
+	if strings.HasPrefix(name, "operator") {
+		return name
+	}
+
+	var b strings.Builder
+	depth := 0
+	for _, r := range name {
+		switch {
+		case r == '<':
+			depth++
+		case r == '>' && depth > 0:
+			depth--
+		case depth == 0:
+			b.WriteRune(r)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,95 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"clangd-parser/internal/lsp"
+	"clangd-parser/internal/model"
+)
+
+func TestSplitQualified(t *testing.T) {
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"bar", []string{"bar"}},
+		{"::ns::Foo::bar", []string{"ns", "Foo", "bar"}},
+		{"Map<a::Key, b::Value>::find", []string{"Map<a::Key, b::Value>", "find"}},
+		{"(anonymous namespace)::helper", []string{"(anonymous namespace)", "helper"}},
+		{"Foo::operator<", []string{"Foo", "operator<"}},
+		{"Foo::operator()", []string{"Foo", "operator()"}},
+	}
+
+	for _, tt := range tests {
+		if got := splitQualified(tt.name); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitQualified(%q) = %q, expected %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestQualifiedNames(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "shape.cpp")
+	code := `namespace geo {
+namespace {
+int helper() { return 1; }
+}
+class Outer {
+    struct Inner {
+        void method() {}
+    };
+};
+double Shape<T>::area() const { return 0; }
+}
+`
+	if err := os.WriteFile(testFile, []byte(code), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	symbol := func(name string, kind, from, to int, children ...lsp.DocumentSymbol) lsp.DocumentSymbol {
+		return lsp.DocumentSymbol{
+			Name:           name,
+			Kind:           kind,
+			Range:          lsp.Range{Start: lsp.Position{Line: from}, End: lsp.Position{Line: to, Character: 1}},
+			SelectionRange: lsp.Range{Start: lsp.Position{Line: from}, End: lsp.Position{Line: from}},
+			Children:       children,
+		}
+	}
+	symbols := []lsp.DocumentSymbol{
+		symbol("geo", lsp.SymbolKindNamespace, 0, 10,
+			symbol("(anonymous namespace)", lsp.SymbolKindNamespace, 1, 3,
+				symbol("helper", lsp.SymbolKindFunction, 2, 2)),
+			symbol("Outer", lsp.SymbolKindClass, 4, 8,
+				symbol("Inner", lsp.SymbolKindStruct, 5, 7,
+					symbol("method", lsp.SymbolKindMethod, 6, 6))),
+			symbol("Shape<T>::area", lsp.SymbolKindMethod, 9, 9)),
+	}
+	chunks := ConvertSymbolsToChunks(symbols, testFile, lsp.PositionEncodingUTF8, nil)
+
+	want := map[string]string{
+		"helper":         "geo::(anonymous namespace)::helper",
+		"method":         "geo::Outer::Inner::method",
+		"Shape<T>::area": "geo::Shape::area",
+	}
+	for _, c := range chunks {
+		if name, ok := want[c.Name]; ok && c.QualifiedName != name {
+			t.Errorf("%s: got qualified name %q, expected %q", c.Name, c.QualifiedName, name)
+		}
+	}
+
+	method := chunks[5]
+	wantScopes := []model.Scope{{Name: "geo", Kind: "Namespace"}, {Name: "Outer", Kind: "Class"}, {Name: "Inner", Kind: "Struct"}}
+	if !reflect.DeepEqual(method.Scopes, wantScopes) {
+		t.Errorf("Scopes = %+v\nexpected %+v", method.Scopes, wantScopes)
+	}
+
+	// The qualifier of an out-of-line method names its class
+	area := chunks[6]
+	wantScopes = []model.Scope{{Name: "geo", Kind: "Namespace"}, {Name: "Shape", Kind: "Class"}}
+	if !reflect.DeepEqual(area.Scopes, wantScopes) || area.Context.StructName != "Shape" {
+		t.Errorf("Unexpected out-of-line scopes %+v in %q", area.Scopes, area.Context.StructName)
+	}
+	t.Logf("✓ Qualified %d chunks", len(chunks))
+}
@@ -0,0 +1,239 @@
+package parser
+
+import (
+	"os"
+	"strings"
+	"unicode"
+
+	"clangd-parser/internal/lsp"
+	"clangd-parser/internal/model"
+)
+
+// EstimateTokens approximates how many tokens a subword tokenizer produces
+// for source code: one per punctuation character and one per four letters
+// or digits of a word
+func EstimateTokens(s string) int {
+	tokens, word := 0, 0
+	flush := func() {
+		tokens += (word + 3) / 4
+		word = 0
+	}
+
+	for _, r := range s {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			word++
+		case unicode.IsSpace(r):
+			flush()
+		default:
+			flush()
+			tokens++
+		}
+	}
+	flush()
+
+	return tokens
+}
+
+// HasOversized reports whether any chunk's snippet exceeds maxTokens
+func HasOversized(chunks []model.SemanticChunk, maxTokens int) bool {
+	for _, c := range chunks {
+		if EstimateTokens(c.Context.Snippet) > maxTokens {
+			return true
+		}
+	}
+	return false
+}
+
+// ChunkSize bounds the size of chunks in estimated tokens. Zero disables
+// either bound.
+type ChunkSize struct {
+	MinTokens int // tiny siblings are grouped up to this size
+	MaxTokens int // larger chunks are split into parts
+}
+
+// SplitChunks breaks every chunk whose snippet exceeds maxTokens into parts
+// that fit, inserted right after it. Cuts go between the chunks nested in it
+// and the given folding ranges where possible, then between statements, and
+// only as a last resort inside a nested block. Zero maxTokens disables
+// splitting.
+func SplitChunks(chunks []model.SemanticChunk, filePath string, folds []lsp.FoldingRange, maxTokens int) []model.SemanticChunk {
+	if maxTokens <= 0 || !HasOversized(chunks, maxTokens) {
+		return chunks
+	}
+
+	content, _ := os.ReadFile(filePath)
+	text := lsp.NewText(content, lsp.PositionEncodingUTF8)
+	lines := text.Lines()
+
+	var result []model.SemanticChunk
+	for _, c := range chunks {
+		parts := splitChunk(&c, chunks, folds, text, lines, maxTokens)
+		result = append(result, c)
+		result = append(result, parts...)
+	}
+	return result
+}
+
+// span is a block of lines, 0-based and inclusive, that cuts should not
+// fall into
+type span struct {
+	start, end int
+}
+
+// splitChunk returns the parts of parent, updating it to list them. A chunk
+// that fits or has no body to split yields none.
+func splitChunk(parent *model.SemanticChunk, chunks []model.SemanticChunk, folds []lsp.FoldingRange, text *lsp.Text, lines []string, maxTokens int) []model.SemanticChunk {
+	first, last := parent.LineFrom-1, parent.LineTo-1
+	if EstimateTokens(parent.Context.Snippet) <= maxTokens || first < 0 || last >= len(lines) {
+		return nil
+	}
+
+	headerEnd := declarationEnd(lines, first, last)
+	if headerEnd >= last {
+		return nil
+	}
+	header := strings.Join(lines[first:headerEnd+1], "\n")
+	budget := max(maxTokens-EstimateTokens(header), maxTokens/2)
+
+	blocks := nestedBlocks(*parent, chunks, folds, lines, headerEnd+1, last)
+	ranges := packLines(lines, headerEnd+1, last, blocks, budget)
+
+	ref := model.ChunkRef{ID: parent.ID, Name: parent.Name, FilePath: parent.Context.FilePath, Line: parent.Line}
+	parts := make([]model.SemanticChunk, len(ranges))
+	for i, r := range ranges {
+		parentRef := ref
+		chunkContext := parent.Context
+		chunkContext.Snippet = strings.Join(lines[r.start:r.end+1], "\n")
+
+		parts[i] = model.SemanticChunk{
+			ID:           model.PartID(parent.ID, i+1),
+			Name:         parent.Name,
+			Signature:    parent.Signature,
+			CodeType:     parent.CodeType,
+			Line:         r.start + 1,
+			LineFrom:     r.start + 1,
+			LineTo:       r.end + 1,
+			Context:      chunkContext,
+			Range:        sourceRange(lsp.Range{Start: lsp.Position{Line: r.start}, End: lsp.Position{Line: r.end, Character: len(lines[r.end])}}, text),
+			Parent:       &parentRef,
+			Part:         i + 1,
+			Header:       header,
+			ParseQuality: parent.ParseQuality,
+		}
+		parent.Parts = append(parent.Parts, model.ChunkRef{ID: parts[i].ID, Name: parent.Name, FilePath: chunkContext.FilePath, Line: r.start + 1})
+	}
+
+	parent.Context.Snippet = header
+	return parts
+}
+
+// declarationEnd returns the line that opens the body of the symbol spanning
+// first to last, or first if there is none within a few lines
+func declarationEnd(lines []string, first, last int) int {
+	const maxLines = 5
+
+	for i := first; i <= last && i < first+maxLines; i++ {
+		if strings.Contains(lines[i], "{") {
+			return i
+		}
+	}
+	return first
+}
+
+// nestedBlocks collects the chunks and folding ranges inside the body from
+// from to to. A chunk's block includes the comment lines above it.
+func nestedBlocks(parent model.SemanticChunk, chunks []model.SemanticChunk, folds []lsp.FoldingRange, lines []string, from, to int) []span {
+	var blocks []span
+	add := func(start, end int) {
+		if start >= from && end <= to && start <= end {
+			blocks = append(blocks, span{start, end})
+		}
+	}
+
+	for _, c := range chunks {
+		if c.ID == parent.ID || c.Context.FilePath != parent.Context.FilePath {
+			continue
+		}
+		start := c.LineFrom - 1
+		for start > from && isCommentLine(lines[start-1]) {
+			start--
+		}
+		add(start, c.LineTo-1)
+	}
+
+	for _, f := range folds {
+		end := f.EndLine
+		// Line-only folds end before the closing brace
+		if end+1 < to && isCloser(lines[end+1]) {
+			end++
+		}
+		add(f.StartLine, end)
+	}
+
+	return blocks
+}
+
+// packLines groups the lines from from to to greedily into ranges of at most
+// budget tokens. When a line doesn't fit, the range is cut at the cheapest
+// point since its start, the latest one on ties.
+func packLines(lines []string, from, to int, blocks []span, budget int) []span {
+	// sums[i] is the token count of the lines from up to, excluding, i
+	sums := make([]int, to+2)
+	for i := from; i <= to; i++ {
+		sums[i+1] = sums[i] + EstimateTokens(lines[i])
+	}
+
+	var ranges []span
+	start := from
+	for i := from; i <= to; i++ {
+		for i > start && sums[i+1]-sums[start] > budget {
+			cut := i
+			for b := i; b > start; b-- {
+				if cutCost(lines, blocks, b) < cutCost(lines, blocks, cut) {
+					cut = b
+				}
+			}
+			ranges = append(ranges, span{start, cut - 1})
+			start = cut
+		}
+	}
+	return append(ranges, span{start, to})
+}
+
+// cutCost rates starting a new part at line i. Each enclosing block costs
+// more than anything at a shallower depth; within a depth, cutting between
+// blocks or statements is cheapest and cutting before a closing brace is
+// avoided.
+func cutCost(lines []string, blocks []span, i int) int {
+	depth, between := 0, false
+	for _, b := range blocks {
+		if b.start < i && i <= b.end {
+			depth++
+		}
+		if b.start == i || b.end+1 == i {
+			between = true
+		}
+	}
+
+	cost := 3 * depth
+	prev := strings.TrimSpace(lines[i-1])
+	if !between && prev != "" && !strings.HasSuffix(prev, ";") && !strings.HasSuffix(prev, "{") && !strings.HasSuffix(prev, "}") {
+		cost++
+	}
+	if isCloser(lines[i]) {
+		cost += 2
+	}
+	return cost
+}
+
+// isCloser reports whether a line only closes blocks, such as "};"
+func isCloser(line string) bool {
+	line = strings.TrimSpace(line)
+	return line != "" && strings.Trim(line, "});,") == ""
+}
+
+func isCommentLine(line string) bool {
+	line = strings.TrimSpace(line)
+	return strings.HasPrefix(line, "//") || strings.HasPrefix(line, "/*") || strings.HasPrefix(line, "*")
+}
@@ -0,0 +1,166 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"clangd-parser/internal/lsp"
+	"clangd-parser/internal/model"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"", 0},
+		{"int a = 1;", 5},
+		{"void first() {", 6},
+		{"QMetaObject::invokeMethod", 8},
+		{"   ", 0},
+	}
+	for _, tt := range tests {
+		if got := EstimateTokens(tt.in); got != tt.want {
+			t.Errorf("EstimateTokens(%q) = %d, expected %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+// partLines returns the 1-based line range of each part following parent
+func partLines(t *testing.T, chunks []model.SemanticChunk, parent string) [][2]int {
+	t.Helper()
+	var lines [][2]int
+	for _, c := range chunks {
+		if c.Parent != nil && c.Parent.ID == parent {
+			lines = append(lines, [2]int{c.LineFrom, c.LineTo})
+		}
+	}
+	return lines
+}
+
+func TestSplitChunksAtNestedSymbols(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "big.cpp")
+	code := `class Big {
+public:
+    /// First
+    void first() {
+        int a = 1;
+        int b = 2;
+    }
+
+    /// Second
+    void second() {
+        int c = 3;
+        int d = 4;
+    }
+
+    void third() {
+        int e = 5;
+    }
+};
+`
+	if err := os.WriteFile(testFile, []byte(code), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	method := func(name string, from, to int) lsp.DocumentSymbol {
+		return lsp.DocumentSymbol{
+			Name:           name,
+			Kind:           lsp.SymbolKindMethod,
+			Range:          lsp.Range{Start: lsp.Position{Line: from, Character: 4}, End: lsp.Position{Line: to, Character: 5}},
+			SelectionRange: lsp.Range{Start: lsp.Position{Line: from, Character: 9}, End: lsp.Position{Line: from, Character: 9 + len(name)}},
+		}
+	}
+	symbols := []lsp.DocumentSymbol{{
+		Name:           "Big",
+		Kind:           lsp.SymbolKindClass,
+		Range:          lsp.Range{Start: lsp.Position{Line: 0}, End: lsp.Position{Line: 17, Character: 2}},
+		SelectionRange: lsp.Range{Start: lsp.Position{Line: 0, Character: 6}, End: lsp.Position{Line: 0, Character: 9}},
+		Children:       []lsp.DocumentSymbol{method("first", 3, 6), method("second", 9, 12), method("third", 14, 16)},
+	}}
+
+	chunks := ConvertSymbolsToChunks(symbols, testFile, lsp.PositionEncodingUTF8, nil)
+	split := SplitChunks(chunks, testFile, nil, 30)
+
+	// Cuts fall between the methods, keeping their comments attached
+	want := [][2]int{{2, 8}, {9, 14}, {15, 18}}
+	if got := partLines(t, split, chunks[0].ID); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got parts %v, expected %v", got, want)
+	}
+
+	parent := split[0]
+	if parent.Context.Snippet != "class Big {" || len(parent.Parts) != 3 {
+		t.Errorf("Expected the parent to keep its header and list 3 parts, got %q and %+v", parent.Context.Snippet, parent.Parts)
+	}
+
+	for i, part := range split[1:4] {
+		if part.ID != model.PartID(parent.ID, i+1) || part.Part != i+1 || parent.Parts[i].ID != part.ID {
+			t.Errorf("Part %d has ID %q and number %d", i+1, part.ID, part.Part)
+		}
+		if part.Header != "class Big {" || part.Name != "Big" || part.Parent.Line != 1 {
+			t.Errorf("Part %d lost its parent: header %q, name %q, parent %+v", i+1, part.Header, part.Name, part.Parent)
+		}
+		if tokens := EstimateTokens(part.Header + "\n" + part.Context.Snippet); tokens > 30 {
+			t.Errorf("Part %d has %d tokens, over the budget", i+1, tokens)
+		}
+		if part.Range == nil || part.Range.StartLine != part.LineFrom || part.Range.StartColumn != 1 {
+			t.Errorf("Part %d has range %+v", i+1, part.Range)
+		}
+	}
+
+	// The methods fit and stay whole, after the parts
+	if len(split) != len(chunks)+3 || split[4].Name != "first" || split[4].Parts != nil {
+		t.Errorf("Expected the methods to follow the parts unchanged, got %d chunks", len(split))
+	}
+	t.Logf("✓ Split %s into %v", parent.Name, want)
+}
+
+func TestSplitChunksAtFoldingRanges(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "run.cpp")
+	code := `int run(int n) {
+    int total = 0;
+    for (int i = 0; i < n; i++) {
+        total += i;
+        total *= 2;
+    }
+    if (total > 100) {
+        total = 100;
+    }
+    return total;
+}
+`
+	if err := os.WriteFile(testFile, []byte(code), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	symbols := []lsp.DocumentSymbol{{
+		Name:           "run",
+		Detail:         "int (int)",
+		Kind:           lsp.SymbolKindFunction,
+		Range:          lsp.Range{Start: lsp.Position{Line: 0}, End: lsp.Position{Line: 10, Character: 1}},
+		SelectionRange: lsp.Range{Start: lsp.Position{Line: 0, Character: 4}, End: lsp.Position{Line: 0, Character: 7}},
+	}}
+	chunks := ConvertSymbolsToChunks(symbols, testFile, lsp.PositionEncodingUTF8, nil)
+
+	// Line-only folds, ending before the closing brace
+	folds := []lsp.FoldingRange{{StartLine: 0, EndLine: 9}, {StartLine: 2, EndLine: 4}, {StartLine: 6, EndLine: 7}}
+
+	// The loop and the if statement stay whole
+	want := [][2]int{{2, 2}, {3, 6}, {7, 11}}
+	if got := partLines(t, SplitChunks(chunks, testFile, folds, 40), chunks[0].ID); !reflect.DeepEqual(got, want) {
+		t.Errorf("With folding ranges got parts %v, expected %v", got, want)
+	}
+
+	// Without them any statement boundary will do
+	want = [][2]int{{2, 4}, {5, 11}}
+	if got := partLines(t, SplitChunks(chunks, testFile, nil, 40), chunks[0].ID); !reflect.DeepEqual(got, want) {
+		t.Errorf("Without folding ranges got parts %v, expected %v", got, want)
+	}
+
+	if got := SplitChunks(chunks, testFile, folds, 0); !reflect.DeepEqual(got, chunks) {
+		t.Error("Expected no splitting without a budget")
+	}
+	t.Logf("✓ Kept nested blocks whole")
+}
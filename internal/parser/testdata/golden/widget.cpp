@@ -0,0 +1,23 @@
+#include <string>
+
+namespace ui {
+
+/// A rectangular area on screen
+class Widget {
+public:
+    Widget(int w, int h);
+
+    /// Draws the widget
+    void draw() const;
+
+    int area() const { return width * height; }
+
+private:
+    int width;
+    int height;
+};
+
+} // namespace ui
+
+/// Creates the default widget
+ui::Widget makeWidget() { return ui::Widget(1, 2); }